@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	averageInterval  = 10.0
+	averageMethod    = "median"
+	averageOutlier   = 3.0
+	averageTolerance = 20.0
+)
+
+// averageCmd represents the average command
+var averageCmd = &cobra.Command{
+	Use:   "average",
+	Args:  cobra.NoArgs,
+	Short: "Average GPX recordings of the same route into a centerline",
+	Long: `Average GPX recordings of the same route into a centerline.
+
+All the tracks of each GPX file are taken as one recording. The recordings are
+turned to the same direction, resampled every interval along the recording of
+median length, and aligned to each other by distance. Recordings deviating
+more than the outlier times of the median deviation are rejected. The
+centerline is the median, or the mean weighted by the inverse square
+deviations of the recordings.
+
+Each point of the centerline has in its extensions the spread, which is the
+RMS distance in meters of the recordings to it, and the confidence, which is
+the ratio of the recordings passing within the tolerance.
+
+Examples:
+  # Average the recordings of volunteers every 5 meters
+  gpxtoolkit average --file a.gpx --file b.gpx --file c.gpx --interval 5
+
+  # Weighted mean with a stricter outlier rejection
+  gpxtoolkit average --file a.gpx --file b.gpx --file c.gpx --method mean --outlier 2
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logs, err := loadTrackLogs()
+		if err != nil {
+			return err
+		}
+		average := &gpxutil.AverageTracks{
+			Interval:  averageInterval,
+			Method:    averageMethod,
+			Outlier:   averageOutlier,
+			Tolerance: averageTolerance,
+		}
+		trackLog, err := average.Run(logs)
+		if err != nil {
+			return err
+		}
+		for _, i := range average.Rejected {
+			name := "stdin"
+			if i < len(files) {
+				name = files[i]
+			}
+			fmt.Fprintf(os.Stderr, "Rejected recording %d: %s\n", i, name)
+		}
+		fmt.Fprintf(os.Stderr, "Averaged %d of %d recordings\n", len(logs)-len(average.Rejected), len(logs))
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(averageCmd)
+	averageCmd.Flags().Float64VarP(&averageInterval, "interval", "i", averageInterval, "Interval in meters of centerline points")
+	averageCmd.Flags().StringVarP(&averageMethod, "method", "m", averageMethod, fmt.Sprintf("Method of averaging: %s", strings.Join(gpxutil.AverageMethods, ", ")))
+	averageCmd.Flags().Float64VarP(&averageOutlier, "outlier", "o", averageOutlier, "Times of the median deviation to reject recordings")
+	averageCmd.Flags().Float64VarP(&averageTolerance, "tolerance", "t", averageTolerance, "Tolerance in meters of confidence")
+}
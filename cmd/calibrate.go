@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	paceCalibrationLength = 20.0
+	paceCalibrationBin    = 0.05
+	paceCalibrationSpeed  = 0.2
+)
+
+// calibrateCmd represents the calibrate command
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Args:  cobra.NoArgs,
+	Short: "Calibrate a personal pace model from recorded GPX tracks",
+	Long: `Calibrate a personal pace model from recorded GPX tracks.
+
+Fits the horizontal speed by grade from the timestamped points with elevation
+of the given files, excluding stops, and outputs the model as JSON. The model
+can be used by the 'time' and 'eta' commands with --pace-model.
+
+Examples:
+  # Calibrate from past tracks of a hiker
+  gpxtoolkit calibrate --file past1.gpx --file past2.gpx > hiker.json
+
+  # Estimate the hiking time of a route by the model
+  gpxtoolkit eta --file route.gpx --pace-model hiker.json
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logs, err := loadTrackLogs()
+		if err != nil {
+			return err
+		}
+		segments := make([][]*gpx.Point, 0)
+		for _, log := range logs {
+			segments = append(segments, trackLogSegments(log)...)
+		}
+		pace, err := gpxutil.CalibratePace(segments, paceCalibrationLength, paceCalibrationBin, paceCalibrationSpeed)
+		if err != nil {
+			return err
+		}
+		for i, grade := range pace.Grades {
+			speed := pace.Speeds[i]
+			fmt.Fprintf(os.Stderr, "Grade %+4.0f%%: %.2f m/s (%.1f km/h)\n", grade*100, speed, speed*3.6)
+		}
+		data, err := json.MarshalIndent(pace, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", data)
+		return nil
+	},
+}
+
+func trackLogSegments(log *gpx.TrackLog) [][]*gpx.Point {
+	segments := make([][]*gpx.Point, 0)
+	for _, t := range log.Tracks {
+		for _, s := range t.Segments {
+			segments = append(segments, s.Points)
+		}
+	}
+	return segments
+}
+
+func init() {
+	rootCmd.AddCommand(calibrateCmd)
+	calibrateCmd.Flags().Float64VarP(&paceCalibrationLength, "length", "l", paceCalibrationLength, "Minimum horizontal length in meters of each sample")
+	calibrateCmd.Flags().Float64VarP(&paceCalibrationBin, "bin", "b", paceCalibrationBin, "Width of grade bins, e.g. 0.05 for 5%")
+	calibrateCmd.Flags().Float64VarP(&paceCalibrationSpeed, "min-speed", "s", paceCalibrationSpeed, "Samples slower than this speed in m/s are regarded as stops")
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	climbsWindow           = 100.0
+	climbsTolerance        = 10.0
+	climbsMinGain          = 50.0
+	climbsWaypoints        = false
+	climbsCorrectElevation = false
+)
+
+// climbsCmd represents the climbs command
+var climbsCmd = &cobra.Command{
+	Use:   "climbs",
+	Args:  cobra.NoArgs,
+	Short: "Detect and categorize climbs and descents of GPX tracks",
+	Long: `Detect and categorize climbs and descents of GPX tracks.
+
+Scans the smoothed elevation profile of each track for continuous climbs and
+descents, tolerating small dips, and reports their location, length, gain,
+average and max grade and category (HC, 1, 2, 3 or 4 from the hardest).
+
+Examples:
+  # Report climbs with at least 100 meters of gain
+  gpxtoolkit climbs --file track.gpx --min-gain 100
+
+  # Add the starts and tops of climbs as waypoints
+  gpxtoolkit climbs --file track.gpx --waypoints > climbs.gpx
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		if climbsCorrectElevation {
+			elev := &gpxutil.CorrectElevation{
+				Waypoints: false,
+				Service:   getElevationService(),
+			}
+			if elev.Service == nil {
+				return fmt.Errorf("no elevation service")
+			}
+			_, err = elev.Run(trackLog)
+			if err != nil {
+				return err
+			}
+		}
+		detect := &gpxutil.DetectClimbs{
+			Window:    climbsWindow,
+			Tolerance: climbsTolerance,
+			MinGain:   climbsMinGain,
+		}
+		var report io.Writer = os.Stdout
+		if climbsWaypoints {
+			report = os.Stderr
+		}
+		waypoints := make([]*gpx.WayPoint, 0)
+		for i, t := range trackLog.Tracks {
+			fmt.Fprintf(report, "=== Track %d: %s ===\n", i, t.GetName())
+			offset := 0.0
+			numClimbs, numDescents := 0, 0
+			for _, s := range t.Segments {
+				climbs, err := detect.Detect(s.Points)
+				if err != nil {
+					return err
+				}
+				for _, c := range climbs {
+					kind, top := "Climb", "top"
+					num := 0
+					if c.IsDescent() {
+						numDescents++
+						kind, top, num = "Descent", "bottom", numDescents
+					} else {
+						numClimbs++
+						num = numClimbs
+					}
+					category := c.Category
+					if category == "" {
+						category = "-"
+					}
+					summary := fmt.Sprintf("%.0fm, %+.0fm, avg %+.1f%%, max %+.1f%%, cat %s",
+						c.Length(), c.Gain, c.AverageGrade*100, c.MaxGrade*100, category)
+					fmt.Fprintf(report, "%s %d: %.0fm (%f,%f) to %.0fm (%f,%f): %s\n",
+						kind, num,
+						offset+c.StartChainage, c.Start.GetLatitude(), c.Start.GetLongitude(),
+						offset+c.EndChainage, c.End.GetLatitude(), c.End.GetLongitude(),
+						summary)
+					if climbsWaypoints {
+						waypoints = append(waypoints,
+							climbWaypoint(c.Start, fmt.Sprintf("%s %d start", kind, num), summary),
+							climbWaypoint(c.End, fmt.Sprintf("%s %d %s", kind, num, top), summary))
+					}
+				}
+				offset += segmentLength(s)
+			}
+		}
+		if climbsWaypoints {
+			trackLog.WayPoints = append(trackLog.WayPoints, waypoints...)
+			return dumpGpx(trackLog)
+		}
+		return nil
+	},
+}
+
+func climbWaypoint(p *gpx.Point, name, description string) *gpx.WayPoint {
+	return &gpx.WayPoint{
+		Name:        proto.String(name),
+		Description: proto.String(description),
+		Latitude:    p.Latitude,
+		Longitude:   p.Longitude,
+		Elevation:   p.Elevation,
+		NanoTime:    p.NanoTime,
+	}
+}
+
+func segmentLength(s *gpx.Segment) float64 {
+	length := 0.0
+	if len(s.Points) <= 1 {
+		return length
+	}
+	for i, b := range s.Points[1:] {
+		length += gpxutil.HaversinDistance(s.Points[i], b)
+	}
+	return length
+}
+
+func init() {
+	rootCmd.AddCommand(climbsCmd)
+	climbsCmd.Flags().Float64Var(&climbsWindow, "window", climbsWindow, "Horizontal window in meters for smoothing the elevation")
+	climbsCmd.Flags().Float64Var(&climbsTolerance, "tolerance", climbsTolerance, "Dips smaller than this elevation in meters are tolerated within a climb")
+	climbsCmd.Flags().Float64VarP(&climbsMinGain, "min-gain", "g", climbsMinGain, "Minimum elevation gain in meters of a climb")
+	climbsCmd.Flags().BoolVarP(&climbsWaypoints, "waypoints", "w", climbsWaypoints, "Output GPX with the starts and tops of climbs as waypoints")
+	climbsCmd.Flags().BoolVarP(&climbsCorrectElevation, "correct-elevation", "e", climbsCorrectElevation, "Correct elevation before calculation")
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareRoute     = ""
+	compareTolerance = 30.0
+	compareTimezone  = "Local"
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Args:  cobra.NoArgs,
+	Short: "Compare recorded GPX tracks against a route",
+	Long: `Compare recorded GPX tracks against a route.
+
+Reports for each recorded GPX the Hausdorff distance and the discrete Fréchet
+distance to the route, the percentage of the route covered within the
+tolerance, and every off-route section farther than the tolerance with its
+start and end time, max deviation and length. Distances are in meters.
+
+The discrete Fréchet distance couples all the points of the route and of each
+recording, so its time grows with the product of their numbers of points,
+e.g. over ten seconds for two logs of 10k points. Simplify long logs first by
+the simple command to speed it up.
+
+Examples:
+  # Check whether the surveyed line was walked within 20 meters
+  gpxtoolkit compare --route planned.gpx --file crew1.gpx --file crew2.gpx --tolerance 20
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if compareRoute == "" {
+			return fmt.Errorf("please specify the route")
+		}
+		location, err := time.LoadLocation(compareTimezone)
+		if err != nil {
+			return err
+		}
+		route, err := openGpx(compareRoute)
+		if err != nil {
+			return err
+		}
+		logs, err := loadTrackLogs()
+		if err != nil {
+			return err
+		}
+		for i, log := range logs {
+			name := "stdin"
+			if i < len(files) {
+				name = files[i]
+			}
+			c := gpxutil.Compare(route, log, compareTolerance)
+			fmt.Fprintf(os.Stdout, "=== Recorded %d: %s ===\n", i, name)
+			fmt.Fprintf(os.Stdout, "Hausdorff distance: %.1f m\n", c.Hausdorff)
+			fmt.Fprintf(os.Stdout, "Fréchet distance: %.1f m\n", c.Frechet)
+			fmt.Fprintf(os.Stdout, "Coverage: %.1f%% within %.0f m\n", c.Coverage*100, compareTolerance)
+			fmt.Fprintf(os.Stdout, "Off-route sections: %d\n", len(c.OffRoutes))
+			for _, o := range c.OffRoutes {
+				fmt.Fprintf(os.Stdout, "  %s - %s: max %.1f m, length %.0f m\n", formatPointTime(o.Start, location), formatPointTime(o.End, location), o.MaxDeviation, o.Length)
+			}
+		}
+		return nil
+	},
+}
+
+// formatPointTime formats the time of the point in the location, or '-' if
+// it has no time.
+func formatPointTime(p *gpx.Point, location *time.Location) string {
+	if p.NanoTime == nil {
+		return "-"
+	}
+	return p.Time().In(location).Format("2006-01-02 15:04:05")
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+	compareCmd.Flags().StringVarP(&compareRoute, "route", "r", compareRoute, "GPX file of the reference route")
+	compareCmd.Flags().Float64VarP(&compareTolerance, "tolerance", "t", compareTolerance, "Tolerance in meters to be on the route")
+	compareCmd.Flags().StringVarP(&compareTimezone, "timezone", "z", compareTimezone, "Time zone of times, e.g. Asia/Taipei")
+}
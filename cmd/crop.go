@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	cropFrom      = ""
+	cropTo        = ""
+	cropTimezone  = "Local"
+	cropThreshold = 30.0
+	cropBBox      = ""
+	cropCircle    = ""
+	cropArea      = ""
+	cropOutside   = false
+)
+
+// cropCmd represents the crop command
+var cropCmd = &cobra.Command{
+	Use:   "crop",
+	Args:  cobra.NoArgs,
+	Short: "Crop GPX tracks to a time range or an area",
+	Long: `Crop GPX tracks to a time range or an area.
+
+With --from or --to, keeps the track points from --from (inclusive) to --to
+(exclusive), with the boundary points interpolated, and drops points without
+time. Times are in RFC 3339, or 'YYYY-MM-DD HH:mm:SS' in the time zone.
+Waypoints with time are kept if within the range, and those without time are
+kept if the cropped tracks pass by them.
+
+With --bbox, --circle or --area, keeps the track points inside the area, or
+outside with --outside. Segments are split where the tracks leave the area,
+with the boundary crossings interpolated. Waypoints not kept are removed.
+Polygons of the area are loaded from GeoJSON (.geojson, .json) or KML (.kml).
+
+Examples:
+  # Crop to the morning of a day in Taipei
+  gpxtoolkit crop --file trek.gpx --from '2022-01-01 06:00:00' --to '2022-01-01 12:00:00' --timezone Asia/Taipei
+
+  # Extract the part of a traverse in a national park
+  gpxtoolkit crop --file traverse.gpx --area park.geojson
+
+  # Cut away the drive within 500 meters of the trailhead
+  gpxtoolkit crop --file trek.gpx --circle 24.1234,121.2345,500 --outside
+
+  # Crop to a bounding box of min lat, min lon, max lat, max lon
+  gpxtoolkit crop --file trek.gpx --bbox 24.1,121.2,24.2,121.3
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		location, err := time.LoadLocation(cropTimezone)
+		if err != nil {
+			return err
+		}
+		window := &gpxutil.TimeWindow{}
+		if cropFrom != "" {
+			window.From, err = parseTime(cropFrom, location)
+			if err != nil {
+				return err
+			}
+		}
+		if cropTo != "" {
+			window.To, err = parseTime(cropTo, location)
+			if err != nil {
+				return err
+			}
+		}
+		if !window.From.IsZero() && !window.To.IsZero() && !window.From.Before(window.To) {
+			return fmt.Errorf("invalid time range: %v to %v", window.From, window.To)
+		}
+		area, err := cropAreaOf()
+		if err != nil {
+			return err
+		}
+		commands := make([]gpxutil.Command, 0)
+		if !window.From.IsZero() || !window.To.IsZero() {
+			commands = append(commands, &gpxutil.CropByTime{
+				Window:    window,
+				Threshold: cropThreshold,
+			})
+		}
+		if area != nil {
+			commands = append(commands, &gpxutil.CropByArea{
+				Area:    area,
+				Outside: cropOutside,
+			})
+		}
+		if len(commands) <= 0 {
+			return fmt.Errorf("no time range or area to crop")
+		}
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, c := range commands {
+			n, err = c.Run(trackLog)
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Cropped to %d points\n", n)
+		return dumpGpx(trackLog)
+	},
+}
+
+// cropAreaOf returns the area of --bbox, --circle or --area, or nil if none.
+func cropAreaOf() (gpxutil.Area, error) {
+	num := 0
+	for _, v := range []string{cropBBox, cropCircle, cropArea} {
+		if v != "" {
+			num++
+		}
+	}
+	if num > 1 {
+		return nil, fmt.Errorf("only one of --bbox, --circle and --area can be specified")
+	}
+	switch {
+	case cropBBox != "":
+		values, err := parseFloats(cropBBox, 4)
+		if err != nil {
+			return nil, err
+		}
+		area := &gpxutil.BoundingBoxArea{
+			MinLatitude:  values[0],
+			MinLongitude: values[1],
+			MaxLatitude:  values[2],
+			MaxLongitude: values[3],
+		}
+		err = area.Validate()
+		if err != nil {
+			return nil, err
+		}
+		return area, nil
+	case cropCircle != "":
+		values, err := parseFloats(cropCircle, 3)
+		if err != nil {
+			return nil, err
+		}
+		area := &gpxutil.CircleArea{
+			Latitude:  values[0],
+			Longitude: values[1],
+			Radius:    values[2],
+		}
+		err = area.Validate()
+		if err != nil {
+			return nil, err
+		}
+		return area, nil
+	case cropArea != "":
+		return gpxutil.OpenPolygonArea(cropArea)
+	}
+	return nil, nil
+}
+
+// parseFloats parses the number of comma separated floats.
+func parseFloats(value string, num int) ([]float64, error) {
+	splits := strings.Split(value, ",")
+	if len(splits) != num {
+		return nil, fmt.Errorf("expected %d comma separated numbers: %s", num, value)
+	}
+	values := make([]float64, num)
+	for i, s := range splits {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func init() {
+	rootCmd.AddCommand(cropCmd)
+	cropCmd.Flags().StringVar(&cropFrom, "from", cropFrom, "Start time of the range")
+	cropCmd.Flags().StringVar(&cropTo, "to", cropTo, "End time of the range")
+	cropCmd.Flags().StringVarP(&cropTimezone, "timezone", "z", cropTimezone, "Time zone of times without offset, e.g. Asia/Taipei")
+	cropCmd.Flags().Float64VarP(&cropThreshold, "threshold", "t", cropThreshold, "Distance threshold of waypoints without time. Waypoints farer than this threshold won't be kept.")
+	cropCmd.Flags().StringVar(&cropBBox, "bbox", cropBBox, "Bounding box of 'min lat,min lon,max lat,max lon'")
+	cropCmd.Flags().StringVar(&cropCircle, "circle", cropCircle, "Circle of 'lat,lon,radius in meters'")
+	cropCmd.Flags().StringVarP(&cropArea, "area", "a", cropArea, "GeoJSON or KML file of polygons")
+	cropCmd.Flags().BoolVar(&cropOutside, "outside", cropOutside, "Keep the points outside the area instead")
+}
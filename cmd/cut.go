@@ -14,6 +14,7 @@ import (
 var (
 	cutThreshold float64 = 30
 	cutWaypoints []string
+	cutGeodesic  bool
 )
 
 // cutCmd represents the cut command
@@ -27,7 +28,7 @@ var cutCmd = &cobra.Command{
 			return err
 		}
 		cut := &gpxutil.SliceByWaypoints{
-			DistanceFunc: gpxutil.HaversinDistance,
+			DistanceMode: distanceMode(false, cutGeodesic),
 			Threshold:    cutThreshold,
 		}
 		if len(cutWaypoints) > 0 {
@@ -69,4 +70,5 @@ func init() {
 	// cutCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	cutCmd.Flags().StringSliceVarP(&cutWaypoints, "waypoint", "w", cutWaypoints, "Distance threshold of waypoints. Waypoints farer than this threshold won't be used for cutting.")
 	cutCmd.Flags().Float64VarP(&cutThreshold, "threshold", "t", cutThreshold, "Distance threshold of waypoints. Waypoints farer than this threshold won't be used for cutting.")
+	cutCmd.Flags().BoolVar(&cutGeodesic, "geodesic", cutGeodesic, "Project waypoints onto the WGS84 geodesic of track lines, e.g. for sparse planned routes")
 }
@@ -8,27 +8,58 @@ import (
 	"gpxtoolkit/gpx"
 	"gpxtoolkit/gpxutil"
 	"os"
+	"regexp"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	effortAlpha                  = 0.2
-	effortByTracks               = false
-	effortCorrectElevation       = true
-	effortWeightElevationGain    = 10.0
-	effortWeightElevationLoss    = 3.3
-	effortOxygenDensity          = true
-	effortOxygenDensitySlope     = 0.0001621796175
-	effortOxygenDensityIntercept = 0.9763291581
+	effortAlpha            = 0.2
+	effortByTracks         = false
+	effortCorrectElevation = true
+	effortOxygenDensity    = true
+	effortFormulas         []string
+	effortExpressions      []string
+	effortList             = false
 )
 
 // effortCmd represents the effort command
 var effortCmd = &cobra.Command{
 	Use:   "effort",
 	Short: "Calculate the kilimeter effort (KmE)",
-	Long:  `Calculate the kilimeter effort (KmE)`,
+	Long: `Calculate the kilimeter effort (KmE) or other effort formulas.
+
+Formulas are expressions over the following variables:
+  dist     distance in meters
+  gain     elevation gain in meters
+  loss     elevation loss in meters
+  avg_alt  average altitude in meters
+  max_alt  max altitude in meters
+  min_alt  min altitude in meters
+  hours    recorded duration in hours
+
+Examples:
+  # List built-in formulas
+  gpxtoolkit effort --list
+
+  # Evaluate built-in formulas track by track
+  gpxtoolkit effort --file track.gpx --tracks --formula kme --formula sac
+
+  # Evaluate a custom formula
+  gpxtoolkit effort --file track.gpx --expr 'mine=(dist + gain*8)/1000'
+`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if effortList {
+			for _, f := range gpxutil.EffortFormulas {
+				fmt.Fprintf(os.Stdout, "%s: %s\n", f.Name, f.Description)
+				fmt.Fprintf(os.Stdout, "    %s\n", f.Expression)
+			}
+			return nil
+		}
+		formulas, err := getEffortFormulas()
+		if err != nil {
+			return err
+		}
 		trackLog, err := loadGpx()
 		if err != nil {
 			return err
@@ -46,23 +77,25 @@ var effortCmd = &cobra.Command{
 				return err
 			}
 		}
-		print := func(title string, st *gpx.TrackStats) {
+		print := func(title string, st *gpx.TrackStats) error {
 			if title != "" {
 				fmt.Fprintf(os.Stdout, "=== %s ===\n", title)
 			}
-			dist := st.GetDistance() / 1000
-			gain := st.GetElevationGain() / 1000
-			loss := st.GetElevationLoss() / 1000
-			effort := dist + gain*effortWeightElevationGain + loss*effortWeightElevationLoss
-			if effortOxygenDensity {
-				avg := st.GetElevationDistance() / st.GetDistance()
-				effort *= effortOxygenDensitySlope*avg + effortOxygenDensityIntercept
-				fmt.Fprintf(os.Stdout, "Formula: (dist + (gain × %.2f) + (loss × %.2f)) × (slope × avg_alt + intercept) = KmE\n", effortWeightElevationGain, effortWeightElevationLoss)
-				fmt.Fprintf(os.Stdout, "         (%.2f + (%.2f × %.2f) + (%.2f × %.2f)) x (%f × %.2f + %f) = %.2f\n", dist, gain, effortWeightElevationGain, loss, effortWeightElevationLoss, effortOxygenDensitySlope, avg, effortOxygenDensityIntercept, effort)
-			} else {
-				fmt.Fprintf(os.Stdout, "Formula: dist + (gain × %.2f) + (loss × %.2f) = KmE\n", effortWeightElevationGain, effortWeightElevationLoss)
-				fmt.Fprintf(os.Stdout, "         %.2f + (%.2f × %.2f) + (%.2f × %.2f) = %.2f\n", dist, gain, effortWeightElevationGain, loss, effortWeightElevationLoss, effort)
+			vars := gpxutil.NewEffortVariables(st)
+			fmt.Fprintf(os.Stdout, "Variables: dist=%.0f gain=%.0f loss=%.0f avg_alt=%.0f max_alt=%.0f min_alt=%.0f hours=%.2f\n",
+				vars.Distance, vars.Gain, vars.Loss, vars.AvgAltitude, vars.MaxAltitude, vars.MinAltitude, vars.Hours)
+			for _, f := range formulas {
+				effort, err := f.Eval(vars)
+				if err != nil {
+					return fmt.Errorf("failed to evaluate '%s': %w", f.Name, err)
+				}
+				if f.Unit != "" {
+					fmt.Fprintf(os.Stdout, "%s: %s = %.2f %s\n", f.Name, f.Expression, effort, f.Unit)
+				} else {
+					fmt.Fprintf(os.Stdout, "%s: %s = %.2f\n", f.Name, f.Expression, effort)
+				}
 			}
+			return nil
 		}
 		if effortByTracks {
 			for i, t := range trackLog.Tracks {
@@ -70,23 +103,64 @@ var effortCmd = &cobra.Command{
 				if err != nil {
 					return err
 				}
-				print(fmt.Sprintf("Track %d: %s", i, t.GetName()), st)
+				err = print(fmt.Sprintf("Track %d: %s", i, t.GetName()), st)
+				if err != nil {
+					return err
+				}
 			}
 		} else {
 			st, err := trackLog.Stat(effortAlpha)
 			if err != nil {
 				return err
 			}
-			print("", st)
+			return print("", st)
 		}
 		return nil
 	},
 }
 
+var effortNamedExpression = regexp.MustCompile(`^([A-Za-z_][\w-]*)=([^=].*)$`)
+
+func getEffortFormulas() ([]*gpxutil.EffortFormula, error) {
+	formulas := make([]*gpxutil.EffortFormula, 0)
+	for _, name := range effortFormulas {
+		f := gpxutil.GetEffortFormula(name)
+		if f == nil {
+			return nil, fmt.Errorf("unknown formula: %s", name)
+		}
+		formulas = append(formulas, f)
+	}
+	for i, expr := range effortExpressions {
+		f := &gpxutil.EffortFormula{
+			Name:       fmt.Sprintf("expr[%d]", i),
+			Expression: expr,
+		}
+		if m := effortNamedExpression.FindStringSubmatch(expr); m != nil {
+			f.Name = m[1]
+			f.Expression = m[2]
+		}
+		if err := f.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid formula '%s': %w", f.Name, err)
+		}
+		formulas = append(formulas, f)
+	}
+	if len(formulas) <= 0 {
+		if effortOxygenDensity {
+			formulas = append(formulas, gpxutil.GetEffortFormula("kme-oxygen"))
+		} else {
+			formulas = append(formulas, gpxutil.GetEffortFormula("kme"))
+		}
+	}
+	return formulas, nil
+}
+
 func init() {
 	rootCmd.AddCommand(effortCmd)
 	effortCmd.Flags().Float64VarP(&effortAlpha, "alpha", "a", effortAlpha, "Alpha filter value for accumulating elevation gain and loss")
 	effortCmd.Flags().BoolVarP(&effortByTracks, "tracks", "t", effortByTracks, "Calculate track by track")
 	effortCmd.Flags().BoolVarP(&effortCorrectElevation, "correct-elevation", "e", effortCorrectElevation, "Correct elevation before calculation")
-	effortCmd.Flags().BoolVarP(&effortOxygenDensity, "oxygen-density", "o", effortOxygenDensity, "Consider oxygen density in the calculation")
+	effortCmd.Flags().BoolVarP(&effortOxygenDensity, "oxygen-density", "o", effortOxygenDensity, "Consider oxygen density in the calculation when no formula is specified")
+	effortCmd.Flags().StringArrayVarP(&effortFormulas, "formula", "F", effortFormulas, "Name of built-in formula; see --list")
+	effortCmd.Flags().StringArrayVar(&effortExpressions, "expr", effortExpressions, "Custom formula as an expression, optionally prefixed by 'name='")
+	effortCmd.Flags().BoolVarP(&effortList, "list", "l", effortList, "List built-in formulas")
 }
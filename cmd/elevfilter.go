@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	elevFilterMethod           = "savgol"
+	elevFilterWindow           = 100.0
+	elevFilterOrder            = 2
+	elevFilterMaxVerticalSpeed = 2.0
+)
+
+// elevFilterCmd represents the elevfilter command
+var elevFilterCmd = &cobra.Command{
+	Use:   "elevfilter",
+	Args:  cobra.NoArgs,
+	Short: "Filter the elevation of GPX track points offline",
+	Long: fmt.Sprintf(`Filter the elevation of GPX track points offline.
+
+Removes elevation spikes rising or dropping faster than the max vertical speed,
+then smooths the elevation over a horizontal window by one of the methods: %s.
+Unlike the 'elev' command, no elevation service is needed, and the latitude,
+longitude and time of points are left untouched.
+
+Examples:
+  # Smooth by Savitzky–Golay of order 2 over 100 meters
+  gpxtoolkit elevfilter --file track.gpx
+
+  # Remove spikes only
+  gpxtoolkit elevfilter --file track.gpx --method none
+`, strings.Join(gpxutil.ElevationFilters, ", ")),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		if elevFilterMaxVerticalSpeed > 0 {
+			spikes := &gpxutil.RemoveElevationSpikes{
+				MaxVerticalSpeed: elevFilterMaxVerticalSpeed,
+			}
+			n, err := spikes.Run(trackLog)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Removed %d elevation spikes\n", n)
+		}
+		if elevFilterMethod != "none" {
+			filter := &gpxutil.FilterElevation{
+				Method: elevFilterMethod,
+				Window: elevFilterWindow,
+				Order:  elevFilterOrder,
+			}
+			n, err := filter.Run(trackLog)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Filtered elevation of %d points\n", n)
+		}
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(elevFilterCmd)
+	elevFilterCmd.Flags().StringVarP(&elevFilterMethod, "method", "m", elevFilterMethod, fmt.Sprintf("Filter method: %s or none", strings.Join(gpxutil.ElevationFilters, ", ")))
+	elevFilterCmd.Flags().Float64VarP(&elevFilterWindow, "window", "w", elevFilterWindow, "Horizontal window in meters of the filter")
+	elevFilterCmd.Flags().IntVarP(&elevFilterOrder, "order", "o", elevFilterOrder, "Polynomial order of Savitzky–Golay filter")
+	elevFilterCmd.Flags().Float64VarP(&elevFilterMaxVerticalSpeed, "max-vertical-speed", "v", elevFilterMaxVerticalSpeed, "Max plausible vertical speed in m/s for removing spikes; 0 to disable")
+}
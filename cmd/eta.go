@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	etaModel            = "tobler"
+	etaSpeed            = 1.0
+	etaCalibrate        []string
+	etaThreshold        = 30.0
+	etaWindow           = 50.0
+	etaCorrectElevation = false
+	etaPaceModel        = ""
+)
+
+// etaCmd represents the eta command
+var etaCmd = &cobra.Command{
+	Use:   "eta",
+	Args:  cobra.NoArgs,
+	Short: "Estimate the hiking time of GPX tracks",
+	Long: fmt.Sprintf(`Estimate the hiking time of GPX tracks.
+
+Prints the estimated duration of each track and of each section between
+waypoints along the track by one of the pace models: %s,
+constant (with --speed), or calibrated (with --calibrate or --pace-model
+saved by the 'calibrate' command).
+
+Examples:
+  # Estimate by Tobler's hiking function
+  gpxtoolkit eta --file route.gpx
+
+  # Estimate by the pace calibrated from past tracks
+  gpxtoolkit eta --file route.gpx --calibrate past1.gpx --calibrate past2.gpx
+
+  # Estimate by a saved personal pace model
+  gpxtoolkit eta --file route.gpx --pace-model hiker.json
+`, strings.Join(paceModelNames(), ", ")),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		model, err := getPaceModel(etaModel, etaSpeed, etaCalibrate, etaPaceModel)
+		if err != nil {
+			return err
+		}
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		if etaCorrectElevation {
+			elev := &gpxutil.CorrectElevation{
+				Waypoints: false,
+				Service:   getElevationService(),
+			}
+			if elev.Service == nil {
+				return fmt.Errorf("no elevation service")
+			}
+			_, err = elev.Run(trackLog)
+			if err != nil {
+				return err
+			}
+		}
+		estimate := &gpxutil.EstimateTime{
+			Pace:      model,
+			Window:    etaWindow,
+			Threshold: etaThreshold,
+		}
+		fmt.Fprintf(os.Stdout, "Model: %s\n", model.Name())
+		total := time.Duration(0)
+		for i, t := range trackLog.Tracks {
+			legs, err := estimate.Legs(t.Points(), trackLog.WayPoints)
+			if err != nil {
+				return err
+			}
+			track := &gpxutil.Leg{}
+			for _, leg := range legs {
+				track.Distance += leg.Distance
+				track.Gain += leg.Gain
+				track.Loss += leg.Loss
+				track.Duration += leg.Duration
+			}
+			total += track.Duration
+			fmt.Fprintf(os.Stdout, "=== Track %d: %s ===\n", i, t.GetName())
+			fmt.Fprintf(os.Stdout, "Distance: %.0f meter\n", track.Distance)
+			fmt.Fprintf(os.Stdout, "Gain:     %.0f meter\n", track.Gain)
+			fmt.Fprintf(os.Stdout, "Loss:     %.0f meter\n", track.Loss)
+			fmt.Fprintf(os.Stdout, "Duration: %v\n", track.Duration.Round(time.Minute))
+			if len(legs) <= 1 {
+				continue
+			}
+			elapsed := time.Duration(0)
+			for _, leg := range legs {
+				elapsed += leg.Duration
+				from, to := "(start)", "(end)"
+				if leg.From != nil {
+					from = leg.From.GetName()
+				}
+				if leg.To != nil {
+					to = leg.To.GetName()
+				}
+				fmt.Fprintf(os.Stdout, "  %s→%s: %.0f meter, +%.0f/-%.0f meter, %v (%v)\n",
+					from, to, leg.Distance, leg.Gain, leg.Loss, leg.Duration.Round(time.Minute), elapsed.Round(time.Minute))
+			}
+		}
+		if len(trackLog.Tracks) > 1 {
+			fmt.Fprintf(os.Stdout, "=== Total ===\n")
+			fmt.Fprintf(os.Stdout, "Duration: %v\n", total.Round(time.Minute))
+		}
+		return nil
+	},
+}
+
+func paceModelNames() []string {
+	names := make([]string, 0, len(gpxutil.PaceModels))
+	for name := range gpxutil.PaceModels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getPaceModel returns the pace model loaded from the JSON file, calibrated
+// from the GPX files, or by name, in that order.
+func getPaceModel(name string, speed float64, calibrate []string, file string) (gpxutil.PaceModel, error) {
+	if file != "" {
+		if len(calibrate) > 0 {
+			return nil, fmt.Errorf("pace model file and calibration files are mutually exclusive")
+		}
+		model, err := gpxutil.OpenCalibratedPace(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load pace model '%s': %w", file, err)
+		}
+		return model, nil
+	}
+	if len(calibrate) > 0 {
+		segments := make([][]*gpx.Point, 0)
+		for _, file := range calibrate {
+			log, err := gpx.Open(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load '%s': %w", file, err)
+			}
+			segments = append(segments, trackLogSegments(log)...)
+		}
+		return gpxutil.CalibratePace(segments, paceCalibrationLength, paceCalibrationBin, paceCalibrationSpeed)
+	}
+	if name == "constant" {
+		if speed <= 0 {
+			return nil, fmt.Errorf("invalid speed: %f", speed)
+		}
+		return &gpxutil.ConstantPace{Speed: speed}, nil
+	}
+	model := gpxutil.PaceModels[name]
+	if model == nil {
+		return nil, fmt.Errorf("unknown pace model: %s", name)
+	}
+	return model, nil
+}
+
+func init() {
+	rootCmd.AddCommand(etaCmd)
+	etaCmd.Flags().StringVarP(&etaModel, "model", "m", etaModel, fmt.Sprintf("Pace model: %s or constant", strings.Join(paceModelNames(), ", ")))
+	etaCmd.Flags().Float64VarP(&etaSpeed, "speed", "s", etaSpeed, "Speed in m/s of the constant pace model")
+	etaCmd.Flags().StringArrayVar(&etaCalibrate, "calibrate", etaCalibrate, "Recorded GPX files for calibrating the pace model")
+	etaCmd.Flags().StringVar(&etaPaceModel, "pace-model", etaPaceModel, "Pace model JSON file saved by the 'calibrate' command")
+	etaCmd.Flags().Float64VarP(&etaThreshold, "threshold", "t", etaThreshold, "Distance threshold of waypoints. Waypoints farer than this threshold won't be used for sections.")
+	etaCmd.Flags().Float64VarP(&etaWindow, "window", "w", etaWindow, "Horizontal window in meters for smoothing the grade")
+	etaCmd.Flags().BoolVarP(&etaCorrectElevation, "correct-elevation", "e", etaCorrectElevation, "Correct elevation before calculation")
+}
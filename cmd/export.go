@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportDevice  = ""
+	exportProfile = ""
+	exportOutput  = ""
+	exportList    = false
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Args:  cobra.NoArgs,
+	Short: "Export GPX for a GPS device",
+	Long: `Export GPX for a GPS device.
+
+Fits the GPX into the constraints of a device profile: tracks over the point
+limit are split (or simplified), waypoint names are truncated without
+collisions, symbols are mapped to those of the device, and unsupported fields
+are stripped. Files over the track or waypoint limit are divided into several
+files, which requires --output.
+
+Use --list for the built-in profiles, or --profile for a JSON profile like:
+
+  {
+    "Name": "my-etrex",
+    "MaxTrackPoints": 500,
+    "Fit": "split",
+    "Epsilon": 5,
+    "MaxTracks": 20,
+    "MaxWaypoints": 500,
+    "WaypointNameLength": 14,
+    "Symbols": {"Milestone": "Mile Marker"},
+    "DefaultSymbol": "Flag, Blue",
+    "Strip": ["hdop", "comment"]
+  }
+
+Examples:
+  # Export for a legacy Garmin eTrex into route1.gpx, route2.gpx, ...
+  gpxtoolkit export --file trek.gpx --device garmin-legacy --output route
+
+  # Export by a custom profile to stdout
+  gpxtoolkit export --file trek.gpx --profile my-etrex.json
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportList {
+			names := make([]string, 0, len(gpxutil.DeviceProfiles))
+			for name := range gpxutil.DeviceProfiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				p := gpxutil.DeviceProfiles[name]
+				fmt.Printf("%-14s %s\n", name, p.Description)
+			}
+			return nil
+		}
+		var profile *gpxutil.DeviceProfile
+		switch {
+		case exportDevice != "" && exportProfile != "":
+			return fmt.Errorf("please specify either a device or a profile")
+		case exportProfile != "":
+			p, err := gpxutil.OpenDeviceProfile(exportProfile)
+			if err != nil {
+				return err
+			}
+			profile = p
+		case exportDevice != "":
+			profile = gpxutil.DeviceProfiles[exportDevice]
+			if profile == nil {
+				return fmt.Errorf("unknown device: %s", exportDevice)
+			}
+		default:
+			return fmt.Errorf("please specify the device or profile")
+		}
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		logs, err := profile.Export(trackLog)
+		if err != nil {
+			return err
+		}
+		if exportOutput == "" {
+			if len(logs) > 1 {
+				return fmt.Errorf("%d files are needed for %s; please specify the output", len(logs), profile.Name)
+			}
+			return dumpGpx(logs[0])
+		}
+		for i, log := range logs {
+			file := exportOutput + ".gpx"
+			if len(logs) > 1 {
+				file = fmt.Sprintf("%s%d.gpx", exportOutput, i+1)
+			}
+			err := saveGpx(file, log)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Saved %d tracks and %d waypoints to %s\n", len(log.Tracks), len(log.WayPoints), file)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVarP(&exportDevice, "device", "d", exportDevice, "Built-in device profile, e.g. garmin-legacy")
+	exportCmd.Flags().StringVarP(&exportProfile, "profile", "p", exportProfile, "Device profile in JSON")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", exportOutput, "Prefix of output GPX files, which are numbered if more than one, instead of stdout")
+	exportCmd.Flags().BoolVarP(&exportList, "list", "l", exportList, "List the built-in device profiles")
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	filterDrop     []string
+	filterKeep     []string
+	filterTimezone = "UTC"
+)
+
+// filterCmd represents the filter command
+var filterCmd = &cobra.Command{
+	Use:   "filter",
+	Args:  cobra.NoArgs,
+	Short: "Remove track points by expressions",
+	Long: `Remove track points by expressions.
+
+Points matching any --drop expression or failing any --keep expression are
+removed. Expressions are evaluated on the original points, with the following
+variables of each point and the line from its previous point:
+  index    index of the point in the segment
+  dist     distance in meters
+  dt       duration in seconds
+  speed    speed in m/s
+  grade    elevation delta over distance, e.g. 0.1 for 10%
+  ele      elevation in meters
+  dele     elevation delta in meters
+  time     unix time in seconds
+  hour     hour of the day in the time zone, e.g. 13.5 for 13:30
+  heading  degrees clockwise from north
+
+Variables are 0 for the first point, or if time or elevation is missing.
+
+Examples:
+  # Remove points faster than 5 m/s or steeper than 100%
+  gpxtoolkit filter --file track.gpx --drop 'speed > 5' --drop 'abs(grade) > 1'
+
+  # Keep points recorded between 6:00 and 18:00 in Taipei
+  gpxtoolkit filter --file track.gpx --keep 'hour >= 6 && hour < 18' --timezone Asia/Taipei
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		location, err := time.LoadLocation(filterTimezone)
+		if err != nil {
+			return err
+		}
+		filter := &gpxutil.FilterPoints{
+			Filters:  make([]*gpxutil.PointFilter, 0),
+			Location: location,
+		}
+		for _, expr := range filterDrop {
+			filter.Filters = append(filter.Filters, &gpxutil.PointFilter{Expression: expr})
+		}
+		for _, expr := range filterKeep {
+			filter.Filters = append(filter.Filters, &gpxutil.PointFilter{Expression: expr, Keep: true})
+		}
+		if len(filter.Filters) <= 0 {
+			return fmt.Errorf("no expression to filter points")
+		}
+		for _, f := range filter.Filters {
+			if err := f.Validate(); err != nil {
+				return fmt.Errorf("invalid expression '%s': %w", f.Expression, err)
+			}
+		}
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		n, err := filter.Run(trackLog)
+		if err != nil {
+			return err
+		}
+		for _, f := range filter.Filters {
+			if f.Keep {
+				fmt.Fprintf(os.Stderr, "Removed %d points not matching '%s'\n", f.Removed, f.Expression)
+			} else {
+				fmt.Fprintf(os.Stderr, "Removed %d points matching '%s'\n", f.Removed, f.Expression)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Removed %d points\n", n)
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(filterCmd)
+	filterCmd.Flags().StringArrayVarP(&filterDrop, "drop", "d", filterDrop, "Expression of points to remove")
+	filterCmd.Flags().StringArrayVarP(&filterKeep, "keep", "k", filterKeep, "Expression of points to keep")
+	filterCmd.Flags().StringVarP(&filterTimezone, "timezone", "z", filterTimezone, "Time zone of the hour variable, e.g. Asia/Taipei or Local")
+}
@@ -14,7 +14,8 @@ import (
 )
 
 var (
-	gpx2csvTWD97 = false
+	gpx2csvTWD97       = false
+	gpx2csvGradeWindow = 50.0
 )
 
 // gpx2csvCmd represents the gpx2csv command
@@ -59,6 +60,7 @@ func convertTracksToCSV(tracks []*gpx.Track, service elevation.Service) error {
 		"Vertical Speed (m/H)",
 		"KmE/H",
 		"EpH",
+		"Grade (%)",
 	}
 	if service != nil {
 		headers = append(headers,
@@ -94,6 +96,11 @@ func convertTracksToCSV(tracks []*gpx.Track, service elevation.Service) error {
 					return err
 				}
 			}
+			// grade is left blank if any point is missing elevation
+			grades, err := gpxutil.Grades(s.Points, gpx2csvGradeWindow)
+			if err != nil {
+				grades = nil
+			}
 			mileage := 0.0
 			duration := time.Duration(0)
 			for k, p := range s.Points {
@@ -146,6 +153,10 @@ func convertTracksToCSV(tracks []*gpx.Track, service elevation.Service) error {
 					duration += dt
 				}
 				mileage += dist
+				grade := ""
+				if k > 0 && grades != nil {
+					grade = fmt.Sprintf("%f", grades[k-1].Grade*100)
+				}
 				values := []string{
 					t.GetName(),
 					fmt.Sprintf("%d", i),
@@ -167,6 +178,7 @@ func convertTracksToCSV(tracks []*gpx.Track, service elevation.Service) error {
 					fmt.Sprintf("%f", vspeed),
 					fmt.Sprintf("%f", KmEpH),
 					fmt.Sprintf("%f", EpH),
+					grade,
 				}
 				if service != nil {
 					values = append(values,
@@ -198,6 +210,7 @@ func convertTracksToCSV(tracks []*gpx.Track, service elevation.Service) error {
 func init() {
 	rootCmd.AddCommand(gpx2csvCmd)
 	gpx2csvCmd.Flags().BoolVarP(&gpx2csvTWD97, "twd97", "", gpx2csvTWD97, "Add TWD97 coordinates")
+	gpx2csvCmd.Flags().Float64Var(&gpx2csvGradeWindow, "grade-window", gpx2csvGradeWindow, "Horizontal window in meters for smoothing the grade")
 }
 
 func durationToHMSs(d time.Duration) string {
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
+	"math"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	gradeWindow           = 50.0
+	gradeBuckets          = []float64{5, 10, 15, 25, 35}
+	gradeSections         = []float64{100, 500, 1000}
+	gradeByTracks         = false
+	gradeCorrectElevation = false
+)
+
+// gradeCmd represents the grade command
+var gradeCmd = &cobra.Command{
+	Use:   "grade",
+	Args:  cobra.NoArgs,
+	Short: "Analyze the grade (slope) profile of GPX tracks",
+	Long: `Analyze the grade (slope) profile of GPX tracks.
+
+Outputs a histogram of distance by absolute grade and the steepest sustained
+sections of the given lengths with their locations.
+
+Examples:
+  # Histogram of 0-15%, 15-25%, 25-35% and over 35%
+  gpxtoolkit grade --file track.gpx --buckets 15,25,35
+
+  # Steepest 200 meters of each track
+  gpxtoolkit grade --file track.gpx --tracks --sections 200
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		if gradeCorrectElevation {
+			elev := &gpxutil.CorrectElevation{
+				Waypoints: false,
+				Service:   getElevationService(),
+			}
+			if elev.Service == nil {
+				return fmt.Errorf("no elevation service")
+			}
+			_, err = elev.Run(trackLog)
+			if err != nil {
+				return err
+			}
+		}
+		if gradeByTracks {
+			for i, t := range trackLog.Tracks {
+				err := printGrade(fmt.Sprintf("Track %d: %s", i, t.GetName()), []*gpx.Track{t})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return printGrade("", trackLog.Tracks)
+	},
+}
+
+func printGrade(title string, tracks []*gpx.Track) error {
+	bounds := make([]float64, len(gradeBuckets))
+	for i, b := range gradeBuckets {
+		bounds[i] = b / 100
+	}
+	histogram := gpxutil.NewGradeHistogram(bounds)
+	steepest := make([]*gpxutil.GradeSection, len(gradeSections))
+	offset := 0.0
+	for _, t := range tracks {
+		for _, s := range t.Segments {
+			lines, err := gpxutil.Grades(s.Points, gradeWindow)
+			if err != nil {
+				return err
+			}
+			histogram.Add(lines)
+			for i, length := range gradeSections {
+				section, err := gpxutil.SteepestSection(s.Points, length)
+				if err != nil {
+					return err
+				}
+				if section == nil {
+					continue
+				}
+				if steepest[i] == nil || math.Abs(section.Grade) > math.Abs(steepest[i].Grade) {
+					section.StartChainage += offset
+					section.EndChainage += offset
+					steepest[i] = section
+				}
+			}
+			offset += segmentLength(s)
+		}
+	}
+	if title != "" {
+		fmt.Fprintf(os.Stdout, "=== %s ===\n", title)
+	}
+	fmt.Fprintf(os.Stdout, "Distance: %v meter\n", math.Round(histogram.Distance))
+	for _, b := range histogram.Buckets {
+		ratio := 0.0
+		if histogram.Distance > 0 {
+			ratio = b.Distance / histogram.Distance * 100
+		}
+		if math.IsInf(b.Max, 1) {
+			fmt.Fprintf(os.Stdout, "Grade %3.0f%% -     : %8.0f meter (%5.1f%%)\n", b.Min*100, b.Distance, ratio)
+		} else {
+			fmt.Fprintf(os.Stdout, "Grade %3.0f%% - %3.0f%%: %8.0f meter (%5.1f%%)\n", b.Min*100, b.Max*100, b.Distance, ratio)
+		}
+	}
+	for i, length := range gradeSections {
+		section := steepest[i]
+		if section == nil {
+			fmt.Fprintf(os.Stdout, "Steepest %.0fm: N/A\n", length)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "Steepest %.0fm: %+.1f%% (%+.0f meter) from %.0fm (%f,%f) to %.0fm (%f,%f)\n",
+			length, section.Grade*100, section.Gain,
+			section.StartChainage, section.Start.GetLatitude(), section.Start.GetLongitude(),
+			section.EndChainage, section.End.GetLatitude(), section.End.GetLongitude())
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(gradeCmd)
+	gradeCmd.Flags().Float64VarP(&gradeWindow, "window", "w", gradeWindow, "Horizontal window in meters for smoothing the grade")
+	gradeCmd.Flags().Float64SliceVarP(&gradeBuckets, "buckets", "b", gradeBuckets, "Bounds of grade buckets in percent")
+	gradeCmd.Flags().Float64SliceVarP(&gradeSections, "sections", "s", gradeSections, "Lengths in meters of the steepest sustained sections")
+	gradeCmd.Flags().BoolVarP(&gradeByTracks, "tracks", "t", gradeByTracks, "Calculate track by track")
+	gradeCmd.Flags().BoolVarP(&gradeCorrectElevation, "correct-elevation", "e", gradeCorrectElevation, "Correct elevation before calculation")
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	joinThreshold = 50.0
+	joinInterval  = 0.0
+	joinSnap      = false
+	joinClear     = false
+)
+
+// joinCmd represents the join command
+var joinCmd = &cobra.Command{
+	Use:   "join",
+	Args:  cobra.NoArgs,
+	Short: "Join GPX tracks into a continuous route by endpoint proximity",
+	Long: `Join GPX tracks into a continuous route by endpoint proximity.
+
+All the segments of all the tracks of the GPX files are ordered by the
+proximity of their endpoints, and reversed when needed, starting from the
+most isolated endpoint. They are stitched into a single track, where the gaps
+within the threshold are bridged, with points interpolated at most every
+interval if it is positive, or snapped to their middle points with --snap.
+Larger gaps are reported and start new segments. Waypoints are kept.
+Duplicate points where segments meet are dropped. Segments with time are
+never reversed, as their time would run backwards, and they are ordered by
+time if all the segments have time. As a last resort, --clear-times orders
+them by endpoint proximity too and clears the times of the reversed ones.
+
+Examples:
+  # Join disordered pieces digitised by different people
+  gpxtoolkit join --file part1.gpx --file part2.gpx --file part3.gpx --threshold 30
+
+  # Snap the small gaps instead of bridging them
+  gpxtoolkit join --file pieces.gpx --snap
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logs, err := loadTrackLogs()
+		if err != nil {
+			return err
+		}
+		trackLog := &gpx.TrackLog{}
+		for _, log := range logs {
+			trackLog.WayPoints = append(trackLog.WayPoints, log.WayPoints...)
+			trackLog.Tracks = append(trackLog.Tracks, log.Tracks...)
+		}
+		join := &gpxutil.JoinTracks{
+			Threshold:  joinThreshold,
+			Interval:   joinInterval,
+			Snap:       joinSnap,
+			ClearTimes: joinClear,
+		}
+		n, err := join.Run(trackLog)
+		if err != nil {
+			return err
+		}
+		for _, g := range join.Gaps {
+			fmt.Fprintf(os.Stderr, "Gap of %.0fm: (%f, %f) - (%f, %f)\n", g.Distance,
+				g.From.GetLatitude(), g.From.GetLongitude(), g.To.GetLatitude(), g.To.GetLongitude())
+		}
+		if join.Untimed > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: cleared times of %d reversed segments\n", join.Untimed)
+		}
+		fmt.Fprintf(os.Stderr, "Joined %d segments with %d gaps\n", n, len(join.Gaps))
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(joinCmd)
+	joinCmd.Flags().Float64VarP(&joinThreshold, "threshold", "t", joinThreshold, "Max gap in meters to be stitched")
+	joinCmd.Flags().Float64VarP(&joinInterval, "interval", "i", joinInterval, "Max interval in meters of points interpolated in gaps; 0 for none")
+	joinCmd.Flags().BoolVarP(&joinSnap, "snap", "s", joinSnap, "Snap gaps to their middle points instead of bridging them")
+	joinCmd.Flags().BoolVar(&joinClear, "clear-times", joinClear, "Order segments with time by endpoint proximity too, clearing the times of reversed ones")
+}
@@ -1,21 +1,73 @@
 package cmd
 
 import (
+	"fmt"
 	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	mergeDedup            = false
+	mergeWindow           = time.Minute
+	mergeMaxOffset        = time.Duration(0)
+	mergeWaypointDistance = 50.0
+)
+
 // mergeCmd represents the merge command
 var mergeCmd = &cobra.Command{
 	Use:   "merge",
 	Args:  cobra.NoArgs,
 	Short: "Merge multiple GPX track logs as a single one",
+	Long: `Merge multiple GPX track logs as a single one.
+
+By default, the waypoints and tracks of all the GPX files are concatenated.
+
+With --dedup, the GPX files are taken as recordings of the same trip by
+several devices. Their clocks are aligned to the first one within the max
+offset if it is positive. For each time window, the points of the recording of
+the least estimated error by HDOP, jitter and gaps are picked into a single
+track, with their source file names in their extensions. Waypoints of similar
+names within the waypoint distance are merged.
+
+Examples:
+  # Merge recordings of a phone and a watch, whose clock may be 2 minutes off
+  gpxtoolkit merge --file phone.gpx --file watch.gpx --dedup --max-offset 2m
+`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		trackLogs, err := loadTrackLogs()
 		if err != nil {
 			return err
 		}
+		if mergeDedup {
+			sources := make([]string, len(files))
+			for i, file := range files {
+				sources[i] = filepath.Base(file)
+			}
+			merge := &gpxutil.MergeRecordings{
+				Sources:          sources,
+				Window:           mergeWindow,
+				MaxOffset:        mergeMaxOffset,
+				WaypointDistance: mergeWaypointDistance,
+			}
+			merged, err := merge.Run(trackLogs)
+			if err != nil {
+				return err
+			}
+			if merge.Untimed > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: dropped %d points without time\n", merge.Untimed)
+			}
+			for i, offset := range merge.Offsets {
+				if offset != 0 {
+					fmt.Fprintf(os.Stderr, "Clock offset of recording %d: %v\n", i, offset)
+				}
+			}
+			return dumpGpx(merged)
+		}
 		merged := &gpx.TrackLog{}
 		for _, trackLog := range trackLogs {
 			merged.WayPoints = append(merged.WayPoints, trackLog.WayPoints...)
@@ -27,4 +79,8 @@ var mergeCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(mergeCmd)
+	mergeCmd.Flags().BoolVar(&mergeDedup, "dedup", mergeDedup, "Merge recordings of the same trip without duplicates")
+	mergeCmd.Flags().DurationVarP(&mergeWindow, "window", "w", mergeWindow, "Time window to pick a recording with --dedup")
+	mergeCmd.Flags().DurationVarP(&mergeMaxOffset, "max-offset", "o", mergeMaxOffset, "Max clock offset to align recordings with --dedup; 0 for none")
+	mergeCmd.Flags().Float64VarP(&mergeWaypointDistance, "waypoint-distance", "d", mergeWaypointDistance, "Max distance in meters to merge waypoints with --dedup")
 }
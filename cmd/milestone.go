@@ -18,6 +18,7 @@ var (
 	milestoneReverse         = false
 	milestoneFits            = false
 	milestoneTerrainDistance = false
+	milestoneGeodesic        = false
 	milestoneFormat          = "gpx"
 )
 
@@ -68,13 +69,13 @@ Examples:
 			Commands: []gpxutil.Command{
 				gpxutil.RemoveDistanceLessThan(0.1),
 				&gpxutil.Milestone{
-					Service:           getElevationService(),
-					Distance:          milestoneDistance,
-					MilestoneName:     name,
-					Reverse:           milestoneReverse,
-					Symbol:            milestoneSymbol,
-					FitWaypoints:      milestoneFits,
-					ByTerrainDistance: milestoneTerrainDistance,
+					Service:       getElevationService(),
+					Distance:      milestoneDistance,
+					MilestoneName: name,
+					Reverse:       milestoneReverse,
+					Symbol:        milestoneSymbol,
+					FitWaypoints:  milestoneFits,
+					DistanceMode:  distanceMode(milestoneTerrainDistance, milestoneGeodesic),
 				},
 			},
 		}
@@ -112,5 +113,7 @@ func init() {
 	milestoneCmd.Flags().BoolVarP(&milestoneReverse, "reverse", "r", milestoneReverse, "Create milestones in reverse order")
 	milestoneCmd.Flags().BoolVar(&milestoneFits, "fits", milestoneFits, "Fit milestones to existing waypoints")
 	milestoneCmd.Flags().BoolVarP(&milestoneTerrainDistance, "terrain-distance", "e", milestoneTerrainDistance, "Use terrain distance instead of 2D distance")
+	milestoneCmd.Flags().BoolVar(&milestoneGeodesic, "geodesic", milestoneGeodesic, "Measure and interpolate along the WGS84 geodesic, e.g. for sparse planned routes")
 	milestoneCmd.Flags().StringVarP(&milestoneFormat, "format", "o", milestoneFormat, "Output format (gpx or csv)")
+	milestoneCmd.MarkFlagsMutuallyExclusive("terrain-distance", "geodesic")
 }
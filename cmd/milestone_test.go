@@ -45,13 +45,13 @@ func TestMilestoneRegression(t *testing.T) {
 		Commands: []gpxutil.Command{
 			gpxutil.RemoveDistanceLessThan(0.1),
 			&gpxutil.Milestone{
-				Service:           nil, // No elevation service for this test
-				Distance:          distance,
-				MilestoneName:     name,
-				Reverse:           false,
-				Symbol:            symbol,
-				FitWaypoints:      false,
-				ByTerrainDistance: false,
+				Service:       nil, // No elevation service for this test
+				Distance:      distance,
+				MilestoneName: name,
+				Reverse:       false,
+				Symbol:        symbol,
+				FitWaypoints:  false,
+				DistanceMode:  gpxutil.HaversinMode,
 			},
 		},
 	}
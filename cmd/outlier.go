@@ -4,9 +4,14 @@ Copyright © 2022 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"encoding/csv"
 	"fmt"
+	"gpxtoolkit/gpx"
 	"gpxtoolkit/gpxutil"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -17,13 +22,43 @@ var (
 	outlierCorrectElevation = true
 	outlierByDistance       = false
 	outlierByEIF            = 0.0
+	outlierEIFFeatures      = []string{"lat", "lon"}
+	outlierEIFTrees         = 100
+	outlierEIFDepth         = 12
+	outlierEIFSampleSize    = 0
+	outlierEIFSeed          = int64(1)
+	outlierEIFDump          = false
+	outlierHampel           = 0
+	outlierHampelThreshold  = 3.0
+	outlierHampelMin        = 20.0
+	outlierSpike            = false
+	outlierMaxAcceleration  = gpxutil.DefaultMaxAcceleration
+	outlierMaxTurnAngle     = gpxutil.DefaultMaxTurnAngle
+	outlierMaxSpeed         = 0.0
+	outlierActivity         = ""
+	outlierReport           = false
 )
 
 // outlierCmd represents the outlier command
 var outlierCmd = &cobra.Command{
 	Use:   "outlier",
 	Short: "Remove outliers in GPX by sigma (standard deviation)",
-	Long:  `Remove outliers in GPX by sigma (standard deviation)`,
+	Long: fmt.Sprintf(`Remove outliers in GPX by sigma (standard deviation).
+
+Robust detectors can be used instead, which are not fooled by a single huge
+jump inflating the standard deviation:
+  --hampel N                   rolling Hampel filter over N points on each side
+  --spike                      spike-and-return glitches by acceleration and turn angle
+  --max-speed or --activity    speed from the previous accepted point
+
+Max speeds of activities in m/s:
+  %s
+The activity of a track is its type if known, or the value of --activity.
+
+Examples:
+  # Remove outliers by Hampel filter and spikes, reporting removed points
+  gpxtoolkit outlier --file track.gpx --hampel 5 --spike --report
+`, activitySpeeds()),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		trackLog, err := loadGpx()
 		if err != nil {
@@ -37,7 +72,7 @@ var outlierCmd = &cobra.Command{
 			}
 			fmt.Fprintf(os.Stderr, "Removed %d duplications\n", n)
 		}
-		if effortCorrectElevation {
+		if outlierCorrectElevation {
 			elev := &gpxutil.CorrectElevation{
 				Waypoints: false,
 				Service:   getElevationService(),
@@ -50,9 +85,55 @@ var outlierCmd = &cobra.Command{
 				return err
 			}
 		}
-		if outlierByEIF > 0 {
+		detectors := make([]gpxutil.OutlierDetector, 0)
+		if outlierHampel > 0 {
+			detectors = append(detectors, &gpxutil.HampelDetector{
+				Window:       outlierHampel,
+				Threshold:    outlierHampelThreshold,
+				MinDeviation: outlierHampelMin,
+			})
+		}
+		if outlierSpike {
+			detectors = append(detectors, &gpxutil.SpikeDetector{
+				MaxAcceleration: outlierMaxAcceleration,
+				MaxTurnAngle:    outlierMaxTurnAngle,
+			})
+		}
+		if outlierMaxSpeed > 0 || outlierActivity != "" {
+			if outlierActivity != "" && gpxutil.ActivitySpeeds[outlierActivity] <= 0 {
+				return fmt.Errorf("unknown activity: %s", outlierActivity)
+			}
+			detectors = append(detectors, &gpxutil.MaxSpeedDetector{
+				MaxSpeed: outlierMaxSpeed,
+				Activity: outlierActivity,
+			})
+		}
+		if len(detectors) > 0 {
+			outlier := &gpxutil.RemoveOutliers{
+				Detectors: detectors,
+			}
+			if outlierReport {
+				outlier.Report = reportOutlier
+			}
+			n, err := outlier.Run(trackLog)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Removed %d outliers\n", n)
+		} else if outlierByEIF > 0 || outlierEIFDump {
 			outlier := &gpxutil.RemoveOutlierByEIF{
-				Threshold: outlierByEIF,
+				Threshold:  outlierByEIF,
+				Features:   outlierEIFFeatures,
+				Trees:      outlierEIFTrees,
+				Depth:      outlierEIFDepth,
+				SampleSize: outlierEIFSampleSize,
+				Seed:       outlierEIFSeed,
+			}
+			if outlierEIFDump {
+				return dumpEIFScores(trackLog, outlier)
+			}
+			if outlierReport {
+				outlier.Report = reportOutlier
 			}
 			n, err := outlier.Run(trackLog)
 			if err != nil {
@@ -66,6 +147,9 @@ var outlierCmd = &cobra.Command{
 			} else {
 				outlier = gpxutil.RemoveOutlierBySpeed(outlierSigma)
 			}
+			if outlierReport {
+				outlier.Report = reportOutlier
+			}
 			n, err := outlier.Run(trackLog)
 			if err != nil {
 				return err
@@ -83,4 +167,83 @@ func init() {
 	outlierCmd.Flags().BoolVarP(&outlierCorrectElevation, "correct-elevation", "e", outlierCorrectElevation, "Correct elevation before calculation")
 	outlierCmd.Flags().BoolVarP(&outlierByDistance, "distance", "D", outlierByDistance, "Calculate by distance instead of speed")
 	outlierCmd.Flags().Float64Var(&outlierByEIF, "eif", outlierByEIF, "Remove outlier by EIF")
+	outlierCmd.Flags().StringSliceVar(&outlierEIFFeatures, "eif-features", outlierEIFFeatures, fmt.Sprintf("Features of EIF: %s", strings.Join(gpxutil.EIFFeatures, ", ")))
+	outlierCmd.Flags().IntVar(&outlierEIFTrees, "eif-trees", outlierEIFTrees, "Number of trees of EIF")
+	outlierCmd.Flags().IntVar(&outlierEIFDepth, "eif-depth", outlierEIFDepth, "Max depth of trees of EIF")
+	outlierCmd.Flags().IntVar(&outlierEIFSampleSize, "eif-sample", outlierEIFSampleSize, "Number of points to sample for each tree of EIF; 0 for all points")
+	outlierCmd.Flags().Int64Var(&outlierEIFSeed, "eif-seed", outlierEIFSeed, "Random seed of EIF")
+	outlierCmd.Flags().BoolVar(&outlierEIFDump, "eif-dump", outlierEIFDump, "Output EIF scores and features of points as CSV instead of GPX")
+	outlierCmd.Flags().IntVar(&outlierHampel, "hampel", outlierHampel, "Number of neighbors on each side of Hampel filter; 0 to disable")
+	outlierCmd.Flags().Float64Var(&outlierHampelThreshold, "hampel-threshold", outlierHampelThreshold, "Threshold of Hampel filter in scaled MAD")
+	outlierCmd.Flags().Float64Var(&outlierHampelMin, "hampel-min", outlierHampelMin, "Min deviation in meters of Hampel filter")
+	outlierCmd.Flags().BoolVar(&outlierSpike, "spike", outlierSpike, "Remove spike-and-return glitches")
+	outlierCmd.Flags().Float64Var(&outlierMaxAcceleration, "max-acceleration", outlierMaxAcceleration, "Max plausible acceleration in m/s² for spikes")
+	outlierCmd.Flags().Float64Var(&outlierMaxTurnAngle, "max-turn", outlierMaxTurnAngle, "Max plausible turn angle in degrees for spikes")
+	outlierCmd.Flags().Float64Var(&outlierMaxSpeed, "max-speed", outlierMaxSpeed, "Max plausible speed in m/s; 0 to decide by activity")
+	outlierCmd.Flags().StringVar(&outlierActivity, "activity", outlierActivity, "Activity for the max plausible speed if the track type is unknown")
+	outlierCmd.Flags().BoolVarP(&outlierReport, "report", "r", outlierReport, "Report every removed point with the reason")
+}
+
+func activitySpeeds() string {
+	activities := make([]string, 0, len(gpxutil.ActivitySpeeds))
+	for activity := range gpxutil.ActivitySpeeds {
+		activities = append(activities, activity)
+	}
+	sort.Strings(activities)
+	for i, activity := range activities {
+		activities[i] = fmt.Sprintf("%s %.0f", activity, gpxutil.ActivitySpeeds[activity])
+	}
+	return strings.Join(activities, ", ")
+}
+
+// reportOutlier prints the removed point with its reason.
+func reportOutlier(o *gpxutil.Outlier) {
+	t := "-"
+	if o.Point.NanoTime != nil {
+		t = o.Point.Time().Format(time.RFC3339)
+	}
+	fmt.Fprintf(os.Stderr, "Track %d segment %d point %d (%f,%f) %s: %s\n",
+		o.Track, o.Segment, o.Index, o.Point.GetLatitude(), o.Point.GetLongitude(), t, o.Reason)
+}
+
+func dumpEIFScores(trackLog *gpx.TrackLog, outlier *gpxutil.RemoveOutlierByEIF) error {
+	w := csv.NewWriter(os.Stdout)
+	headers := []string{"Track Index", "Segment Index", "Point Index", "Time (UTC)", "Latitude", "Longitude"}
+	headers = append(headers, outlier.Features...)
+	headers = append(headers, "Score")
+	if err := w.Write(headers); err != nil {
+		return err
+	}
+	var err error
+	outlier.Threshold = 0
+	outlier.Score = func(score *gpxutil.EIFScore) {
+		if err != nil {
+			return
+		}
+		p := score.Point
+		record := []string{
+			fmt.Sprintf("%d", score.Track),
+			fmt.Sprintf("%d", score.Segment),
+			fmt.Sprintf("%d", score.Index),
+			"",
+			fmt.Sprintf("%f", p.GetLatitude()),
+			fmt.Sprintf("%f", p.GetLongitude()),
+		}
+		if p.NanoTime != nil {
+			record[3] = p.Time().Format(time.RFC3339)
+		}
+		for _, v := range score.Features {
+			record = append(record, fmt.Sprintf("%f", v))
+		}
+		record = append(record, fmt.Sprintf("%f", score.Score))
+		err = w.Write(record)
+	}
+	if _, e := outlier.Run(trackLog); e != nil {
+		return e
+	}
+	if err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
 }
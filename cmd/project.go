@@ -14,6 +14,7 @@ import (
 var (
 	projectThreshold    float64 = 30
 	projectKeepOriginal bool
+	projectGeodesic     bool
 )
 
 // projectCmd represents the project command
@@ -27,7 +28,7 @@ var projectCmd = &cobra.Command{
 			return err
 		}
 		project := &gpxutil.ProjectWaypoints{
-			DistanceFunc: gpxutil.HaversinDistance,
+			DistanceMode: distanceMode(false, projectGeodesic),
 			Threshold:    projectThreshold,
 			KeepOriginal: projectKeepOriginal,
 		}
@@ -44,4 +45,5 @@ func init() {
 	rootCmd.AddCommand(projectCmd)
 	projectCmd.Flags().BoolVarP(&projectKeepOriginal, "keep", "k", projectKeepOriginal, "Keep the original waypoints")
 	projectCmd.Flags().Float64VarP(&projectThreshold, "threshold", "t", projectThreshold, "Distance threshold of waypoints. Waypoints farer than this threshold won't be used for projection.")
+	projectCmd.Flags().BoolVar(&projectGeodesic, "geodesic", projectGeodesic, "Project onto the WGS84 geodesic of track lines, e.g. for sparse planned routes")
 }
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	resampleInterval = 10 * time.Second
+	resampleMinSpeed = 0.0
+)
+
+// resampleCmd represents the resample command
+var resampleCmd = &cobra.Command{
+	Use:   "resample",
+	Args:  cobra.NoArgs,
+	Short: "Resample GPX tracks at a fixed time interval",
+	Long: `Resample GPX tracks at a fixed time interval.
+
+Replaces the points of each segment by exactly one point per interval from the
+start of the segment, with position and elevation linearly interpolated. Points
+without time are dropped. With --min-speed, samples while moving slower than
+the speed, i.e. during stops, are dropped as well.
+
+Examples:
+  # One point per 5 seconds
+  gpxtoolkit resample --file track.gpx --interval 5s
+
+  # One point per minute, dropping samples slower than 0.2 m/s
+  gpxtoolkit resample --file track.gpx --interval 1m --min-speed 0.2
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		resample := &gpxutil.ResampleByTime{
+			Interval: resampleInterval,
+			MinSpeed: resampleMinSpeed,
+		}
+		n, err := resample.Run(trackLog)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Resampled to %d points\n", n)
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resampleCmd)
+	resampleCmd.Flags().DurationVarP(&resampleInterval, "interval", "i", resampleInterval, "Time interval between points")
+	resampleCmd.Flags().Float64VarP(&resampleMinSpeed, "min-speed", "s", resampleMinSpeed, "Drop samples slower than this speed in m/s")
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	resegmentGap       = 5 * time.Minute
+	resegmentJump      = 500.0
+	resegmentSpeed     = 0.0
+	resegmentMinPoints = 0
+	resegmentMinLength = 0.0
+)
+
+// resegmentCmd represents the resegment command
+var resegmentCmd = &cobra.Command{
+	Use:   "resegment",
+	Args:  cobra.NoArgs,
+	Short: "Split track segments at time gaps and position jumps",
+	Long: `Split track segments at time gaps and position jumps.
+
+Starts a new segment wherever the time gap, distance jump or implied speed from
+the previous point exceeds the threshold, so that the gap is not drawn as a
+straight line, nor counted in distance and milestones. A threshold of 0 is
+disabled. Tiny segments left over can be dropped by --min-points and
+--min-length.
+
+Examples:
+  # Split at gaps over 10 minutes or jumps over 200 meters
+  gpxtoolkit resegment --file track.gpx --gap 10m --jump 200
+
+  # Split at implied speed over 30 m/s and drop segments under 10 points
+  gpxtoolkit resegment --file track.gpx --gap 0 --jump 0 --speed 30 --min-points 10
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		resegment := &gpxutil.ReSegmentByGaps{
+			MaxDuration: resegmentGap,
+			MaxDistance: resegmentJump,
+			MaxSpeed:    resegmentSpeed,
+		}
+		n, err := resegment.Run(trackLog)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Split %d segments\n", n)
+		if resegmentMinPoints > 0 || resegmentMinLength > 0 {
+			tiny := &gpxutil.RemoveTinySegments{
+				MinPoints: resegmentMinPoints,
+				MinLength: resegmentMinLength,
+			}
+			n, err := tiny.Run(trackLog)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Removed %d tiny segments\n", n)
+		}
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resegmentCmd)
+	resegmentCmd.Flags().DurationVarP(&resegmentGap, "gap", "g", resegmentGap, "Max time gap between points")
+	resegmentCmd.Flags().Float64VarP(&resegmentJump, "jump", "j", resegmentJump, "Max distance in meters between points")
+	resegmentCmd.Flags().Float64VarP(&resegmentSpeed, "speed", "s", resegmentSpeed, "Max implied speed in m/s between points")
+	resegmentCmd.Flags().IntVar(&resegmentMinPoints, "min-points", resegmentMinPoints, "Remove segments with fewer points")
+	resegmentCmd.Flags().Float64Var(&resegmentMinLength, "min-length", resegmentMinLength, "Remove segments shorter in meters")
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"gpxtoolkit/elevation"
 	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
 	"gpxtoolkit/log"
+	"io"
 	"net/http"
 	"os"
 
@@ -63,25 +65,53 @@ func loadTrackLogs() ([]*gpx.TrackLog, error) {
 		logs = append(logs, log)
 	} else {
 		for _, file := range files {
-			f, err := os.Open(file)
+			log, err := openGpx(file)
 			if err != nil {
 				return nil, err
 			}
-			log, err := parser.Parse(f)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to parse GPX from '%s': %s\n", file, err.Error())
-				return nil, err
-			}
 			logs = append(logs, log)
 		}
 	}
 	return logs, nil
 }
 
+func openGpx(file string) (*gpx.TrackLog, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	parser := &gpx.Parser{}
+	log, err := parser.Parse(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse GPX from '%s': %s\n", file, err.Error())
+		return nil, err
+	}
+	return log, nil
+}
+
 func dumpGpx(gpxLog *gpx.TrackLog) error {
+	return writeGpx(os.Stdout, gpxLog)
+}
+
+func saveGpx(file string, gpxLog *gpx.TrackLog) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = writeGpx(f, gpxLog)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	// the error of closing is the last chance to know the writes failed
+	return f.Close()
+}
+
+func writeGpx(w io.Writer, gpxLog *gpx.TrackLog) error {
 	writer := &gpx.Writer{
 		Creator: rootCmd.Use,
-		Writer:  os.Stdout,
+		Writer:  w,
 	}
 	if keepCreator && gpxLog.Creator != nil {
 		writer.Creator = *gpxLog.Creator
@@ -94,6 +124,18 @@ func dumpGpx(gpxLog *gpx.TrackLog) error {
 	return nil
 }
 
+// distanceMode returns the distance mode of the flags, which should be marked
+// as mutually exclusive by the command.
+func distanceMode(terrain, geodesic bool) gpxutil.DistanceMode {
+	if terrain {
+		return gpxutil.TerrainMode
+	}
+	if geodesic {
+		return gpxutil.GeodesicMode
+	}
+	return gpxutil.HaversinMode
+}
+
 func getElevationService() elevation.Service {
 	if googleElevationAPIKey == "" {
 		googleElevationAPIKey = os.Getenv("GOOGLE_ELEVATION_API_KEY")
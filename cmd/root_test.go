@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExclusiveDistanceFlags(t *testing.T) {
+	defer func() {
+		milestoneTerrainDistance = false
+		milestoneGeodesic = false
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	}()
+	rootCmd.SetArgs([]string{"milestone", "--terrain-distance", "--geodesic"})
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "geodesic") {
+		t.Fatalf("Expected error of both terrain and geodesic distances, got %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"gpxtoolkit/gpxutil"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -14,22 +15,44 @@ import (
 var (
 	simpleEpsilon = 10.0
 	simpleFirst   = true
+	simple3D      = false
+	simpleMethod  = "rdp"
+	simpleMax     = 0
 )
 
 // simpleCmd represents the simple command
 var simpleCmd = &cobra.Command{
 	Use:   "simple",
 	Short: "Simplify GPX points",
-	Long:  `Simplify GPX points.`,
+	Long: `Simplify GPX points.
+
+Methods are rdp (Ramer–Douglas–Peucker), which keeps points farther than
+epsilon meters from the simplified line, and vw (Visvalingam–Whyatt), which
+keeps points whose triangle areas with their neighbors are over epsilon²
+square meters. With --max-points, tracks still having more points are
+simplified to exactly that many points, e.g. for GPS units accepting at most
+500 points per track. It fails for tracks of more segments than half of that,
+which can not keep the end points of every segment.
+
+Examples:
+  # Simplify with epsilon 5 meters
+  gpxtoolkit simple --file track.gpx --epsilon 5
+
+  # Simplify to 500 points per track by Visvalingam–Whyatt
+  gpxtoolkit simple --file track.gpx --method vw --epsilon 0 --max-points 500
+`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		trackLog, err := loadGpx()
 		if err != nil {
 			return err
 		}
 		simplify := &gpxutil.Simplify{
-			Epsilon: simpleEpsilon,
-			First:   simpleFirst,
-			Service: getElevationService(),
+			Epsilon:   simpleEpsilon,
+			First:     simpleFirst,
+			Elevation: simple3D,
+			Method:    simpleMethod,
+			MaxPoints: simpleMax,
+			Service:   getElevationService(),
 		}
 		n, err := simplify.Run(trackLog)
 		if err != nil {
@@ -44,4 +67,7 @@ func init() {
 	rootCmd.AddCommand(simpleCmd)
 	simpleCmd.Flags().BoolVarP(&simpleFirst, "first", "F", simpleFirst, "Simplify the first point")
 	simpleCmd.Flags().Float64VarP(&simpleEpsilon, "epsilon", "e", simpleEpsilon, "Epsilon (distance) for simplification")
+	simpleCmd.Flags().StringVarP(&simpleMethod, "method", "m", simpleMethod, fmt.Sprintf("Simplification method: %s", strings.Join(gpxutil.SimplifyMethods, ", ")))
+	simpleCmd.Flags().IntVarP(&simpleMax, "max-points", "n", simpleMax, "Max number of points per track")
+	simpleCmd.Flags().BoolVar(&simple3D, "3d", simple3D, "Include elevation in the distance for simplification")
 }
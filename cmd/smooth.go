@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpxutil"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	smoothProcessNoise     = 0.5
+	smoothMeasurementNoise = 5.0
+	smoothDeduplicate      = true
+)
+
+// smoothCmd represents the smooth command
+var smoothCmd = &cobra.Command{
+	Use:   "smooth",
+	Args:  cobra.NoArgs,
+	Short: "Smooth GPS jitter of track points by Kalman filter",
+	Long: `Smooth GPS jitter of track points by Kalman filter.
+
+Corrects the positions of track points by a constant-velocity Kalman filter
+with a Rauch–Tung–Striebel backward pass over each segment. Time of points is
+used when present, otherwise points are regarded as evenly spaced. The
+measurement noise is scaled by the HDOP of points if available.
+
+Lower process noise or higher measurement noise gives smoother tracks.
+
+Examples:
+  # Smooth a track recorded under forest canopy
+  gpxtoolkit smooth --file track.gpx --measurement-noise 10
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		if smoothDeduplicate {
+			dedup := gpxutil.RemoveDuplicated()
+			n, err := dedup.Run(trackLog)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Removed %d duplications\n", n)
+		}
+		smooth := &gpxutil.Smooth{
+			ProcessNoise:     smoothProcessNoise,
+			MeasurementNoise: smoothMeasurementNoise,
+		}
+		n, err := smooth.Run(trackLog)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Smoothed %d points\n", n)
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(smoothCmd)
+	smoothCmd.Flags().Float64VarP(&smoothProcessNoise, "process-noise", "q", smoothProcessNoise, "Standard deviation of acceleration in m/s²")
+	smoothCmd.Flags().Float64VarP(&smoothMeasurementNoise, "measurement-noise", "r", smoothMeasurementNoise, "Standard deviation of GPS positions in meters, scaled by HDOP if available")
+	smoothCmd.Flags().BoolVarP(&smoothDeduplicate, "deduplication", "d", smoothDeduplicate, "Remove duplicated points before smoothing")
+}
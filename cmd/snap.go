@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapTrails     []string
+	snapThreshold  = 30.0
+	snapSigma      = 10.0
+	snapBeta       = 10.0
+	snapCandidates = 8
+)
+
+// snapCmd represents the snap command
+var snapCmd = &cobra.Command{
+	Use:   "snap",
+	Args:  cobra.NoArgs,
+	Short: "Snap GPX tracks onto reference trails",
+	Long: `Snap GPX tracks onto reference trails.
+
+Each recorded point is map-matched onto the tracks of the trail GPX files by a
+hidden Markov model: the candidates are its projections onto the trails within
+the threshold, and the most likely sequence of candidates is the one close to
+the recorded points, whose distances along the trails are also close to the
+distances between the recorded points. The snapped tracks follow the trail
+geometry with the original timestamps. Points without any trail within the
+threshold are kept as they are, in separate off-trail segments.
+
+Examples:
+  # Snap a noisy recording onto the surveyed trail
+  gpxtoolkit snap --file track.gpx --trail trail.gpx
+
+  # Snap onto a trail network with a worse GPS fix
+  gpxtoolkit snap --file track.gpx --trail main.gpx --trail branch.gpx --threshold 50 --sigma 20
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(snapTrails) <= 0 {
+			return fmt.Errorf("please specify the trails")
+		}
+		trails := &gpx.TrackLog{}
+		for _, file := range snapTrails {
+			log, err := openGpx(file)
+			if err != nil {
+				return err
+			}
+			trails.Tracks = append(trails.Tracks, log.Tracks...)
+		}
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		snap := &gpxutil.SnapToTrails{
+			Trails:     trails,
+			Threshold:  snapThreshold,
+			Sigma:      snapSigma,
+			Beta:       snapBeta,
+			Candidates: snapCandidates,
+		}
+		n, err := snap.Run(trackLog)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Snapped %d points\n", n)
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapCmd)
+	snapCmd.Flags().StringArrayVarP(&snapTrails, "trail", "r", snapTrails, "GPX file of reference trails; can be specified multiple times")
+	snapCmd.Flags().Float64VarP(&snapThreshold, "threshold", "t", snapThreshold, "Max distance in meters to snap onto trails")
+	snapCmd.Flags().Float64VarP(&snapSigma, "sigma", "s", snapSigma, "Standard deviation of GPS error in meters")
+	snapCmd.Flags().Float64VarP(&snapBeta, "beta", "b", snapBeta, "Scale in meters of differences between distances along trails and between points")
+	snapCmd.Flags().IntVarP(&snapCandidates, "candidates", "n", snapCandidates, "Max number of candidates of each point")
+}
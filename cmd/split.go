@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	splitBy        = "day"
+	splitTimezone  = "Local"
+	splitNights    = 1
+	splitMinStop   = 4 * time.Hour
+	splitRadius    = 200.0
+	splitThreshold = 30.0
+	splitOutput    = ""
+)
+
+// splitCmd represents the split command
+var splitCmd = &cobra.Command{
+	Use:   "split",
+	Args:  cobra.NoArgs,
+	Short: "Split multi-day GPX by day or overnight stops",
+	Long: `Split multi-day GPX by day or overnight stops.
+
+Splits the track points into one track, or one GPX file with --output, for
+each window with points, where windows are:
+  day        local calendar days in the time zone
+  overnight  between the longest stops staying within the radius, up to the
+             number of nights
+
+Tracks are named by the dates. Waypoints with time are kept in their windows,
+and those without time are kept in windows whose tracks pass by them.
+
+Examples:
+  # Split by local day in Taipei
+  gpxtoolkit split --file trek.gpx --timezone Asia/Taipei
+
+  # Split at 3 overnight stops into day1.gpx, day2.gpx, ...
+  gpxtoolkit split --file trek.gpx --by overnight --nights 3 --output day
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		location, err := time.LoadLocation(splitTimezone)
+		if err != nil {
+			return err
+		}
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		var windows []*gpxutil.TimeWindow
+		switch splitBy {
+		case "day":
+			windows = gpxutil.DayWindows(trackLog, location)
+		case "overnight":
+			windows = gpxutil.OvernightWindows(trackLog, splitNights, splitRadius, splitMinStop, location)
+		default:
+			return fmt.Errorf("unknown split: %s", splitBy)
+		}
+		if splitOutput != "" {
+			n := 0
+			for _, w := range windows {
+				cropped := w.Crop(trackLog, splitThreshold)
+				track := &gpx.Track{Name: &w.Name}
+				for _, t := range cropped.Tracks {
+					track.Segments = append(track.Segments, t.Segments...)
+				}
+				if len(track.Segments) <= 0 {
+					// no points as SplitByTime
+					continue
+				}
+				cropped.Tracks = []*gpx.Track{track}
+				n++
+				file := fmt.Sprintf("%s%d.gpx", splitOutput, n)
+				err := saveGpx(file, cropped)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stderr, "Saved %s to %s\n", w.Name, file)
+			}
+			return nil
+		}
+		split := &gpxutil.SplitByTime{
+			Windows:   windows,
+			Threshold: splitThreshold,
+		}
+		n, err := split.Run(trackLog)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Split into %d tracks\n", n)
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(splitCmd)
+	splitCmd.Flags().StringVarP(&splitBy, "by", "b", splitBy, "Split by: day or overnight")
+	splitCmd.Flags().StringVarP(&splitTimezone, "timezone", "z", splitTimezone, "Time zone of days, e.g. Asia/Taipei")
+	splitCmd.Flags().IntVarP(&splitNights, "nights", "n", splitNights, "Max number of overnight stops to split at")
+	splitCmd.Flags().DurationVar(&splitMinStop, "min-stop", splitMinStop, "Min duration of overnight stops")
+	splitCmd.Flags().Float64Var(&splitRadius, "radius", splitRadius, "Radius in meters of overnight stops")
+	splitCmd.Flags().Float64VarP(&splitThreshold, "threshold", "t", splitThreshold, "Distance threshold of waypoints without time. Waypoints farer than this threshold from a window won't be kept in it.")
+	splitCmd.Flags().StringVarP(&splitOutput, "output", "o", splitOutput, "Prefix of output GPX files, which are numbered by windows, instead of stdout")
+}
+
+// parseTime parses the time in RFC 3339, or 'YYYY-MM-DD HH:mm:SS' and
+// 'YYYY-MM-DD' in the location.
+func parseTime(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(value), location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"gpxtoolkit/gpxutil"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	stopsRadius   = 50.0
+	stopsDuration = 10 * time.Minute
+	stopsCollapse = false
+	stopsTimezone = "Local"
+)
+
+// stopsCmd represents the stops command
+var stopsCmd = &cobra.Command{
+	Use:   "stops",
+	Args:  cobra.NoArgs,
+	Short: "Detect stops and add them as waypoints",
+	Long: `Detect stops and add them as waypoints.
+
+A stop is where the track stays within the radius from its first point for at
+least the duration. A waypoint named 'Stop N' is added at the centroid of each
+stop, with the arrival time, departure time and duration in its description.
+With --collapse, the points of each stop are replaced by the centroid, which
+removes the GPS jitter while resting.
+
+Examples:
+  # Find rests longer than 15 minutes within 30 meters
+  gpxtoolkit stops --file track.gpx --radius 30 --duration 15m
+
+  # Collapse stops into single points, in Taipei time
+  gpxtoolkit stops --file track.gpx --collapse --timezone Asia/Taipei
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		location, err := time.LoadLocation(stopsTimezone)
+		if err != nil {
+			return err
+		}
+		trackLog, err := loadGpx()
+		if err != nil {
+			return err
+		}
+		stops := &gpxutil.DetectStops{
+			Radius:   stopsRadius,
+			Duration: stopsDuration,
+			Collapse: stopsCollapse,
+			Location: location,
+		}
+		n, err := stops.Run(trackLog)
+		if err != nil {
+			return err
+		}
+		for i, s := range stops.Stops {
+			fmt.Fprintf(os.Stderr, "Stop %d: %s - %s (%v)\n", i+1,
+				s.Arrival.Time().In(location).Format("2006-01-02 15:04:05"),
+				s.Departure.Time().In(location).Format("2006-01-02 15:04:05"),
+				s.Duration().Round(time.Second))
+		}
+		fmt.Fprintf(os.Stderr, "Found %d stops\n", n)
+		return dumpGpx(trackLog)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stopsCmd)
+	stopsCmd.Flags().Float64VarP(&stopsRadius, "radius", "r", stopsRadius, "Radius in meters to stay within")
+	stopsCmd.Flags().DurationVarP(&stopsDuration, "duration", "d", stopsDuration, "Min duration of stops")
+	stopsCmd.Flags().BoolVarP(&stopsCollapse, "collapse", "c", stopsCollapse, "Replace the points of stops by their centroids")
+	stopsCmd.Flags().StringVarP(&stopsTimezone, "timezone", "z", stopsTimezone, "Time zone of descriptions, e.g. Asia/Taipei")
+}
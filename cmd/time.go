@@ -13,6 +13,10 @@ import (
 var (
 	timeStart       = "(now)"
 	timeSpeed       = 10.0
+	timeModel       = ""
+	timeCalibrate   []string
+	timeWindow      = 50.0
+	timePaceModel   = ""
 	terrainDistance = false
 )
 
@@ -35,12 +39,19 @@ var timeCmd = &cobra.Command{
 			}
 		}
 		time := &gpxutil.ReTimestamp{
-			DistanceFunc: gpxutil.HaversinDistance,
+			DistanceMode: distanceMode(terrainDistance, false),
 			Start:        start,
 			Speed:        timeSpeed,
 		}
-		if terrainDistance {
-			time.DistanceFunc = gpxutil.TerrainDistance
+		if timeModel != "" || len(timeCalibrate) > 0 || timePaceModel != "" {
+			model, err := getPaceModel(timeModel, timeSpeed, timeCalibrate, timePaceModel)
+			if err != nil {
+				return err
+			}
+			time.Pace = &gpxutil.EstimateTime{
+				Pace:   model,
+				Window: timeWindow,
+			}
 		}
 		_, err = time.Run(trackLog)
 		if err != nil {
@@ -54,5 +65,9 @@ func init() {
 	rootCmd.AddCommand(timeCmd)
 	timeCmd.Flags().StringVarP(&timeStart, "start", "S", timeStart, "Start time for deriving the time, in YYYY-MM-DD HH:mm:SS")
 	timeCmd.Flags().Float64VarP(&timeSpeed, "speed", "s", timeSpeed, "Average speed for deriving the time")
+	timeCmd.Flags().StringVarP(&timeModel, "model", "m", timeModel, "Pace model instead of the constant speed; see 'eta' command")
+	timeCmd.Flags().StringArrayVar(&timeCalibrate, "calibrate", timeCalibrate, "Recorded GPX files for calibrating the pace model")
+	timeCmd.Flags().StringVar(&timePaceModel, "pace-model", timePaceModel, "Pace model JSON file saved by the 'calibrate' command")
+	timeCmd.Flags().Float64VarP(&timeWindow, "window", "w", timeWindow, "Horizontal window in meters for smoothing the grade of the pace model")
 	timeCmd.Flags().BoolVarP(&terrainDistance, "terrain", "t", terrainDistance, "Use terrain (3D) distance instead of haversin (2D) distance")
 }
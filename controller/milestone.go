@@ -36,17 +36,21 @@ func (c *MilestoneController) Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	distance := queryGetFloat64(query, "distance", 100)
+	distanceMode := gpxutil.HaversinMode
+	if queryGetBool(query, "terrainDistance", false) {
+		distanceMode = gpxutil.TerrainMode
+	}
 	commands := &gpxutil.ChainedCommands{
 		Commands: []gpxutil.Command{
 			gpxutil.RemoveDistanceLessThan(0.1),
 			&gpxutil.Milestone{
-				Service:           c.Service,
-				Distance:          distance,
-				MilestoneName:     name,
-				Reverse:           queryGetBool(query, "reverse", false),
-				Symbol:            queryGetString(query, "symbol", "Milestone"),
-				FitWaypoints:      queryGetBool(query, "fits", false),
-				ByTerrainDistance: queryGetBool(query, "terrainDistance", false),
+				Service:       c.Service,
+				Distance:      distance,
+				MilestoneName: name,
+				Reverse:       queryGetBool(query, "reverse", false),
+				Symbol:        queryGetString(query, "symbol", "Milestone"),
+				FitWaypoints:  queryGetBool(query, "fits", false),
+				DistanceMode:  distanceMode,
 			},
 		},
 	}
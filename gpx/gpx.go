@@ -5,6 +5,9 @@ import (
 	"os"
 )
 
+// Namespace is the XML namespace of the extensions of gpxtoolkit.
+const Namespace = "https://github.com/outdoorsafetylab/gpxtoolkit"
+
 func Open(file string) (*TrackLog, error) {
 	r, err := os.Open(file)
 	if err != nil {
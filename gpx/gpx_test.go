@@ -301,3 +301,47 @@ func TestGPXCorrect(t *testing.T) {
 	}
 }
 */
+
+func TestExtensions(t *testing.T) {
+	p := &Parser{}
+	log, err := p.Parse(bytes.NewBuffer([]byte(`<gpx xmlns="http://www.topografix.com/GPX/1/1" xmlns:gpxtoolkit="https://github.com/outdoorsafetylab/gpxtoolkit" xmlns:other="https://example.com/other">
+<trk><trkseg>
+<trkpt lat="24" lon="121"><extensions><gpxtoolkit:spread>1.5</gpxtoolkit:spread><gpxtoolkit:confidence>0.8</gpxtoolkit:confidence><gpxtoolkit:source>phone</gpxtoolkit:source></extensions></trkpt>
+<trkpt lat="24" lon="121"><extensions><other:spread>wide</other:spread><other:confidence>high</other:confidence><other:source>watch</other:source></extensions></trkpt>
+</trkseg></trk>
+</gpx>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := log.Tracks[0].Segments[0].Points
+	if points[0].GetSpread() != 1.5 || points[0].GetConfidence() != 0.8 || points[0].GetSource() != "phone" {
+		t.Fatalf("Unexpected extensions: %v", points[0])
+	}
+	if points[1].Spread != nil || points[1].Confidence != nil || points[1].Source != nil {
+		t.Fatalf("Unexpected extensions of other namespace: %v", points[1])
+	}
+	var buf bytes.Buffer
+	if err := (&Writer{Writer: &buf}).Write(log); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`xmlns:gpxtoolkit=`)) {
+		t.Fatalf("Missing namespace of extensions")
+	}
+	log, err = p.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if points := log.Tracks[0].Segments[0].Points; points[0].GetSpread() != 1.5 || points[0].GetConfidence() != 0.8 || points[0].GetSource() != "phone" {
+		t.Fatalf("Unexpected extensions written: %v", points[0])
+	}
+	log.Tracks[0].Segments[0].Points[0].Spread = nil
+	log.Tracks[0].Segments[0].Points[0].Confidence = nil
+	log.Tracks[0].Segments[0].Points[0].Source = nil
+	buf.Reset()
+	if err := (&Writer{Writer: &buf}).Write(log); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte(`gpxtoolkit`)) {
+		t.Fatalf("Unexpected namespace without extensions")
+	}
+}
@@ -18,7 +18,8 @@ func (p *Parser) Parse(r io.Reader) (*TrackLog, error) {
 	var segment *Segment
 	var pt *Point
 	var wpt *WayPoint
-	err := xml.NewParser().On("//gpx", func(attrs map[string]string) error {
+	parser := xml.NewParser()
+	err := parser.On("//gpx", func(attrs map[string]string) error {
 		log = &TrackLog{Tracks: make([]*Track, 0)}
 		creator := attrs["creator"]
 		if creator != "" {
@@ -101,6 +102,39 @@ func (p *Parser) Parse(r io.Reader) (*TrackLog, error) {
 		}
 		pt.NanoTime = proto.Int64(tm.UnixNano())
 		return nil
+	}).OnText("//gpx/trk/trkseg/trkpt/hdop", true, func(text string) error {
+		hdop, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		pt.Hdop = proto.Float64(hdop)
+		return nil
+	}).OnText("//gpx/trk/trkseg/trkpt/extensions/spread", true, func(text string) error {
+		if parser.Space() != Namespace {
+			return nil
+		}
+		spread, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		pt.Spread = proto.Float64(spread)
+		return nil
+	}).OnText("//gpx/trk/trkseg/trkpt/extensions/confidence", true, func(text string) error {
+		if parser.Space() != Namespace {
+			return nil
+		}
+		confidence, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		pt.Confidence = proto.Float64(confidence)
+		return nil
+	}).OnText("//gpx/trk/trkseg/trkpt/extensions/source", true, func(text string) error {
+		if parser.Space() != Namespace {
+			return nil
+		}
+		pt.Source = proto.String(text)
+		return nil
 	}).On("//gpx/wpt", func(attrs map[string]string) error {
 		wpt = &WayPoint{}
 		lat, err := strconv.ParseFloat(attrs["lat"], 64)
@@ -375,6 +375,10 @@ type Point struct {
 	Longitude     *float64               `protobuf:"fixed64,2,req,name=longitude" json:"longitude,omitempty"`
 	NanoTime      *int64                 `protobuf:"varint,3,opt,name=nano_time,json=nanoTime" json:"nano_time,omitempty"`
 	Elevation     *float64               `protobuf:"fixed64,4,opt,name=elevation" json:"elevation,omitempty"`
+	Hdop          *float64               `protobuf:"fixed64,5,opt,name=hdop" json:"hdop,omitempty"`
+	Spread        *float64               `protobuf:"fixed64,6,opt,name=spread" json:"spread,omitempty"`
+	Confidence    *float64               `protobuf:"fixed64,7,opt,name=confidence" json:"confidence,omitempty"`
+	Source        *string                `protobuf:"bytes,8,opt,name=source" json:"source,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -437,6 +441,34 @@ func (x *Point) GetElevation() float64 {
 	return 0
 }
 
+func (x *Point) GetHdop() float64 {
+	if x != nil && x.Hdop != nil {
+		return *x.Hdop
+	}
+	return 0
+}
+
+func (x *Point) GetSpread() float64 {
+	if x != nil && x.Spread != nil {
+		return *x.Spread
+	}
+	return 0
+}
+
+func (x *Point) GetConfidence() float64 {
+	if x != nil && x.Confidence != nil {
+		return *x.Confidence
+	}
+	return 0
+}
+
+func (x *Point) GetSource() string {
+	if x != nil && x.Source != nil {
+		return *x.Source
+	}
+	return ""
+}
+
 type TrackStats struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	Distance          *float64               `protobuf:"fixed64,1,req,name=distance" json:"distance,omitempty"`
@@ -594,12 +626,18 @@ const file_gpx_track_log_proto_rawDesc = "" +
 	"\bsegments\x18\x04 \x03(\v2\f.gpx.SegmentR\bsegments\"-\n" +
 	"\aSegment\x12\"\n" +
 	"\x06points\x18\x01 \x03(\v2\n" +
-	".gpx.PointR\x06points\"|\n" +
+	".gpx.PointR\x06points\"\xe0\x01\n" +
 	"\x05Point\x12\x1a\n" +
 	"\blatitude\x18\x01 \x02(\x01R\blatitude\x12\x1c\n" +
 	"\tlongitude\x18\x02 \x02(\x01R\tlongitude\x12\x1b\n" +
 	"\tnano_time\x18\x03 \x01(\x03R\bnanoTime\x12\x1c\n" +
-	"\televation\x18\x04 \x01(\x01R\televation\"\x92\x03\n" +
+	"\televation\x18\x04 \x01(\x01R\televation\x12\x12\n" +
+	"\x04hdop\x18\x05 \x01(\x01R\x04hdop\x12\x16\n" +
+	"\x06spread\x18\x06 \x01(\x01R\x06spread\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\a \x01(\x01R\n" +
+	"confidence\x12\x16\n" +
+	"\x06source\x18\b \x01(\tR\x06source\"\x92\x03\n" +
 	"\n" +
 	"TrackStats\x12\x1a\n" +
 	"\bdistance\x18\x01 \x02(\x01R\bdistance\x12\x1b\n" +
@@ -19,10 +19,14 @@ func (gw *Writer) Write(log *TrackLog) error {
 		value: "  ",
 	}
 	w := gw.Writer
+	namespaces := ""
+	if hasExtensions(log) {
+		namespaces = fmt.Sprintf(` xmlns:gpxtoolkit="%s"`, Namespace)
+	}
 	if _, err := w.Write([]byte(fmt.Sprintf(`%s<?xml version="1.0" encoding="UTF-8"?>%s`, indent, newline))); err != nil {
 		return err
 	}
-	if _, err := w.Write([]byte(fmt.Sprintf(`%s<gpx version="1.1" creator="%s" xmlns="http://www.topografix.com/GPX/1/1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd">%s`, indent, gw.Creator, newline))); err != nil {
+	if _, err := w.Write([]byte(fmt.Sprintf(`%s<gpx version="1.1" creator="%s" xmlns="http://www.topografix.com/GPX/1/1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"%s xsi:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd">%s`, indent, gw.Creator, namespaces, newline))); err != nil {
 		return err
 	}
 	indent.level++
@@ -131,6 +135,36 @@ func (gw *Writer) Write(log *TrackLog) error {
 						return err
 					}
 				}
+				if pt.Hdop != nil {
+					if _, err := w.Write([]byte(fmt.Sprintf(`%s<hdop>%f</hdop>%s`, indent, pt.GetHdop(), newline))); err != nil {
+						return err
+					}
+				}
+				if pt.Spread != nil || pt.Confidence != nil || pt.Source != nil {
+					if _, err := w.Write([]byte(fmt.Sprintf(`%s<extensions>%s`, indent, newline))); err != nil {
+						return err
+					}
+					indent.level++
+					if pt.Spread != nil {
+						if _, err := w.Write([]byte(fmt.Sprintf(`%s<gpxtoolkit:spread>%f</gpxtoolkit:spread>%s`, indent, pt.GetSpread(), newline))); err != nil {
+							return err
+						}
+					}
+					if pt.Confidence != nil {
+						if _, err := w.Write([]byte(fmt.Sprintf(`%s<gpxtoolkit:confidence>%f</gpxtoolkit:confidence>%s`, indent, pt.GetConfidence(), newline))); err != nil {
+							return err
+						}
+					}
+					if pt.Source != nil {
+						if _, err := w.Write([]byte(fmt.Sprintf(`%s<gpxtoolkit:source>%s</gpxtoolkit:source>%s`, indent, xmlEscape(pt.GetSource()), newline))); err != nil {
+							return err
+						}
+					}
+					indent.level--
+					if _, err := w.Write([]byte(fmt.Sprintf(`%s</extensions>%s`, indent, newline))); err != nil {
+						return err
+					}
+				}
 				indent.level--
 				if _, err := w.Write([]byte(fmt.Sprintf(`%s</trkpt>%s`, indent, newline))); err != nil {
 					return err
@@ -174,3 +208,18 @@ func (i *indent) String() string {
 	}
 	return res
 }
+
+// hasExtensions returns whether any track point has the extensions of
+// gpxtoolkit.
+func hasExtensions(log *TrackLog) bool {
+	for _, t := range log.Tracks {
+		for _, seg := range t.Segments {
+			for _, pt := range seg.Points {
+				if pt.Spread != nil || pt.Confidence != nil || pt.Source != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,462 @@
+package gpxutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/xml"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Area is a geographic area to crop track logs. Crossings returns the ratios
+// along the line from a to b, interpolated by lat/lon, where it crosses the
+// boundary of the area, in any order.
+type Area interface {
+	Contains(latitude, longitude float64) bool
+	Crossings(a, b *gpx.Point) []float64
+}
+
+// BoundingBoxArea is the area within the latitudes and longitudes.
+type BoundingBoxArea struct {
+	MinLatitude, MinLongitude float64
+	MaxLatitude, MaxLongitude float64
+}
+
+// Validate checks the minimums are not greater than the maximums.
+func (a *BoundingBoxArea) Validate() error {
+	if a.MinLatitude > a.MaxLatitude || a.MinLongitude > a.MaxLongitude {
+		return fmt.Errorf("invalid bounding box: min (%f, %f) greater than max (%f, %f)", a.MinLatitude, a.MinLongitude, a.MaxLatitude, a.MaxLongitude)
+	}
+	return nil
+}
+
+func (a *BoundingBoxArea) Contains(latitude, longitude float64) bool {
+	return latitude >= a.MinLatitude && latitude <= a.MaxLatitude && longitude >= a.MinLongitude && longitude <= a.MaxLongitude
+}
+
+func (a *BoundingBoxArea) Crossings(p, q *gpx.Point) []float64 {
+	lat1, lon1 := p.GetLatitude(), p.GetLongitude()
+	dlat, dlon := q.GetLatitude()-lat1, q.GetLongitude()-lon1
+	ratios := make([]float64, 0)
+	if dlat != 0 {
+		for _, lat := range []float64{a.MinLatitude, a.MaxLatitude} {
+			ratio := (lat - lat1) / dlat
+			if lon := lon1 + dlon*ratio; lon >= a.MinLongitude && lon <= a.MaxLongitude {
+				ratios = append(ratios, ratio)
+			}
+		}
+	}
+	if dlon != 0 {
+		for _, lon := range []float64{a.MinLongitude, a.MaxLongitude} {
+			ratio := (lon - lon1) / dlon
+			if lat := lat1 + dlat*ratio; lat >= a.MinLatitude && lat <= a.MaxLatitude {
+				ratios = append(ratios, ratio)
+			}
+		}
+	}
+	return ratios
+}
+
+// CircleArea is the area within the radius in meters from the center.
+type CircleArea struct {
+	Latitude, Longitude float64
+	Radius              float64
+}
+
+// Validate checks the radius is positive.
+func (a *CircleArea) Validate() error {
+	if a.Radius <= 0 {
+		return fmt.Errorf("invalid radius of circle: %f", a.Radius)
+	}
+	return nil
+}
+
+func (a *CircleArea) Contains(latitude, longitude float64) bool {
+	return gpx.GeoDistance(a.Latitude, a.Longitude, latitude, longitude) <= a.Radius
+}
+
+// Crossings bisects the line on both sides of its point closest to the
+// center, so that a line passing through the circle crosses it twice.
+func (a *CircleArea) Crossings(p, q *gpx.Point) []float64 {
+	prj := newLocalProjection(&gpx.Point{Latitude: proto.Float64(a.Latitude), Longitude: proto.Float64(a.Longitude)})
+	x1, y1 := prj.project(p)
+	x2, y2 := prj.project(q)
+	dx, dy := x2-x1, y2-y1
+	closest := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		closest = math.Max(0, math.Min(1, -(x1*dx+y1*dy)/l))
+	}
+	ratios := make([]float64, 0)
+	for _, r := range [][2]float64{{0, closest}, {closest, 1}} {
+		if ratio, ok := bisectCrossing(a, p, q, r[0], r[1]); ok {
+			ratios = append(ratios, ratio)
+		}
+	}
+	return ratios
+}
+
+// Polygon is an outer ring with holes of [longitude, latitude] as GeoJSON.
+type Polygon struct {
+	Outer [][2]float64
+	Holes [][][2]float64
+}
+
+func (p *Polygon) Contains(latitude, longitude float64) bool {
+	if !ringContains(p.Outer, latitude, longitude) {
+		return false
+	}
+	for _, hole := range p.Holes {
+		if ringContains(hole, latitude, longitude) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Polygon) crossings(a, b *gpx.Point) []float64 {
+	ratios := ringCrossings(p.Outer, a, b)
+	for _, hole := range p.Holes {
+		ratios = append(ratios, ringCrossings(hole, a, b)...)
+	}
+	return ratios
+}
+
+// ringCrossings returns the ratios along the line from a to b where it
+// intersects the edges of the ring.
+func ringCrossings(ring [][2]float64, a, b *gpx.Point) []float64 {
+	ratios := make([]float64, 0)
+	x1, y1 := a.GetLongitude(), a.GetLatitude()
+	dx, dy := b.GetLongitude()-x1, b.GetLatitude()-y1
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xj, yj := ring[j][0], ring[j][1]
+		ex, ey := ring[i][0]-xj, ring[i][1]-yj
+		denominator := dx*ey - dy*ex
+		if denominator == 0 {
+			// parallel or an empty edge
+			continue
+		}
+		ratio := ((xj-x1)*ey - (yj-y1)*ex) / denominator
+		edge := ((xj-x1)*dy - (yj-y1)*dx) / denominator
+		if edge >= 0 && edge <= 1 {
+			ratios = append(ratios, ratio)
+		}
+	}
+	return ratios
+}
+
+// ringContains returns whether the ring contains the point by ray casting.
+func ringContains(ring [][2]float64, latitude, longitude float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > latitude) != (yj > latitude) && longitude < (xj-xi)*(latitude-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// PolygonArea is the area within any of the polygons.
+type PolygonArea struct {
+	Polygons []*Polygon
+}
+
+func (a *PolygonArea) Contains(latitude, longitude float64) bool {
+	for _, p := range a.Polygons {
+		if p.Contains(latitude, longitude) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *PolygonArea) Crossings(p, q *gpx.Point) []float64 {
+	ratios := make([]float64, 0)
+	for _, polygon := range a.Polygons {
+		ratios = append(ratios, polygon.crossings(p, q)...)
+	}
+	return ratios
+}
+
+// OpenPolygonArea opens the polygons of a GeoJSON or KML file by its extension.
+func OpenPolygonArea(file string) (*PolygonArea, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".geojson", ".json":
+		return ParseGeoJSONArea(f)
+	case ".kml":
+		return ParseKMLArea(f)
+	default:
+		return nil, fmt.Errorf("unknown area file: %s", file)
+	}
+}
+
+type geoJSON struct {
+	Type        string
+	Coordinates json.RawMessage
+	Geometry    *geoJSON
+	Geometries  []*geoJSON
+	Features    []*geoJSON
+}
+
+// ParseGeoJSONArea parses the polygons and multi-polygons of a GeoJSON
+// geometry, feature or feature collection.
+func ParseGeoJSONArea(r io.Reader) (*PolygonArea, error) {
+	var g geoJSON
+	err := json.NewDecoder(r).Decode(&g)
+	if err != nil {
+		return nil, err
+	}
+	area := &PolygonArea{}
+	err = area.addGeoJSON(&g)
+	if err != nil {
+		return nil, err
+	}
+	if len(area.Polygons) <= 0 {
+		return nil, fmt.Errorf("no polygon in GeoJSON")
+	}
+	return area, nil
+}
+
+func (a *PolygonArea) addGeoJSON(g *geoJSON) error {
+	switch g.Type {
+	case "FeatureCollection":
+		for _, f := range g.Features {
+			if err := a.addGeoJSON(f); err != nil {
+				return err
+			}
+		}
+	case "Feature":
+		if g.Geometry != nil {
+			return a.addGeoJSON(g.Geometry)
+		}
+	case "GeometryCollection":
+		for _, geometry := range g.Geometries {
+			if err := a.addGeoJSON(geometry); err != nil {
+				return err
+			}
+		}
+	case "Polygon":
+		var rings [][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &rings); err != nil {
+			return err
+		}
+		a.addRings(rings)
+	case "MultiPolygon":
+		var polygons [][][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &polygons); err != nil {
+			return err
+		}
+		for _, rings := range polygons {
+			a.addRings(rings)
+		}
+	}
+	return nil
+}
+
+func (a *PolygonArea) addRings(rings [][][2]float64) {
+	if len(rings) <= 0 {
+		return
+	}
+	a.Polygons = append(a.Polygons, &Polygon{Outer: rings[0], Holes: rings[1:]})
+}
+
+// ParseKMLArea parses the polygons of a KML in any placemarks or folders.
+func ParseKMLArea(r io.Reader) (*PolygonArea, error) {
+	area := &PolygonArea{}
+	var polygon *Polygon
+	parser := xml.NewParser()
+	err := parser.OnAny(func(map[string]string) error {
+		if parser.Peek() == "Polygon" {
+			polygon = &Polygon{}
+		}
+		return nil
+	}, func(text string) error {
+		if polygon == nil || parser.Peek() != "coordinates" {
+			return nil
+		}
+		ring, err := parseKMLCoordinates(text)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(parser.XPath(), "/innerBoundaryIs/") {
+			polygon.Holes = append(polygon.Holes, ring)
+		} else {
+			polygon.Outer = ring
+		}
+		return nil
+	}, func() error {
+		if parser.Peek() == "Polygon" && polygon != nil {
+			area.Polygons = append(area.Polygons, polygon)
+			polygon = nil
+		}
+		return nil
+	}).Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(area.Polygons) <= 0 {
+		return nil, fmt.Errorf("no polygon in KML")
+	}
+	return area, nil
+}
+
+// parseKMLCoordinates parses the tuples of 'longitude,latitude[,altitude]'
+// separated by white spaces.
+func parseKMLCoordinates(text string) ([][2]float64, error) {
+	ring := make([][2]float64, 0)
+	for _, tuple := range strings.Fields(text) {
+		values := strings.Split(tuple, ",")
+		if len(values) < 2 {
+			return nil, fmt.Errorf("invalid KML coordinates: %s", tuple)
+		}
+		lon, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		lat, err := strconv.ParseFloat(values[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		ring = append(ring, [2]float64{lon, lat})
+	}
+	return ring, nil
+}
+
+// CropByArea keeps the track points inside the area, or outside if Outside is
+// true, with new segments wherever tracks leave and the boundary crossings
+// interpolated. Waypoints not kept are removed.
+type CropByArea struct {
+	Area    Area
+	Outside bool
+}
+
+func (c *CropByArea) Name() string {
+	if c.Outside {
+		return "Crop outside Area"
+	}
+	return "Crop inside Area"
+}
+
+func (c *CropByArea) keeps(latitude, longitude float64) bool {
+	return c.Area.Contains(latitude, longitude) != c.Outside
+}
+
+// Run returns the number of points after cropping.
+func (c *CropByArea) Run(tracklog *gpx.TrackLog) (int, error) {
+	n := 0
+	tracks := make([]*gpx.Track, 0, len(tracklog.Tracks))
+	for _, t := range tracklog.Tracks {
+		segments := make([]*gpx.Segment, 0, len(t.Segments))
+		for _, seg := range t.Segments {
+			for _, s := range c.crop(seg.Points) {
+				segments = append(segments, s)
+				n += len(s.Points)
+			}
+		}
+		if len(segments) > 0 {
+			t.Segments = segments
+			tracks = append(tracks, t)
+		}
+	}
+	tracklog.Tracks = tracks
+	waypoints := make([]*gpx.WayPoint, 0, len(tracklog.WayPoints))
+	for _, w := range tracklog.WayPoints {
+		if c.keeps(w.GetLatitude(), w.GetLongitude()) {
+			waypoints = append(waypoints, w)
+		}
+	}
+	tracklog.WayPoints = waypoints
+	return n, nil
+}
+
+// crop splits each line at its crossings of the boundary, and keeps the parts
+// of the lines whose middle points are kept, so that lines passing through or
+// out of the area are cropped even if their points are not.
+func (c *CropByArea) crop(points []*gpx.Point) []*gpx.Segment {
+	segments := make([]*gpx.Segment, 0)
+	if len(points) <= 1 {
+		if len(points) == 1 && c.keeps(points[0].GetLatitude(), points[0].GetLongitude()) {
+			segments = append(segments, &gpx.Segment{Points: points})
+		}
+		return segments
+	}
+	var current *gpx.Segment
+	for i, b := range points[1:] {
+		a := points[i]
+		ratios := []float64{0, 1}
+		for _, r := range c.Area.Crossings(a, b) {
+			if r > 0 && r < 1 {
+				ratios = append(ratios, r)
+			}
+		}
+		sort.Float64s(ratios)
+		for k, r := range ratios[1:] {
+			from := ratios[k]
+			if r-from < 1e-12 {
+				continue
+			}
+			mid := interpolate(a, b, (from+r)/2)
+			if !c.keeps(mid.GetLatitude(), mid.GetLongitude()) {
+				if current != nil {
+					segments = append(segments, current)
+					current = nil
+				}
+				continue
+			}
+			if current == nil {
+				start := a
+				if from > 0 {
+					start = interpolate(a, b, from)
+				}
+				current = &gpx.Segment{Points: []*gpx.Point{start}}
+			}
+			end := b
+			if r < 1 {
+				end = interpolate(a, b, r)
+			}
+			current.Points = append(current.Points, end)
+		}
+	}
+	if current != nil {
+		segments = append(segments, current)
+	}
+	return segments
+}
+
+// bisectCrossing returns the ratio between lo and hi along the line from p to q
+// where it crosses the boundary of the area, if the area contains only one of
+// the points at lo and hi.
+func bisectCrossing(area Area, p, q *gpx.Point, lo, hi float64) (float64, bool) {
+	contains := func(ratio float64) bool {
+		m := interpolate(p, q, ratio)
+		return area.Contains(m.GetLatitude(), m.GetLongitude())
+	}
+	inside := contains(lo)
+	if inside == contains(hi) {
+		return 0, false
+	}
+	for i := 0; i < 50; i++ {
+		mid := (lo + hi) / 2
+		if contains(mid) == inside {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2, true
+}
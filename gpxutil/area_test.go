@@ -0,0 +1,222 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// northTrackLog walks north from 24.000 to 24.010 every 0.001 degree.
+func northTrackLog(t *testing.T) *gpx.TrackLog {
+	points := ""
+	for i := 0; i <= 10; i++ {
+		points += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"></trkpt>`, 24.0+float64(i)*0.001)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<wpt lat="24.004" lon="121.0"><name>Inside</name></wpt>
+<wpt lat="24.009" lon="121.0"><name>Outside</name></wpt>
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, points)
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tracklog
+}
+
+func segmentLengths(tracklog *gpx.TrackLog) []int {
+	lengths := make([]int, 0)
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			lengths = append(lengths, len(seg.Points))
+		}
+	}
+	return lengths
+}
+
+func TestCropByArea(t *testing.T) {
+	box := &BoundingBoxArea{MinLatitude: 24.0025, MinLongitude: 120.99, MaxLatitude: 24.0065, MaxLongitude: 121.01}
+	tracklog := northTrackLog(t)
+	crop := &CropByArea{Area: box}
+	n, err := crop.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 24.003 ... 24.006 with the crossings
+	if n != 6 {
+		t.Fatalf("Unexpected number of points: %d", n)
+	}
+	points := tracklog.Tracks[0].Segments[0].Points
+	if math.Abs(points[0].GetLatitude()-24.0025) > 1e-9 || math.Abs(points[5].GetLatitude()-24.0065) > 1e-9 {
+		t.Fatalf("Unexpected crossings: %f, %f", points[0].GetLatitude(), points[5].GetLatitude())
+	}
+	if len(tracklog.WayPoints) != 1 || tracklog.WayPoints[0].GetName() != "Inside" {
+		t.Fatalf("Unexpected waypoints: %v", tracklog.WayPoints)
+	}
+
+	tracklog = northTrackLog(t)
+	crop = &CropByArea{Area: box, Outside: true}
+	_, err = crop.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lengths := segmentLengths(tracklog); len(lengths) != 2 || lengths[0] != 4 || lengths[1] != 5 {
+		t.Fatalf("Unexpected segments: %v", lengths)
+	}
+	if len(tracklog.WayPoints) != 1 || tracklog.WayPoints[0].GetName() != "Outside" {
+		t.Fatalf("Unexpected waypoints: %v", tracklog.WayPoints)
+	}
+
+	tracklog = northTrackLog(t)
+	crop = &CropByArea{Area: &CircleArea{Latitude: 24.005, Longitude: 121.0, Radius: 250}}
+	n, err = crop.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 24.003 ... 24.007 with the crossings
+	if n != 7 {
+		t.Fatalf("Unexpected number of points in circle: %d", n)
+	}
+}
+
+func TestPolygonArea(t *testing.T) {
+	// a square with a hole around 24.005 crossed by the track
+	geojson := `{"type": "FeatureCollection", "features": [{"type": "Feature", "properties": {}, "geometry": {
+"type": "Polygon", "coordinates": [
+[[120.99, 24.0015], [121.01, 24.0015], [121.01, 24.0085], [120.99, 24.0085], [120.99, 24.0015]],
+[[120.995, 24.0045], [121.005, 24.0045], [121.005, 24.0055], [120.995, 24.0055], [120.995, 24.0045]]
+]}}]}`
+	kml := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2"><Document><Folder><Placemark><name>Park</name>
+<Polygon><outerBoundaryIs><LinearRing><coordinates>
+120.99,24.0015,0 121.01,24.0015,0 121.01,24.0085,0 120.99,24.0085,0 120.99,24.0015,0
+</coordinates></LinearRing></outerBoundaryIs>
+<innerBoundaryIs><LinearRing><coordinates>
+120.995,24.0045 121.005,24.0045 121.005,24.0055 120.995,24.0055 120.995,24.0045
+</coordinates></LinearRing></innerBoundaryIs></Polygon>
+</Placemark></Folder></Document></kml>`
+	geojsonArea, err := ParseGeoJSONArea(strings.NewReader(geojson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kmlArea, err := ParseKMLArea(strings.NewReader(kml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, area := range []*PolygonArea{geojsonArea, kmlArea} {
+		if len(area.Polygons) != 1 || len(area.Polygons[0].Holes) != 1 {
+			t.Fatalf("Unexpected polygons: %v", area.Polygons)
+		}
+		tracklog := northTrackLog(t)
+		crop := &CropByArea{Area: area}
+		_, err := crop.Run(tracklog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 24.002 ... 24.004 and 24.006 ... 24.008 with the crossings
+		if lengths := segmentLengths(tracklog); len(lengths) != 2 || lengths[0] != 5 || lengths[1] != 5 {
+			t.Fatalf("Unexpected segments: %v", lengths)
+		}
+	}
+}
+
+func TestCropByAreaOfSparseTrack(t *testing.T) {
+	// a single line from 24.000 to 24.010 whose points are both outside
+	sparse := func() *gpx.TrackLog {
+		return &gpx.TrackLog{Tracks: []*gpx.Track{{Segments: []*gpx.Segment{{Points: []*gpx.Point{
+			{Latitude: proto.Float64(24.0), Longitude: proto.Float64(121.0)},
+			{Latitude: proto.Float64(24.01), Longitude: proto.Float64(121.0)},
+		}}}}}}
+	}
+	tracklog := sparse()
+	circle := &CircleArea{Latitude: 24.005, Longitude: 121.0, Radius: 100}
+	n, err := (&CropByArea{Area: circle}).Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Unexpected number of points in circle: %d", n)
+	}
+	for _, p := range tracklog.Tracks[0].Segments[0].Points {
+		if d := gpx.GeoDistance(circle.Latitude, circle.Longitude, p.GetLatitude(), p.GetLongitude()); math.Abs(d-circle.Radius) > 0.01 {
+			t.Fatalf("Unexpected crossing of circle: %f", d)
+		}
+	}
+
+	tracklog = sparse()
+	_, err = (&CropByArea{Area: circle, Outside: true}).Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lengths := segmentLengths(tracklog); len(lengths) != 2 || lengths[0] != 2 || lengths[1] != 2 {
+		t.Fatalf("Unexpected segments outside circle: %v", lengths)
+	}
+
+	// a U-shaped polygon whose notch between 120.999 and 121.001 is crossed
+	// by a line from one arm to the other
+	u := &PolygonArea{Polygons: []*Polygon{{Outer: [][2]float64{
+		{120.998, 24.0}, {121.002, 24.0}, {121.002, 24.002}, {121.001, 24.002},
+		{121.001, 24.001}, {120.999, 24.001}, {120.999, 24.002}, {120.998, 24.002}, {120.998, 24.0},
+	}}}}
+	tracklog = &gpx.TrackLog{Tracks: []*gpx.Track{{Segments: []*gpx.Segment{{Points: []*gpx.Point{
+		{Latitude: proto.Float64(24.0015), Longitude: proto.Float64(120.9985)},
+		{Latitude: proto.Float64(24.0015), Longitude: proto.Float64(121.0015)},
+	}}}}}}
+	_, err = (&CropByArea{Area: u}).Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lengths := segmentLengths(tracklog); len(lengths) != 2 || lengths[0] != 2 || lengths[1] != 2 {
+		t.Fatalf("Unexpected segments in concave polygon: %v", lengths)
+	}
+	segments := tracklog.Tracks[0].Segments
+	if math.Abs(segments[0].Points[1].GetLongitude()-120.999) > 1e-9 || math.Abs(segments[1].Points[0].GetLongitude()-121.001) > 1e-9 {
+		t.Fatalf("Unexpected crossings of notch: %f, %f", segments[0].Points[1].GetLongitude(), segments[1].Points[0].GetLongitude())
+	}
+
+	// a line clipping the corner of a bounding box
+	box := &BoundingBoxArea{MinLatitude: 24.004, MinLongitude: 120.99, MaxLatitude: 24.02, MaxLongitude: 121.0005}
+	tracklog = &gpx.TrackLog{Tracks: []*gpx.Track{{Segments: []*gpx.Segment{{Points: []*gpx.Point{
+		{Latitude: proto.Float64(24.0), Longitude: proto.Float64(120.999)},
+		{Latitude: proto.Float64(24.01), Longitude: proto.Float64(121.002)},
+	}}}}}}
+	n, err = (&CropByArea{Area: box}).Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Unexpected number of points in box corner: %d", n)
+	}
+}
+
+func TestCircleAreaValidate(t *testing.T) {
+	if err := (&CircleArea{Radius: 100}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&CircleArea{Radius: -100}).Validate(); err == nil {
+		t.Fatal("Unexpected valid negative radius")
+	}
+}
+
+func TestBoundingBoxAreaValidate(t *testing.T) {
+	for _, c := range []struct {
+		area  *BoundingBoxArea
+		valid bool
+	}{
+		{&BoundingBoxArea{MinLatitude: 24.1, MinLongitude: 121.2, MaxLatitude: 24.2, MaxLongitude: 121.3}, true},
+		{&BoundingBoxArea{MinLatitude: 24.1, MinLongitude: 121.2, MaxLatitude: 24.1, MaxLongitude: 121.2}, true},
+		{&BoundingBoxArea{MinLatitude: 24.2, MinLongitude: 121.2, MaxLatitude: 24.1, MaxLongitude: 121.3}, false},
+		{&BoundingBoxArea{MinLatitude: 24.1, MinLongitude: 121.3, MaxLatitude: 24.2, MaxLongitude: 121.2}, false},
+	} {
+		if err := c.area.Validate(); (err == nil) != c.valid {
+			t.Fatalf("Unexpected validation of %v: %v", c.area, err)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package gpxutil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// AverageMethods are the methods of AverageTracks.
+var AverageMethods = []string{"median", "mean"}
+
+// AverageTracks finds the consensus centerline of recordings of the same route.
+// The recordings are turned to the direction of the one of median length, which
+// is resampled every Interval meters as stations. Each recording is then
+// aligned to the stations by the closest points going forward, and those
+// deviating more than Outlier times of the median deviation of all recordings
+// are rejected. The centerline is the median or the mean weighted by the
+// inverse square deviations of the aligned points of the accepted recordings
+// at each station, aligned again to the median centerline.
+//
+// Each point of the centerline has the spread, which is the RMS distance in
+// meters of the aligned points to it, and the confidence, which is the ratio of
+// the accepted recordings passing within Tolerance meters of it.
+type AverageTracks struct {
+	Interval  float64
+	Method    string  // median if empty
+	Outlier   float64 // 3 if not positive
+	Tolerance float64
+	Rejected  []int // indices of the rejected recordings
+}
+
+type recording struct {
+	points   []*gpx.Point
+	lines    []*line
+	mileages []float64 // mileage at the start of each line
+	length   float64
+}
+
+func newRecording(points []*gpx.Point) *recording {
+	r := &recording{points: points, lines: getLines(HaversinMode, points)}
+	r.mileages = make([]float64, len(r.lines))
+	for i, l := range r.lines {
+		r.mileages[i] = r.length
+		r.length += l.dist
+	}
+	return r
+}
+
+func (r *recording) reverse() *recording {
+	points := make([]*gpx.Point, len(r.points))
+	for i, p := range r.points {
+		points[len(points)-1-i] = p
+	}
+	return newRecording(points)
+}
+
+func (c *AverageTracks) Name() string {
+	return fmt.Sprintf("Average Tracks every %.0fm", c.Interval)
+}
+
+// Run returns the track log of the centerline of the recordings.
+func (c *AverageTracks) Run(tracklogs []*gpx.TrackLog) (*gpx.TrackLog, error) {
+	switch c.Method {
+	case "", "median", "mean":
+	default:
+		return nil, fmt.Errorf("unknown average method: %s", c.Method)
+	}
+	if c.Interval <= 0 {
+		return nil, fmt.Errorf("invalid interval: %f", c.Interval)
+	}
+	recordings := make([]*recording, 0, len(tracklogs))
+	for i, tracklog := range tracklogs {
+		_, points := trackLogLines(tracklog)
+		if len(points) < 2 {
+			return nil, fmt.Errorf("no track in recording %d", i)
+		}
+		recordings = append(recordings, newRecording(points))
+	}
+	if len(recordings) < 2 {
+		return nil, fmt.Errorf("at least 2 recordings are required")
+	}
+	ref := c.reference(recordings)
+	for i, r := range recordings {
+		first, last := r.points[0], r.points[len(r.points)-1]
+		refFirst, refLast := ref.points[0], ref.points[len(ref.points)-1]
+		if HaversinDistance(first, refLast)+HaversinDistance(last, refFirst) < HaversinDistance(first, refFirst)+HaversinDistance(last, refLast) {
+			recordings[i] = r.reverse()
+		}
+	}
+	ref = c.reference(recordings)
+	stations := c.stations(ref)
+
+	aligned := c.align(recordings, stations)
+	accepted := make([]bool, len(recordings))
+	for i := range accepted {
+		accepted[i] = true
+	}
+	centers, _, _ := c.center(aligned, accepted, nil, true)
+	deviations := c.deviations(aligned, centers)
+	c.Rejected = make([]int, 0)
+	if len(recordings) >= 3 {
+		outlier := c.Outlier
+		if outlier <= 0 {
+			outlier = 3
+		}
+		limit := math.Max(outlier*median(deviations), c.Tolerance)
+		for i, dev := range deviations {
+			if dev > limit {
+				accepted[i] = false
+				c.Rejected = append(c.Rejected, i)
+			}
+		}
+		centers, _, _ = c.center(aligned, accepted, nil, true)
+	}
+	stations = make([]*gpx.Point, 0, len(centers))
+	for _, p := range centers {
+		if p != nil {
+			stations = append(stations, p)
+		}
+	}
+	aligned = c.align(recordings, stations)
+	weights := make([]float64, len(recordings))
+	for i, dev := range c.deviations(aligned, stations) {
+		weights[i] = 1 / math.Pow(math.Max(dev, 1), 2)
+	}
+	centers, spreads, confidences := c.center(aligned, accepted, weights, c.Method != "mean")
+	points := make([]*gpx.Point, 0, len(centers))
+	for i, p := range centers {
+		if p == nil {
+			continue
+		}
+		p.Spread = proto.Float64(spreads[i])
+		p.Confidence = proto.Float64(confidences[i])
+		points = append(points, p)
+	}
+	return &gpx.TrackLog{
+		Tracks: []*gpx.Track{{
+			Name:     proto.String("Average"),
+			Segments: []*gpx.Segment{{Points: points}},
+		}},
+	}, nil
+}
+
+// reference returns the recording of median length.
+func (c *AverageTracks) reference(recordings []*recording) *recording {
+	sorted := make([]*recording, len(recordings))
+	copy(sorted, recordings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].length < sorted[j].length
+	})
+	return sorted[len(sorted)/2]
+}
+
+// stations returns the points of the recording resampled every Interval.
+func (c *AverageTracks) stations(r *recording) []*gpx.Point {
+	num := int(math.Ceil(r.length / c.Interval))
+	stations := make([]*gpx.Point, 0, num+1)
+	j := 0
+	for i := 0; i <= num; i++ {
+		mileage := r.length * float64(i) / float64(num)
+		for j < len(r.lines)-1 && r.mileages[j]+r.lines[j].dist < mileage {
+			j++
+		}
+		l := r.lines[j]
+		ratio := 0.0
+		if l.dist > 0 {
+			ratio = math.Min(1, math.Max(0, (mileage-r.mileages[j])/l.dist))
+		}
+		stations = append(stations, l.interpolate(ratio))
+	}
+	return stations
+}
+
+// align returns the closest points of the recordings to the stations, searching
+// forward in a window from the previous one. A station is not aligned to a
+// recording if it is beyond the end points by Tolerance.
+func (c *AverageTracks) align(recordings []*recording, stations []*gpx.Point) [][]*gpx.Point {
+	window := math.Max(4*c.Interval, 100)
+	aligned := make([][]*gpx.Point, len(recordings))
+	for i, r := range recordings {
+		aligned[i] = make([]*gpx.Point, len(stations))
+		cur := -1
+		for s, station := range stations {
+			best, bestDist := -1, math.Inf(1)
+			var closest *gpx.Point
+			start := max(cur, 0)
+			for j := start; j < len(r.lines); j++ {
+				if cur >= 0 && r.mileages[j] > r.mileages[cur]+window {
+					break
+				}
+				p := r.lines[j].closestPoint(station)
+				dist := HaversinDistance(p, station)
+				if dist < bestDist {
+					best, bestDist, closest = j, dist, p
+				}
+			}
+			cur = best
+			if bestDist > c.Tolerance && (closest == r.points[0] || closest == r.points[len(r.points)-1]) {
+				continue
+			}
+			aligned[i][s] = closest
+		}
+	}
+	return aligned
+}
+
+// deviations returns the median distances of the aligned points of each
+// recording to the centers.
+func (c *AverageTracks) deviations(aligned [][]*gpx.Point, centers []*gpx.Point) []float64 {
+	deviations := make([]float64, len(aligned))
+	for i, points := range aligned {
+		dists := make([]float64, 0, len(points))
+		for s, p := range points {
+			if p != nil && centers[s] != nil {
+				dists = append(dists, HaversinDistance(p, centers[s]))
+			}
+		}
+		if len(dists) > 0 {
+			deviations[i] = median(dists)
+		} else {
+			deviations[i] = math.Inf(1)
+		}
+	}
+	return deviations
+}
+
+// center returns the centers of the aligned points of the accepted recordings
+// at each station, or nil if there is none, with their spreads and confidences.
+func (c *AverageTracks) center(aligned [][]*gpx.Point, accepted []bool, weights []float64, byMedian bool) ([]*gpx.Point, []float64, []float64) {
+	num := 0
+	for _, ok := range accepted {
+		if ok {
+			num++
+		}
+	}
+	stations := len(aligned[0])
+	centers := make([]*gpx.Point, stations)
+	spreads := make([]float64, stations)
+	confidences := make([]float64, stations)
+	for s := 0; s < stations; s++ {
+		var prj *localProjection
+		xs, ys, ws, eles, eleWs := []float64{}, []float64{}, []float64{}, []float64{}, []float64{}
+		for i, points := range aligned {
+			p := points[s]
+			if !accepted[i] || p == nil {
+				continue
+			}
+			if prj == nil {
+				prj = newLocalProjection(p)
+			}
+			w := 1.0
+			if weights != nil {
+				w = weights[i]
+			}
+			x, y := prj.project(p)
+			xs, ys, ws = append(xs, x), append(ys, y), append(ws, w)
+			if p.Elevation != nil {
+				eles, eleWs = append(eles, p.GetElevation()), append(eleWs, w)
+			}
+		}
+		if prj == nil {
+			continue
+		}
+		x, y := weightedMean(xs, ws), weightedMean(ys, ws)
+		if byMedian {
+			x, y = median(xs), median(ys)
+		}
+		lat, lon := prj.unproject(x, y)
+		p := &gpx.Point{Latitude: proto.Float64(lat), Longitude: proto.Float64(lon)}
+		if len(eles) > 0 {
+			if byMedian {
+				p.Elevation = proto.Float64(median(eles))
+			} else {
+				p.Elevation = proto.Float64(weightedMean(eles, eleWs))
+			}
+		}
+		sum, within := 0.0, 0
+		for i := range xs {
+			d := math.Hypot(xs[i]-x, ys[i]-y)
+			sum += d * d
+			if d <= c.Tolerance {
+				within++
+			}
+		}
+		centers[s] = p
+		spreads[s] = math.Sqrt(sum / float64(len(xs)))
+		confidences[s] = float64(within) / float64(num)
+	}
+	return centers, spreads, confidences
+}
+
+func weightedMean(values, weights []float64) float64 {
+	sum, total := 0.0, 0.0
+	for i, v := range values {
+		sum += v * weights[i]
+		total += weights[i]
+	}
+	return sum / total
+}
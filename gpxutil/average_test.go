@@ -0,0 +1,86 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestAverageTracks(t *testing.T) {
+	// meters per degree of longitude at 24N
+	kx := 6371393 * math.Pi / 180 * math.Cos(24*math.Pi/180)
+	// recordings 1km to the north with different offsets to the east, sampling
+	// and directions, and an outlier 200m away
+	offsets := []float64{-5, -2, 0, 3, 6, 200}
+	tracklogs := make([]*gpx.TrackLog, len(offsets))
+	for i, offset := range offsets {
+		num := 20 + i*7
+		points := make([]string, 0, num+1)
+		for j := 0; j <= num; j++ {
+			lat := 24.0 + 0.009*float64(j)/float64(num)
+			points = append(points, fmt.Sprintf(`<trkpt lat="%f" lon="%f"><ele>%d</ele></trkpt>`, lat, 121.0+offset/kx, 100+i))
+		}
+		if i == 1 {
+			for a, b := 0, len(points)-1; a < b; a, b = a+1, b-1 {
+				points[a], points[b] = points[b], points[a]
+			}
+		}
+		xml := ""
+		for _, p := range points {
+			xml += p
+		}
+		tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, xml))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tracklogs[i] = tracklog
+	}
+	average := &AverageTracks{Interval: 20, Tolerance: 20}
+	tracklog, err := average.Run(tracklogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(average.Rejected) != 1 || average.Rejected[0] != 5 {
+		t.Fatalf("Unexpected rejected recordings: %v", average.Rejected)
+	}
+	points := tracklog.Tracks[0].Segments[0].Points
+	if len(points) < 45 || len(points) > 55 {
+		t.Fatalf("Unexpected number of points: %d", len(points))
+	}
+	if points[0].GetLatitude() > points[len(points)-1].GetLatitude() {
+		t.Fatalf("Unexpected direction")
+	}
+	// RMS of -5, -2, 0, 3, 6
+	spread := math.Sqrt((25 + 4 + 0 + 9 + 36) / 5.0)
+	for _, p := range points {
+		if d := math.Abs(p.GetLongitude()-121.0) * kx; d > 0.5 {
+			t.Fatalf("Unexpected deviation of median: %f", d)
+		}
+		if math.Abs(p.GetSpread()-spread) > 0.5 {
+			t.Fatalf("Unexpected spread: %f", p.GetSpread())
+		}
+		if p.GetConfidence() != 1 {
+			t.Fatalf("Unexpected confidence: %f", p.GetConfidence())
+		}
+		if p.GetElevation() != 102 {
+			t.Fatalf("Unexpected elevation: %f", p.GetElevation())
+		}
+	}
+
+	average = &AverageTracks{Interval: 20, Tolerance: 20, Method: "mean"}
+	tracklog, err = average.Run(tracklogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range tracklog.Tracks[0].Segments[0].Points {
+		if d := math.Abs(p.GetLongitude()-121.0) * kx; d > 2 {
+			t.Fatalf("Unexpected deviation of mean: %f", d)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package gpxutil
+
+import (
+	"math"
+
+	"gpxtoolkit/gpx"
+)
+
+// Climb is a continuous climb (or descent when Gain is negative) along a
+// segment.
+type Climb struct {
+	Start, End                 *gpx.Point
+	StartChainage, EndChainage float64
+	Gain                       float64 // smoothed elevation difference from Start to End
+	AverageGrade               float64
+	MaxGrade                   float64 // steepest grade in the direction of the climb
+	Category                   string
+}
+
+func (c *Climb) Length() float64 {
+	return c.EndChainage - c.StartChainage
+}
+
+func (c *Climb) IsDescent() bool {
+	return c.Gain < 0
+}
+
+// ClimbCategory categorizes a climb by its score, i.e. the length in meters
+// multiplied by the average grade in percent, in the same way as the popular
+// cycling climb categories. It returns an empty string for uncategorized
+// climbs.
+func ClimbCategory(length, grade float64) string {
+	score := length * math.Abs(grade) * 100
+	switch {
+	case score >= 80000:
+		return "HC"
+	case score >= 64000:
+		return "1"
+	case score >= 32000:
+		return "2"
+	case score >= 16000:
+		return "3"
+	case score >= 8000:
+		return "4"
+	default:
+		return ""
+	}
+}
+
+// DetectClimbs finds climbs and descents in the smoothed elevation profile
+// of the points.
+type DetectClimbs struct {
+	Window    float64 // horizontal window in meters for smoothing elevation
+	Tolerance float64 // dips (or bumps) smaller than this in meters don't end a climb
+	MinGain   float64 // climbs with less elevation gain in meters are ignored
+}
+
+func (d *DetectClimbs) Detect(points []*gpx.Point) ([]*Climb, error) {
+	prof, err := newProfile(points)
+	if err != nil {
+		return nil, err
+	}
+	if len(points) <= 1 {
+		return []*Climb{}, nil
+	}
+	elevations := prof.smoothedElevations(d.Window)
+	grades := prof.grades(d.Window)
+	climbs := make([]*Climb, 0)
+	for _, turn := range turningPoints(elevations, d.Tolerance) {
+		a, b := turn[0], turn[1]
+		gain := elevations[b] - elevations[a]
+		if math.Abs(gain) < d.MinGain {
+			continue
+		}
+		climb := &Climb{
+			Start:         points[a],
+			End:           points[b],
+			StartChainage: prof.chainages[a],
+			EndChainage:   prof.chainages[b],
+			Gain:          gain,
+		}
+		if climb.Length() > 0 {
+			climb.AverageGrade = gain / climb.Length()
+		}
+		for _, l := range grades[a:b] {
+			if gain > 0 {
+				climb.MaxGrade = math.Max(climb.MaxGrade, l.Grade)
+			} else {
+				climb.MaxGrade = math.Min(climb.MaxGrade, l.Grade)
+			}
+		}
+		climb.Category = ClimbCategory(climb.Length(), climb.AverageGrade)
+		climbs = append(climbs, climb)
+	}
+	return climbs, nil
+}
+
+// turningPoints splits the elevations into alternating ascending and
+// descending ranges of indices. A range only ends when the elevation turns
+// back by more than the tolerance.
+func turningPoints(elevations []float64, tolerance float64) [][2]int {
+	ranges := make([][2]int, 0)
+	start := 0
+	extreme := 0
+	direction := 0
+	lo, hi := 0, 0 // lowest and highest points before the trend is known
+	for i, e := range elevations {
+		switch direction {
+		case 0:
+			if e < elevations[lo] {
+				lo = i
+			}
+			if e > elevations[hi] {
+				hi = i
+			}
+			if e-elevations[lo] > tolerance {
+				direction = 1
+				start = lo
+				extreme = i
+			} else if elevations[hi]-e > tolerance {
+				direction = -1
+				start = hi
+				extreme = i
+			}
+		case 1:
+			if e >= elevations[extreme] {
+				extreme = i
+			} else if elevations[extreme]-e > tolerance {
+				ranges = append(ranges, [2]int{start, extreme})
+				start = extreme
+				extreme = i
+				direction = -1
+			}
+		case -1:
+			if e <= elevations[extreme] {
+				extreme = i
+			} else if e-elevations[extreme] > tolerance {
+				ranges = append(ranges, [2]int{start, extreme})
+				start = extreme
+				extreme = i
+				direction = 1
+			}
+		}
+	}
+	if direction != 0 && extreme > start {
+		ranges = append(ranges, [2]int{start, extreme})
+	}
+	return ranges
+}
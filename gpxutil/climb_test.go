@@ -0,0 +1,31 @@
+package gpxutil
+
+import (
+	"testing"
+)
+
+func TestTurningPoints(t *testing.T) {
+	elevations := []float64{100, 98, 120, 115, 150, 200, 190, 195, 120, 130}
+	ranges := turningPoints(elevations, 10)
+	expected := [][2]int{{1, 5}, {5, 8}}
+	if len(ranges) != len(expected) {
+		t.Fatalf("Unexpected ranges: %v", ranges)
+	}
+	for i, r := range ranges {
+		if r != expected[i] {
+			t.Fatalf("Unexpected ranges: %v", ranges)
+		}
+	}
+}
+
+func TestClimbCategory(t *testing.T) {
+	if c := ClimbCategory(500, 0.1); c != "" {
+		t.Fatalf("Unexpected category: %s", c)
+	}
+	if c := ClimbCategory(2000, 0.1); c != "3" {
+		t.Fatalf("Unexpected category: %s", c)
+	}
+	if c := ClimbCategory(5000, -0.2); c != "HC" {
+		t.Fatalf("Unexpected category: %s", c)
+	}
+}
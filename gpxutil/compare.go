@@ -0,0 +1,173 @@
+package gpxutil
+
+import (
+	"math"
+
+	"gpxtoolkit/gpx"
+)
+
+// OffRoute is a section of a recorded track farther than the tolerance from
+// the route.
+type OffRoute struct {
+	Start, End   *gpx.Point
+	MaxDeviation float64 // meters from the route
+	Length       float64 // meters along the recorded track beyond the tolerance
+}
+
+// Comparison is the geometric comparison of a recorded track log against a
+// route, in meters.
+type Comparison struct {
+	Hausdorff float64
+	Frechet   float64 // discrete Fréchet distance of the points
+	Coverage  float64 // ratio of the route length within the tolerance
+	OffRoutes []*OffRoute
+}
+
+// Compare compares the tracks of the recorded track log against those of the
+// route, where segments of a track log are compared as a whole. The Fréchet
+// distance couples the recorded points of all segments in order, without
+// interpolated points, so it is coarse along sparse routes, and takes time of
+// the product of the numbers of points. The comparison is empty if either has
+// no line.
+func Compare(route, recorded *gpx.TrackLog, tolerance float64) *Comparison {
+	routeLines, routePoints := trackLogLines(route)
+	recordedLines, recordedPoints := trackLogLines(recorded)
+	c := &Comparison{}
+	if len(routeLines) <= 0 || len(recordedLines) <= 0 {
+		return c
+	}
+	for _, p := range recordedPoints {
+		c.Hausdorff = math.Max(c.Hausdorff, distanceToLines(routeLines, p))
+	}
+	for _, p := range routePoints {
+		c.Hausdorff = math.Max(c.Hausdorff, distanceToLines(recordedLines, p))
+	}
+	c.Frechet = frechet(routePoints, recordedPoints)
+	c.Coverage = coverage(routeLines, recordedLines, tolerance)
+	for _, t := range recorded.Tracks {
+		for _, seg := range t.Segments {
+			c.OffRoutes = append(c.OffRoutes, offRoutes(routeLines, seg.Points, tolerance)...)
+		}
+	}
+	return c
+}
+
+// trackLogLines returns the lines of all the segments of the track log, and
+// all the points in order.
+func trackLogLines(tracklog *gpx.TrackLog) ([]*line, []*gpx.Point) {
+	lines := make([]*line, 0)
+	points := make([]*gpx.Point, 0)
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			lines = append(lines, getLines(HaversinMode, seg.Points)...)
+			points = append(points, seg.Points...)
+		}
+	}
+	return lines, points
+}
+
+// distanceToLines returns the distance from the point to the closest of the
+// lines.
+func distanceToLines(lines []*line, p *gpx.Point) float64 {
+	dist := math.Inf(1)
+	for _, l := range lines {
+		dist = math.Min(dist, HaversinDistance(p, l.closestPoint(p)))
+	}
+	return dist
+}
+
+// frechet returns the discrete Fréchet distance between the points, which
+// keeps only two rows of the coupling table.
+func frechet(a, b []*gpx.Point) float64 {
+	prev := make([]float64, len(b))
+	curr := make([]float64, len(b))
+	for i, p := range a {
+		for j, q := range b {
+			d := HaversinDistance(p, q)
+			switch {
+			case i == 0 && j == 0:
+				curr[j] = d
+			case i == 0:
+				curr[j] = math.Max(curr[j-1], d)
+			case j == 0:
+				curr[j] = math.Max(prev[j], d)
+			default:
+				curr[j] = math.Max(math.Min(prev[j], math.Min(prev[j-1], curr[j-1])), d)
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)-1]
+}
+
+// coverage returns the ratio of the length of the route lines within the
+// tolerance from the recorded lines, sampled every half tolerance.
+func coverage(routeLines, recordedLines []*line, tolerance float64) float64 {
+	step := math.Max(1, tolerance/2)
+	total, covered := 0.0, 0.0
+	for _, l := range routeLines {
+		if l.dist <= 0 {
+			continue
+		}
+		num := int(math.Ceil(l.dist / step))
+		for i := 0; i < num; i++ {
+			p := l.interpolate((float64(i) + 0.5) / float64(num))
+			if distanceToLines(recordedLines, p) <= tolerance {
+				covered += l.dist / float64(num)
+			}
+		}
+		total += l.dist
+	}
+	if total <= 0 {
+		return 0
+	}
+	return covered / total
+}
+
+// offRoutes returns the runs of points farther than the tolerance from the
+// route lines, whose lengths include the legs from and back to where the
+// track crosses the tolerance.
+func offRoutes(routeLines []*line, points []*gpx.Point, tolerance float64) []*OffRoute {
+	sections := make([]*OffRoute, 0)
+	var section *OffRoute
+	for i, p := range points {
+		dist := distanceToLines(routeLines, p)
+		if dist <= tolerance {
+			if section != nil {
+				crossing := toleranceCrossing(routeLines, p, points[i-1], tolerance)
+				section.Length += HaversinDistance(points[i-1], crossing)
+			}
+			section = nil
+			continue
+		}
+		if section == nil {
+			section = &OffRoute{Start: p}
+			sections = append(sections, section)
+			if i > 0 {
+				crossing := toleranceCrossing(routeLines, points[i-1], p, tolerance)
+				section.Length += HaversinDistance(crossing, p)
+			}
+		} else {
+			section.Length += HaversinDistance(points[i-1], p)
+		}
+		section.End = p
+		section.MaxDeviation = math.Max(section.MaxDeviation, dist)
+	}
+	return sections
+}
+
+// toleranceCrossing returns the point on the line from a within the tolerance
+// to b beyond it, where it crosses the tolerance from the route lines, by
+// bisection.
+func toleranceCrossing(routeLines []*line, a, b *gpx.Point, tolerance float64) *gpx.Point {
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 30; i++ {
+		mid := (lo + hi) / 2
+		if distanceToLines(routeLines, interpolate(a, b, mid)) <= tolerance {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return interpolate(a, b, (lo+hi)/2)
+}
@@ -0,0 +1,100 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCompare(t *testing.T) {
+	route, err := gpx.Parse(bytes.NewBuffer([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>
+	<trkpt lat="24.000" lon="121.0"></trkpt>
+	<trkpt lat="24.010" lon="121.0"></trkpt>
+</trkseg></trk>
+</gpx>`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// walks 80% of the route with a detour of 2 points 50 meters east
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := ""
+	for i := 0; i <= 16; i++ {
+		lon := 121.0
+		if i == 8 || i == 9 {
+			lon += 0.0005
+		}
+		points += fmt.Sprintf(`<trkpt lat="%f" lon="%f"><time>%s</time></trkpt>`, 24+float64(i)*0.0005, lon, start.Add(time.Duration(i)*time.Minute).Format(time.RFC3339))
+	}
+	recorded, err := gpx.Parse(bytes.NewBuffer([]byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, points))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Compare(route, recorded, 20)
+	// the uncovered end of the route
+	uncovered := HaversinDistance(route.Tracks[0].Segments[0].Points[1], recorded.Tracks[0].Segments[0].Points[16])
+	if math.Abs(c.Hausdorff-uncovered) > 0.1 {
+		t.Fatalf("Unexpected Hausdorff distance: %f", c.Hausdorff)
+	}
+	if c.Frechet < c.Hausdorff {
+		t.Fatalf("Unexpected Fréchet distance: %f", c.Frechet)
+	}
+	// 20% at the end and about 9% around the detour are not covered
+	if c.Coverage < 0.7 || c.Coverage > 0.75 {
+		t.Fatalf("Unexpected coverage: %f", c.Coverage)
+	}
+	if len(c.OffRoutes) != 1 {
+		t.Fatalf("Unexpected number of off-route sections: %d", len(c.OffRoutes))
+	}
+	o := c.OffRoutes[0]
+	if !o.Start.Time().Equal(start.Add(8*time.Minute)) || !o.End.Time().Equal(start.Add(9*time.Minute)) {
+		t.Fatalf("Unexpected off-route section: %v - %v", o.Start.Time(), o.End.Time())
+	}
+	// 55.6 meters between the detour points, and about 45.7 meters of the legs
+	// to and from them beyond the tolerance
+	if math.Abs(o.MaxDeviation-50.8) > 1 || math.Abs(o.Length-146.9) > 1 {
+		t.Fatalf("Unexpected off-route deviation or length: %f, %f", o.MaxDeviation, o.Length)
+	}
+
+	// an excursion of a single point like the detour
+	routeLines, _ := trackLogLines(route)
+	excursion := []*gpx.Point{
+		{Latitude: proto.Float64(24.0035), Longitude: proto.Float64(121.0)},
+		{Latitude: proto.Float64(24.004), Longitude: proto.Float64(121.0005)},
+		{Latitude: proto.Float64(24.0045), Longitude: proto.Float64(121.0)},
+	}
+	sections := offRoutes(routeLines, excursion, 20)
+	if len(sections) != 1 || math.Abs(sections[0].Length-2*45.7) > 1 {
+		t.Fatalf("Unexpected off-route section of a single point: %v", sections)
+	}
+}
+
+func TestFrechet(t *testing.T) {
+	points := func(lon float64, lats ...float64) []*gpx.Point {
+		res := make([]*gpx.Point, len(lats))
+		for i, lat := range lats {
+			res[i] = &gpx.Point{Latitude: proto.Float64(lat), Longitude: proto.Float64(lon)}
+		}
+		return res
+	}
+	a := points(121.0, 24.000, 24.001, 24.002)
+	b := points(121.0001, 24.000, 24.001, 24.002)
+	if d, want := frechet(a, b), HaversinDistance(a[0], b[0]); math.Abs(d-want) > 1e-6 {
+		t.Fatalf("Unexpected Fréchet distance of parallel points: %f", d)
+	}
+	// going back breaks the order, unlike the Hausdorff distance
+	c := points(121.0, 24.002, 24.001, 24.000)
+	if d, want := frechet(a, c), HaversinDistance(a[0], c[0]); math.Abs(d-want) > 1e-6 {
+		t.Fatalf("Unexpected Fréchet distance of reversed points: %f", d)
+	}
+}
@@ -0,0 +1,417 @@
+package gpxutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// DeviceProfile declares the constraints of a GPS device, which are applied by
+// the commands of the profile. Zero values are unconstrained.
+type DeviceProfile struct {
+	Name               string
+	Description        string
+	MaxTrackPoints     int     // max number of points per track
+	Fit                string  // how to fit tracks into MaxTrackPoints: split (default) or simplify
+	Epsilon            float64 // RDP epsilon in meters to simplify tracks beforehand
+	MaxTracks          int     // max number of tracks per file
+	MaxWaypoints       int     // max number of waypoints per file
+	WaypointNameLength int     // max number of characters of waypoint names
+	Symbols            map[string]string
+	DefaultSymbol      string // symbol of waypoints not in Symbols; kept if empty
+	Strip              []string
+}
+
+// DeviceStripFields are the fields which can be stripped by a DeviceProfile.
+var DeviceStripFields = []string{"time", "elevation", "hdop", "spread", "confidence", "source", "description", "comment", "symbol", "type"}
+
+// garminSymbols maps common symbols to those of Garmin devices.
+var garminSymbols = map[string]string{
+	"milestone":   "Mile Marker",
+	"summit":      "Summit",
+	"peak":        "Summit",
+	"camp":        "Campground",
+	"campground":  "Campground",
+	"water":       "Drinking Water",
+	"parking":     "Parking Area",
+	"trailhead":   "Trail Head",
+	"trail head":  "Trail Head",
+	"viewpoint":   "Scenic Area",
+	"danger":      "Danger Area",
+	"shelter":     "Lodge",
+	"hut":         "Lodge",
+	"restroom":    "Restroom",
+	"toilet":      "Restroom",
+	"information": "Information",
+}
+
+// DeviceProfiles are the built-in device profiles, whose limits are the
+// conservative ones of the device families.
+var DeviceProfiles = map[string]*DeviceProfile{
+	"garmin-legacy": {
+		Name:               "garmin-legacy",
+		Description:        "Legacy Garmin units, e.g. eTrex and GPSMAP 60",
+		MaxTrackPoints:     500,
+		MaxTracks:          20,
+		MaxWaypoints:       500,
+		WaypointNameLength: 14,
+		Symbols:            garminSymbols,
+		DefaultSymbol:      "Flag, Blue",
+		Strip:              []string{"hdop", "spread", "confidence", "source", "comment", "type"},
+	},
+	"garmin": {
+		Name:               "garmin",
+		Description:        "Recent Garmin handhelds and watches",
+		MaxTrackPoints:     10000,
+		MaxTracks:          250,
+		MaxWaypoints:       5000,
+		WaypointNameLength: 30,
+		Symbols:            garminSymbols,
+		DefaultSymbol:      "Flag, Blue",
+		Strip:              []string{"hdop", "spread", "confidence", "source"},
+	},
+	"suunto": {
+		Name:               "suunto",
+		Description:        "Suunto watches via the Suunto app",
+		MaxTrackPoints:     1000,
+		Fit:                "simplify",
+		MaxTracks:          1,
+		MaxWaypoints:       200,
+		WaypointNameLength: 15,
+		Strip:              []string{"time", "hdop", "spread", "confidence", "source", "description", "comment", "symbol", "type"},
+	},
+	"osmand": {
+		Name:        "osmand",
+		Description: "OsmAnd, which has no practical limits",
+		Strip:       []string{"hdop"},
+	},
+}
+
+// OpenDeviceProfile loads a DeviceProfile from a JSON file.
+func OpenDeviceProfile(file string) (*DeviceProfile, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ParseDeviceProfile(r)
+}
+
+// ParseDeviceProfile decodes and validates a DeviceProfile from JSON.
+func ParseDeviceProfile(r io.Reader) (*DeviceProfile, error) {
+	p := &DeviceProfile{}
+	err := json.NewDecoder(r).Decode(p)
+	if err != nil {
+		return nil, err
+	}
+	err = p.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate checks the limits, fit and stripped fields.
+func (p *DeviceProfile) Validate() error {
+	if p.MaxTrackPoints < 0 || p.MaxTracks < 0 || p.MaxWaypoints < 0 || p.WaypointNameLength < 0 || p.Epsilon < 0 {
+		return fmt.Errorf("negative limit in device profile %s", p.Name)
+	}
+	if p.MaxTrackPoints == 1 {
+		return fmt.Errorf("max track points of device profile %s must be at least 2", p.Name)
+	}
+	if p.WaypointNameLength > 0 && p.WaypointNameLength < minWaypointNameLength {
+		return fmt.Errorf("waypoint name length of device profile %s must be at least %d", p.Name, minWaypointNameLength)
+	}
+	switch p.Fit {
+	case "", "split", "simplify":
+	default:
+		return fmt.Errorf("unknown fit of device profile %s: %s", p.Name, p.Fit)
+	}
+	for _, field := range p.Strip {
+		found := false
+		for _, f := range DeviceStripFields {
+			if f == field {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown field to strip in device profile %s: %s", p.Name, field)
+		}
+	}
+	return nil
+}
+
+// Commands returns the commands to fit a track log into the device.
+func (p *DeviceProfile) Commands() []Command {
+	commands := make([]Command, 0)
+	if p.Epsilon > 0 || (p.MaxTrackPoints > 0 && p.Fit == "simplify") {
+		simplify := &Simplify{Epsilon: p.Epsilon, First: true}
+		if p.Fit == "simplify" {
+			simplify.MaxPoints = p.MaxTrackPoints
+		}
+		commands = append(commands, simplify)
+	}
+	if p.MaxTrackPoints > 0 && p.Fit != "simplify" {
+		commands = append(commands, &SplitTracks{MaxPoints: p.MaxTrackPoints})
+	}
+	if len(p.Symbols) > 0 || p.DefaultSymbol != "" {
+		commands = append(commands, &MapSymbols{Symbols: p.Symbols, Default: p.DefaultSymbol})
+	}
+	if p.WaypointNameLength > 0 {
+		commands = append(commands, &TruncateWaypointNames{Length: p.WaypointNameLength})
+	}
+	if len(p.Strip) > 0 {
+		commands = append(commands, &StripFields{Fields: p.Strip})
+	}
+	return commands
+}
+
+// Export runs the commands of the profile on the track log, and divides it into
+// track logs within MaxTracks and MaxWaypoints.
+func (p *DeviceProfile) Export(tracklog *gpx.TrackLog) ([]*gpx.TrackLog, error) {
+	_, err := (&ChainedCommands{Commands: p.Commands()}).Run(tracklog)
+	if err != nil {
+		return nil, err
+	}
+	maxTracks, maxWaypoints := p.MaxTracks, p.MaxWaypoints
+	if maxTracks <= 0 {
+		maxTracks = max(1, len(tracklog.Tracks))
+	}
+	if maxWaypoints <= 0 {
+		maxWaypoints = max(1, len(tracklog.WayPoints))
+	}
+	files := max(1, (len(tracklog.Tracks)+maxTracks-1)/maxTracks, (len(tracklog.WayPoints)+maxWaypoints-1)/maxWaypoints)
+	logs := make([]*gpx.TrackLog, files)
+	for i := range logs {
+		logs[i] = &gpx.TrackLog{
+			Creator:  tracklog.Creator,
+			Name:     tracklog.Name,
+			NanoTime: tracklog.NanoTime,
+			Link:     tracklog.Link,
+		}
+		logs[i].Tracks = tracklog.Tracks[min(len(tracklog.Tracks), i*maxTracks):min(len(tracklog.Tracks), (i+1)*maxTracks)]
+		logs[i].WayPoints = tracklog.WayPoints[min(len(tracklog.WayPoints), i*maxWaypoints):min(len(tracklog.WayPoints), (i+1)*maxWaypoints)]
+	}
+	return logs, nil
+}
+
+// SplitTracks splits tracks of more points than MaxPoints into parts, named by
+// the part number, where each part begins with the last point of the previous
+// part so that there is no gap between them.
+type SplitTracks struct {
+	MaxPoints int
+}
+
+func (c *SplitTracks) Name() string {
+	return fmt.Sprintf("Split Tracks over %d Points", c.MaxPoints)
+}
+
+// Run returns the number of new tracks.
+func (c *SplitTracks) Run(tracklog *gpx.TrackLog) (int, error) {
+	if c.MaxPoints < 2 {
+		return 0, fmt.Errorf("invalid max points: %d", c.MaxPoints)
+	}
+	tracks := make([]*gpx.Track, 0, len(tracklog.Tracks))
+	for _, t := range tracklog.Tracks {
+		parts := c.split(t)
+		if len(parts) > 1 {
+			for i, part := range parts {
+				part.Name = proto.String(fmt.Sprintf("%s (%d/%d)", t.GetName(), i+1, len(parts)))
+			}
+		}
+		tracks = append(tracks, parts...)
+	}
+	n := len(tracks) - len(tracklog.Tracks)
+	tracklog.Tracks = tracks
+	return n, nil
+}
+
+func (c *SplitTracks) split(t *gpx.Track) []*gpx.Track {
+	newPart := func() *gpx.Track {
+		return &gpx.Track{Name: t.Name, Type: t.Type, Comment: t.Comment}
+	}
+	parts := []*gpx.Track{newPart()}
+	count := 0
+	for _, seg := range t.Segments {
+		points := seg.Points
+		for len(points) > 0 {
+			part := parts[len(parts)-1]
+			if count >= c.MaxPoints {
+				// continue from the last point in a new part
+				part = newPart()
+				parts = append(parts, part)
+				count = 0
+				if len(points) < len(seg.Points) {
+					prev := parts[len(parts)-2]
+					last := prev.Segments[len(prev.Segments)-1]
+					points = append([]*gpx.Point{last.Points[len(last.Points)-1]}, points...)
+				}
+			}
+			num := min(len(points), c.MaxPoints-count)
+			part.Segments = append(part.Segments, &gpx.Segment{Points: points[:num]})
+			count += num
+			points = points[num:]
+		}
+	}
+	return parts
+}
+
+// MapSymbols maps the symbols of waypoints case-insensitively by Symbols, or
+// to Default if not found and Default is not empty.
+type MapSymbols struct {
+	Symbols map[string]string
+	Default string
+}
+
+func (c *MapSymbols) Name() string {
+	return "Map Waypoint Symbols"
+}
+
+// Run returns the number of waypoints whose symbols are changed.
+func (c *MapSymbols) Run(tracklog *gpx.TrackLog) (int, error) {
+	symbols := make(map[string]string, len(c.Symbols))
+	for from, to := range c.Symbols {
+		symbols[strings.ToLower(from)] = to
+	}
+	n := 0
+	for _, wpt := range tracklog.WayPoints {
+		sym, ok := symbols[strings.ToLower(wpt.GetSymbol())]
+		if !ok {
+			if c.Default == "" {
+				continue
+			}
+			sym = c.Default
+		}
+		if sym != wpt.GetSymbol() {
+			wpt.Symbol = proto.String(sym)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// minWaypointNameLength is the minimum length of truncated waypoint names, to
+// keep a character before a suffix of '~' and a digit.
+const minWaypointNameLength = 3
+
+// TruncateWaypointNames truncates the names of waypoints to Length characters,
+// and replaces the ends of names colliding with others by '~' and a number.
+// It fails if the suffix leaves no character of a name within Length.
+type TruncateWaypointNames struct {
+	Length int
+}
+
+func (c *TruncateWaypointNames) Name() string {
+	return fmt.Sprintf("Truncate Waypoint Names to %d Characters", c.Length)
+}
+
+// Run returns the number of waypoints renamed.
+func (c *TruncateWaypointNames) Run(tracklog *gpx.TrackLog) (int, error) {
+	if c.Length < minWaypointNameLength {
+		return 0, fmt.Errorf("invalid length: %d", c.Length)
+	}
+	used := make(map[string]bool)
+	for _, wpt := range tracklog.WayPoints {
+		if len([]rune(wpt.GetName())) <= c.Length {
+			used[wpt.GetName()] = true
+		}
+	}
+	n := 0
+	for _, wpt := range tracklog.WayPoints {
+		name := []rune(wpt.GetName())
+		if len(name) <= c.Length {
+			continue
+		}
+		truncated := string(name[:c.Length])
+		for i := 1; used[truncated]; i++ {
+			suffix := []rune(fmt.Sprintf("~%d", i))
+			if len(suffix) >= c.Length {
+				return 0, fmt.Errorf("too many waypoints named %s to truncate to %d characters", string(name[:c.Length]), c.Length)
+			}
+			truncated = string(name[:c.Length-len(suffix)]) + string(suffix)
+		}
+		used[truncated] = true
+		wpt.Name = proto.String(truncated)
+		n++
+	}
+	return n, nil
+}
+
+// StripFields clears the fields of track points and waypoints, which are any
+// of DeviceStripFields.
+type StripFields struct {
+	Fields []string
+}
+
+func (c *StripFields) Name() string {
+	fields := append([]string{}, c.Fields...)
+	sort.Strings(fields)
+	return fmt.Sprintf("Strip %s", strings.Join(fields, ", "))
+}
+
+// Run returns the number of track points and waypoints.
+func (c *StripFields) Run(tracklog *gpx.TrackLog) (int, error) {
+	strip := make(map[string]bool)
+	for _, f := range c.Fields {
+		strip[f] = true
+	}
+	n := 0
+	for _, t := range tracklog.Tracks {
+		if strip["type"] {
+			t.Type = nil
+		}
+		if strip["comment"] {
+			t.Comment = nil
+		}
+		for _, seg := range t.Segments {
+			for _, p := range seg.Points {
+				if strip["time"] {
+					p.NanoTime = nil
+				}
+				if strip["elevation"] {
+					p.Elevation = nil
+				}
+				if strip["hdop"] {
+					p.Hdop = nil
+				}
+				if strip["spread"] {
+					p.Spread = nil
+				}
+				if strip["confidence"] {
+					p.Confidence = nil
+				}
+				if strip["source"] {
+					p.Source = nil
+				}
+				n++
+			}
+		}
+	}
+	for _, wpt := range tracklog.WayPoints {
+		if strip["time"] {
+			wpt.NanoTime = nil
+		}
+		if strip["elevation"] {
+			wpt.Elevation = nil
+		}
+		if strip["description"] {
+			wpt.Description = nil
+		}
+		if strip["comment"] {
+			wpt.Comment = nil
+		}
+		if strip["symbol"] {
+			wpt.Symbol = nil
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,127 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestDeviceProfile(t *testing.T) {
+	points := ""
+	for i := 0; i < 25; i++ {
+		points += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"><ele>100</ele><hdop>1.5</hdop><extensions><gpxtoolkit:spread>1.5</gpxtoolkit:spread></extensions></trkpt>`, 24+float64(i)*0.001)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" xmlns:gpxtoolkit="https://github.com/outdoorsafetylab/gpxtoolkit" creator="foobar" version="1.1">
+<wpt lat="24.0" lon="121.0"><name>Chixing Mountain East Peak</name><sym>Summit</sym></wpt>
+<wpt lat="24.1" lon="121.0"><name>Chixing Mountain East Peak</name><sym>summit</sym></wpt>
+<wpt lat="24.2" lon="121.0"><name>Chixing Mountain</name><sym>Restroom</sym></wpt>
+<wpt lat="24.3" lon="121.0"><name>Lake</name><cmt>Dry in winter</cmt></wpt>
+<trk><name>Day 1</name><trkseg>%s</trkseg></trk>
+</gpx>`, points)
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, err := ParseDeviceProfile(strings.NewReader(`{
+		"Name": "test",
+		"MaxTrackPoints": 10,
+		"MaxWaypoints": 3,
+		"WaypointNameLength": 16,
+		"Symbols": {"SUMMIT": "Summit"},
+		"DefaultSymbol": "Flag, Blue",
+		"Strip": ["hdop", "spread", "comment"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracklog.Tracks[0].Segments[0].Points[0].Spread == nil {
+		t.Fatalf("Unexpected missing spread before stripping")
+	}
+	logs, err := profile.Export(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 || len(logs[0].WayPoints) != 3 || len(logs[1].WayPoints) != 1 {
+		t.Fatalf("Unexpected number of files: %d", len(logs))
+	}
+	tracks := logs[0].Tracks
+	// 25 points + 2 shared boundary points in parts of at most 10 points
+	if len(tracks) != 3 || len(logs[1].Tracks) != 0 {
+		t.Fatalf("Unexpected number of tracks: %d", len(tracks))
+	}
+	for i, track := range tracks {
+		if track.GetName() != fmt.Sprintf("Day 1 (%d/3)", i+1) {
+			t.Fatalf("Unexpected track name: %s", track.GetName())
+		}
+		if i > 0 {
+			prev := tracks[i-1].Segments[0].Points
+			if track.Segments[0].Points[0] != prev[len(prev)-1] {
+				t.Fatalf("Unexpected gap before track[%d]", i)
+			}
+		}
+		for _, p := range track.Segments[0].Points {
+			if p.Hdop != nil || p.Spread != nil || p.Elevation == nil {
+				t.Fatalf("Unexpected fields of track point: %v", p)
+			}
+		}
+	}
+	names := []string{"Chixing Mounta~1", "Chixing Mounta~2", "Chixing Mountain", "Lake"}
+	symbols := []string{"Summit", "Summit", "Flag, Blue", "Flag, Blue"}
+	wpts := append(logs[0].WayPoints, logs[1].WayPoints...)
+	for i, wpt := range wpts {
+		if wpt.GetName() != names[i] || wpt.GetSymbol() != symbols[i] || wpt.Comment != nil {
+			t.Fatalf("Unexpected waypoint[%d]: %s, %s, %s", i, wpt.GetName(), wpt.GetSymbol(), wpt.GetComment())
+		}
+	}
+
+	_, err = ParseDeviceProfile(strings.NewReader(`{"Name": "bad", "Strip": ["color"]}`))
+	if err == nil {
+		t.Fatalf("Expected error of unknown field to strip")
+	}
+	_, err = ParseDeviceProfile(strings.NewReader(`{"Name": "bad", "WaypointNameLength": 2}`))
+	if err == nil {
+		t.Fatalf("Expected error of waypoint name length shorter than the suffix")
+	}
+	for name, p := range DeviceProfiles {
+		if err := p.Validate(); err != nil {
+			t.Fatalf("Invalid built-in profile %s: %s", name, err.Error())
+		}
+	}
+}
+
+func TestTruncateWaypointNames(t *testing.T) {
+	wpts := ""
+	for i := 0; i < 12; i++ {
+		wpts += `<wpt lat="24.0" lon="121.0"><name>Campsite</name></wpt>`
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+%s
+</gpx>`, wpts)
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&TruncateWaypointNames{Length: 3}).Run(tracklog); err == nil {
+		t.Fatalf("Expected error of too many waypoints to truncate")
+	}
+	tracklog, err = gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&TruncateWaypointNames{Length: 4}).Run(tracklog); err != nil {
+		t.Fatal(err)
+	}
+	for _, wpt := range tracklog.WayPoints {
+		if len([]rune(wpt.GetName())) > 4 {
+			t.Fatalf("Unexpected waypoint name longer than 4: %s", wpt.GetName())
+		}
+	}
+	if _, err := (&TruncateWaypointNames{Length: 2}).Run(tracklog); err == nil {
+		t.Fatalf("Expected error of length shorter than the suffix")
+	}
+}
@@ -0,0 +1,128 @@
+package gpxutil
+
+import (
+	"fmt"
+
+	"gpxtoolkit/gpx"
+
+	"github.com/maja42/goval"
+)
+
+// EffortVariables are the variables available to effort formulas. Distances
+// and altitudes are in meters.
+type EffortVariables struct {
+	Distance    float64
+	Gain, Loss  float64
+	AvgAltitude float64
+	MaxAltitude float64
+	MinAltitude float64
+	Hours       float64 // recorded duration in hours
+}
+
+func NewEffortVariables(st *gpx.TrackStats) *EffortVariables {
+	v := &EffortVariables{
+		Distance:    st.GetDistance(),
+		Gain:        st.GetElevationGain(),
+		Loss:        st.GetElevationLoss(),
+		MaxAltitude: st.GetElevationMax(),
+		MinAltitude: st.GetElevationMin(),
+		Hours:       st.Duration().Hours(),
+	}
+	if v.Distance > 0 {
+		v.AvgAltitude = st.GetElevationDistance() / v.Distance
+	}
+	return v
+}
+
+func (v *EffortVariables) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"dist":    v.Distance,
+		"gain":    v.Gain,
+		"loss":    v.Loss,
+		"avg_alt": v.AvgAltitude,
+		"max_alt": v.MaxAltitude,
+		"min_alt": v.MinAltitude,
+		"hours":   v.Hours,
+	}
+}
+
+// EffortFormula is a named goval expression over EffortVariables, e.g.
+// `(dist + gain*10 + loss*3.3) / 1000`.
+type EffortFormula struct {
+	Name        string
+	Expression  string
+	Unit        string
+	Description string
+}
+
+func (f *EffortFormula) Eval(variables *EffortVariables) (float64, error) {
+	val, err := goval.NewEvaluator().Evaluate(f.Expression, variables.Map(), functions)
+	if err != nil {
+		return 0, err
+	}
+	switch v := val.(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("unexpected result of '%s': %v", f.Name, val)
+	}
+}
+
+// Validate evaluates the formula with sample variables to check its syntax.
+func (f *EffortFormula) Validate() error {
+	_, err := f.Eval(&EffortVariables{
+		Distance:    10000,
+		Gain:        1000,
+		Loss:        1000,
+		AvgAltitude: 1500,
+		MaxAltitude: 2000,
+		MinAltitude: 1000,
+		Hours:       5,
+	})
+	return err
+}
+
+// EffortFormulas are the built-in effort formulas.
+var EffortFormulas = []*EffortFormula{
+	{
+		Name:        "kme",
+		Expression:  "(dist + gain*10 + loss*3.3) / 1000",
+		Unit:        "KmE",
+		Description: "Kilometer effort",
+	},
+	{
+		Name:        "kme-oxygen",
+		Expression:  "(dist + gain*10 + loss*3.3) / 1000 * (0.0001621796175*avg_alt + 0.9763291581)",
+		Unit:        "KmE",
+		Description: "Kilometer effort corrected by the oxygen density at the average altitude",
+	},
+	{
+		Name:        "course-constant",
+		Expression:  "1.8*hours + 0.3*dist/1000 + 10*gain/1000 + 0.6*loss/1000",
+		Unit:        "",
+		Description: "Japanese course constant (コース定数) by Yamamoto Masayoshi using the recorded duration",
+	},
+	{
+		Name:        "sac",
+		Expression:  "max(dist/4200, gain/300 + loss/500) + min(dist/4200, gain/300 + loss/500)/2",
+		Unit:        "hours",
+		Description: "Swiss Alpine Club hiking time (Wanderzeit)",
+	},
+	{
+		Name:        "naismith",
+		Expression:  "dist/5000 + gain/600",
+		Unit:        "hours",
+		Description: "Naismith's rule hiking time",
+	},
+}
+
+func GetEffortFormula(name string) *EffortFormula {
+	for _, f := range EffortFormulas {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package gpxutil
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEffortFormulas(t *testing.T) {
+	vars := &EffortVariables{
+		Distance:    10000,
+		Gain:        1000,
+		Loss:        500,
+		AvgAltitude: 2000,
+	}
+	for _, f := range EffortFormulas {
+		if err := f.Validate(); err != nil {
+			t.Fatalf("Invalid formula '%s': %v", f.Name, err)
+		}
+	}
+	effort, err := GetEffortFormula("kme").Eval(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(effort-21.65) > 1e-9 {
+		t.Fatalf("Unexpected effort: %f", effort)
+	}
+	effort, err = GetEffortFormula("kme-oxygen").Eval(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(effort-21.65*(0.0001621796175*2000+0.9763291581)) > 1e-9 {
+		t.Fatalf("Unexpected effort: %f", effort)
+	}
+	hours, err := GetEffortFormula("sac").Eval(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// vertical: 1000/300 + 500/500 = 4.33h; horizontal: 10000/4200 = 2.38h
+	if math.Round(hours*100) != 552 {
+		t.Fatalf("Unexpected hours: %f", hours)
+	}
+	f := &EffortFormula{Name: "invalid", Expression: "dist +"}
+	if err := f.Validate(); err == nil {
+		t.Fatal("Expected error")
+	}
+}
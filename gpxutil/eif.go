@@ -1,13 +1,47 @@
 package gpxutil
 
 import (
-	"gpxtoolkit/gpx"
+	"fmt"
+	"math"
+	"math/rand"
 
-	eif "github.com/oskanberg/eif-go"
+	"gpxtoolkit/gpx"
 )
 
+// EIFFeatures are the per-point features available to RemoveOutlierByEIF:
+//
+//	lat, lon:     position in degrees
+//	speed:        speed from the previous point in m/s
+//	acceleration: change of speed from the previous line in m/s²
+//	heading:      turn angle at the point in degrees
+//	dele:         elevation delta from the previous point in meters
+//	dt:           time gap from the previous point in seconds
+//
+// The first point takes the speed, elevation delta and time gap to the second
+// point. Features unavailable for a point, e.g. speed without time, are 0.
+var EIFFeatures = []string{"lat", "lon", "speed", "acceleration", "heading", "dele", "dt"}
+
+// EIFScore is the anomaly score of a point with its feature values.
+type EIFScore struct {
+	Track, Segment int
+	Index          int
+	Point          *gpx.Point
+	Features       []float64
+	Score          float64
+}
+
+// RemoveOutlierByEIF removes points whose anomaly score by an extended
+// isolation forest is over Threshold, or only scores them if Threshold is not
+// positive. Features are standardized by median and MAD before fitting.
 type RemoveOutlierByEIF struct {
-	Threshold float64
+	Threshold  float64
+	Features   []string // lat and lon if empty
+	Trees      int      // 100 if not positive
+	Depth      int      // 12 if not positive
+	SampleSize int      // number of points to sample for each tree; all if not positive
+	Seed       int64
+	Score      func(score *EIFScore)  // called for every point if not nil
+	Report     func(outlier *Outlier) // called for each removed point if not nil
 }
 
 func (r *RemoveOutlierByEIF) Name() string {
@@ -15,42 +49,267 @@ func (r *RemoveOutlierByEIF) Name() string {
 }
 
 func (r *RemoveOutlierByEIF) Run(tracklog *gpx.TrackLog) (int, error) {
+	features := r.Features
+	if len(features) <= 0 {
+		features = []string{"lat", "lon"}
+	}
+	for _, f := range features {
+		if eifFeatureIndex(f) < 0 {
+			return 0, fmt.Errorf("unknown EIF feature: %s", f)
+		}
+	}
+	rng := rand.New(rand.NewSource(r.Seed))
 	n := 0
-	for _, t := range tracklog.Tracks {
-		for i, seg := range t.Segments {
+	for i, t := range tracklog.Tracks {
+		for j, seg := range t.Segments {
 			num := len(seg.Points)
-			seg, err := r.remove(seg)
+			seg, err := r.remove(rng, features, i, j, seg)
 			if err != nil {
 				return 0, err
 			}
 			n += (num - len(seg.Points))
-			t.Segments[i] = seg
+			t.Segments[j] = seg
 		}
 	}
 	return n, nil
 }
 
-func (r *RemoveOutlierByEIF) remove(seg *gpx.Segment) (*gpx.Segment, error) {
-	points := make([][]float64, len(seg.Points))
-	for i, p := range seg.Points {
-		points[i] = []float64{
-			p.GetLatitude(),
-			p.GetLongitude(),
-			// p.GetElevation(),
-		}
+func (r *RemoveOutlierByEIF) remove(rng *rand.Rand, features []string, track, segment int, seg *gpx.Segment) (*gpx.Segment, error) {
+	if len(seg.Points) <= 2 {
+		return seg, nil
 	}
+	values := eifFeatures(seg.Points, features)
+	data := standardize(values)
+	trees := r.Trees
+	if trees <= 0 {
+		trees = 100
+	}
+	depth := r.Depth
+	if depth <= 0 {
+		depth = 12
+	}
+	forest := newIsolationForest(rng, data, trees, depth, r.SampleSize)
 	res := &gpx.Segment{
 		Points: make([]*gpx.Point, 0),
 	}
-	f := eif.NewForest(points, eif.WithMaxTreeDepth(12), eif.WithTrees(100))
-	// f := eif.NewForest(points)
-	for i, p := range points {
-		score := f.Score(p)
-		if score > r.Threshold {
+	for i, p := range data {
+		score := forest.score(p)
+		if r.Score != nil {
+			r.Score(&EIFScore{
+				Track:    track,
+				Segment:  segment,
+				Index:    i,
+				Point:    seg.Points[i],
+				Features: values[i],
+				Score:    score,
+			})
+		}
+		if r.Threshold > 0 && score > r.Threshold {
 			// log.Printf("Discarding %v: score=%f", p, score)
+			if r.Report != nil {
+				r.Report(&Outlier{
+					Track:   track,
+					Segment: segment,
+					Index:   i,
+					Point:   seg.Points[i],
+					Reason:  fmt.Sprintf("EIF score %.3f over %.3f", score, r.Threshold),
+				})
+			}
 			continue
 		}
 		res.Points = append(res.Points, seg.Points[i])
 	}
 	return res, nil
 }
+
+func eifFeatureIndex(name string) int {
+	for i, f := range EIFFeatures {
+		if f == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// eifFeatures returns the values of the features of each point.
+func eifFeatures(points []*gpx.Point, features []string) [][]float64 {
+	lines := getLines(HaversinMode, points)
+	values := make([][]float64, len(points))
+	for i, p := range points {
+		all := make([]float64, len(EIFFeatures))
+		all[0] = p.GetLatitude()
+		all[1] = p.GetLongitude()
+		// the first point takes the line to the second point, so that it is
+		// not isolated by the lack of a previous point
+		if len(lines) > 0 {
+			in := lines[max(0, i-1)]
+			if in.speed != nil {
+				all[2] = *in.speed
+				if i > 1 && lines[i-2].speed != nil && *in.duration > 0 {
+					all[3] = (*in.speed - *lines[i-2].speed) / in.duration.Seconds()
+				}
+			}
+			if i > 0 && i < len(lines) {
+				all[4] = turnAngle(in.a, p, lines[i].b)
+			}
+			if in.a.Elevation != nil && in.b.Elevation != nil {
+				all[5] = in.b.GetElevation() - in.a.GetElevation()
+			}
+			if in.duration != nil {
+				all[6] = in.duration.Seconds()
+			}
+		}
+		values[i] = make([]float64, len(features))
+		for j, f := range features {
+			values[i][j] = all[eifFeatureIndex(f)]
+		}
+	}
+	return values
+}
+
+// standardize scales each dimension by its median and MAD, or by its standard
+// deviation if the MAD is 0.
+func standardize(values [][]float64) [][]float64 {
+	data := make([][]float64, len(values))
+	for i := range data {
+		data[i] = make([]float64, len(values[i]))
+	}
+	column := make([]float64, len(values))
+	for d := range values[0] {
+		for i, v := range values {
+			column[i] = v[d]
+		}
+		m := median(column)
+		deviations := make([]float64, len(column))
+		mean, std := 0.0, 0.0
+		for i, v := range column {
+			deviations[i] = math.Abs(v - m)
+			mean += v
+		}
+		scale := 1.4826 * median(deviations)
+		if scale == 0 {
+			mean /= float64(len(column))
+			for _, v := range column {
+				std += (v - mean) * (v - mean)
+			}
+			scale = math.Sqrt(std / float64(len(column)))
+		}
+		if scale == 0 {
+			scale = 1
+		}
+		for i, v := range column {
+			data[i][d] = (v - m) / scale
+		}
+	}
+	return data
+}
+
+// isolationForest is an extended isolation forest, whose trees split data by
+// hyperplanes of random slopes.
+type isolationForest struct {
+	trees []*isolationNode
+	c     float64
+}
+
+type isolationNode struct {
+	normal    []float64
+	intercept []float64
+	left      *isolationNode
+	right     *isolationNode
+	size      int // number of samples in a leaf
+}
+
+func newIsolationForest(rng *rand.Rand, data [][]float64, trees, depth, sampleSize int) *isolationForest {
+	if sampleSize <= 0 || sampleSize > len(data) {
+		sampleSize = len(data)
+	}
+	f := &isolationForest{
+		trees: make([]*isolationNode, trees),
+		c:     averagePathLength(sampleSize),
+	}
+	for i := range f.trees {
+		sample := data
+		if sampleSize < len(data) {
+			sample = make([][]float64, sampleSize)
+			for j, k := range rng.Perm(len(data))[:sampleSize] {
+				sample[j] = data[k]
+			}
+		}
+		f.trees[i] = newIsolationNode(rng, sample, depth)
+	}
+	return f
+}
+
+// score returns the anomaly score between 0 and 1 of the point, where scores
+// over 0.5 are more anomalous than average.
+func (f *isolationForest) score(p []float64) float64 {
+	total := 0.0
+	for _, t := range f.trees {
+		total += t.pathLength(p, 0)
+	}
+	return math.Pow(2, -total/float64(len(f.trees))/f.c)
+}
+
+func newIsolationNode(rng *rand.Rand, data [][]float64, depth int) *isolationNode {
+	if len(data) <= 1 || depth <= 0 {
+		return &isolationNode{size: len(data)}
+	}
+	dims := len(data[0])
+	lo := append([]float64{}, data[0]...)
+	hi := append([]float64{}, data[0]...)
+	for _, v := range data {
+		for i, x := range v {
+			lo[i] = math.Min(lo[i], x)
+			hi[i] = math.Max(hi[i], x)
+		}
+	}
+	node := &isolationNode{
+		normal:    make([]float64, dims),
+		intercept: make([]float64, dims),
+	}
+	for i := range node.normal {
+		node.normal[i] = rng.NormFloat64()
+		node.intercept[i] = lo[i] + rng.Float64()*(hi[i]-lo[i])
+	}
+	var left, right [][]float64
+	for _, v := range data {
+		if node.isLeft(v) {
+			left = append(left, v)
+		} else {
+			right = append(right, v)
+		}
+	}
+	node.left = newIsolationNode(rng, left, depth-1)
+	node.right = newIsolationNode(rng, right, depth-1)
+	return node
+}
+
+func (n *isolationNode) isLeft(p []float64) bool {
+	d := 0.0
+	for i, x := range p {
+		d += (x - n.intercept[i]) * n.normal[i]
+	}
+	return d <= 0
+}
+
+func (n *isolationNode) pathLength(p []float64, depth int) float64 {
+	if n.left == nil {
+		return float64(depth) + averagePathLength(n.size)
+	}
+	if n.isLeft(p) {
+		return n.left.pathLength(p, depth+1)
+	}
+	return n.right.pathLength(p, depth+1)
+}
+
+// averagePathLength is the average path length of unsuccessful searches in a
+// binary search tree of n nodes.
+func averagePathLength(n int) float64 {
+	if n <= 1 {
+		return 0
+	}
+	if n == 2 {
+		return 1
+	}
+	return 2*(math.Log(float64(n-1))+0.5772156649) - 2*float64(n-1)/float64(n)
+}
@@ -0,0 +1,124 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestRemoveOutlierByEIF(t *testing.T) {
+	// walking 1 m/s eastward with a point every 10 seconds, where point[50]
+	// jumps 500 meters north, so both lines to point[50] and point[51] are fast
+	trkpts := ""
+	for i := 0; i < 100; i++ {
+		lat := 24.0
+		if i == 50 {
+			lat += 500.0 / 111195
+		}
+		lon := 121 + float64(i)*10/101582
+		trkpts += fmt.Sprintf(`<trkpt lat="%f" lon="%f"><ele>100</ele><time>2022-01-01T00:%02d:%02dZ</time></trkpt>`, lat, lon, i*10/60, i*10%60)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, trkpts)
+	reported := 0
+	run := func(threshold float64) (int, []float64) {
+		tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scores := make([]float64, 0)
+		eif := &RemoveOutlierByEIF{
+			Threshold:  threshold,
+			Features:   []string{"speed", "heading", "dt"},
+			SampleSize: 64,
+			Seed:       1,
+			Score: func(score *EIFScore) {
+				scores = append(scores, score.Score)
+			},
+			Report: func(outlier *Outlier) {
+				reported++
+			},
+		}
+		n, err := eif.Run(tracklog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n, scores
+	}
+	n, scores := run(0)
+	if n != 0 || len(scores) != 100 {
+		t.Fatalf("Unexpected result of scoring: %d, %d", n, len(scores))
+	}
+	for i, score := range scores {
+		if i != 50 && i != 51 && score >= scores[50] {
+			t.Fatalf("Unexpected score of point[%d]: %f >= %f", i, score, scores[50])
+		}
+	}
+	_, again := run(0)
+	if fmt.Sprint(again) != fmt.Sprint(scores) {
+		t.Fatal("Unexpected scores by the same seed")
+	}
+	n, _ = run(scores[50] - 0.01)
+	if n <= 0 || reported != n {
+		t.Fatalf("Unexpected number of outliers: %d, %d reported", n, reported)
+	}
+	_, err := (&RemoveOutlierByEIF{Features: []string{"foobar"}}).Run(&gpx.TrackLog{})
+	if err == nil {
+		t.Fatal("Unexpected success of unknown feature")
+	}
+}
+
+func TestRemoveOutlierByEIFOfPosition(t *testing.T) {
+	// walking 1 m/s eastward with irregular time gaps of 5 to 60 seconds,
+	// where point[50] is 200 meters north while its speed stays plausible, so
+	// only its position is anomalous
+	gaps := []int{5, 60, 10, 30}
+	trkpts := ""
+	elapsed, lon := 0, 121.0
+	for i := 0; i < 100; i++ {
+		gap := gaps[i%len(gaps)]
+		elapsed += gap
+		lon += float64(gap) / 101582
+		lat := 24.0
+		if i == 50 {
+			lat += 200.0 / 111195
+		}
+		trkpts += fmt.Sprintf(`<trkpt lat="%f" lon="%f"><time>2022-01-01T%02d:%02d:%02dZ</time></trkpt>`, lat, lon, elapsed/3600, elapsed/60%60, elapsed%60)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, trkpts)
+	run := func() []float64 {
+		tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		scores := make([]float64, 0)
+		eif := &RemoveOutlierByEIF{
+			Features: []string{"lat", "lon", "dt"},
+			Seed:     7,
+			Score: func(score *EIFScore) {
+				scores = append(scores, score.Score)
+			},
+		}
+		if _, err := eif.Run(tracklog); err != nil {
+			t.Fatal(err)
+		}
+		return scores
+	}
+	scores := run()
+	for i, score := range scores {
+		if i != 50 && score >= scores[50] {
+			t.Fatalf("Unexpected score of point[%d]: %f >= %f", i, score, scores[50])
+		}
+	}
+	// trees are seeded even if all points are fitted
+	if again := run(); fmt.Sprint(again) != fmt.Sprint(scores) {
+		t.Fatal("Unexpected scores by the same seed")
+	}
+}
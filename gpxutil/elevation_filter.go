@@ -0,0 +1,277 @@
+package gpxutil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/log"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ElevationFilters are the methods of FilterElevation.
+var ElevationFilters = []string{"savgol", "average", "median"}
+
+// FilterElevation smooths the elevation of track points over a horizontal
+// window (in meters) centered at each point. Points without elevation are
+// skipped and latitude, longitude and time are left untouched. Methods are:
+//
+//	savgol:  Savitzky–Golay, i.e. local polynomial least squares of Order
+//	average: distance-weighted moving average
+//	median:  median of the points in the window
+type FilterElevation struct {
+	Method string
+	Window float64
+	Order  int // polynomial order of savgol
+}
+
+func (c *FilterElevation) Name() string {
+	return fmt.Sprintf("Filter Elevation by %s over %.0fm", c.Method, c.Window)
+}
+
+func (c *FilterElevation) Run(tracklog *gpx.TrackLog) (int, error) {
+	if c.Window <= 0 {
+		return 0, fmt.Errorf("invalid window: %f", c.Window)
+	}
+	if c.Method == "savgol" && c.Order < 0 {
+		return 0, fmt.Errorf("invalid order: %d", c.Order)
+	}
+	n := 0
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			p, err := newProfile(pointsWithElevation(seg.Points))
+			if err != nil {
+				return n, err
+			}
+			var elevations []float64
+			switch c.Method {
+			case "savgol":
+				elevations = c.savgol(p)
+			case "average":
+				elevations = p.smoothedElevations(c.Window)
+			case "median":
+				elevations = c.median(p)
+			default:
+				return n, fmt.Errorf("unknown elevation filter: %s", c.Method)
+			}
+			for i, pt := range p.points {
+				if elevations[i] != pt.GetElevation() {
+					pt.Elevation = proto.Float64(elevations[i])
+					n++
+				}
+			}
+		}
+	}
+	return n, nil
+}
+
+// windows returns the range [from, to) of points within the window centered at
+// each point.
+func (c *FilterElevation) windows(p *profile) [][2]int {
+	windows := make([][2]int, len(p.points))
+	from, to := 0, 0
+	for i, chainage := range p.chainages {
+		for p.chainages[from] < chainage-c.Window/2 {
+			from++
+		}
+		for to < len(p.points) && p.chainages[to] <= chainage+c.Window/2 {
+			to++
+		}
+		windows[i] = [2]int{from, to}
+	}
+	return windows
+}
+
+func (c *FilterElevation) median(p *profile) []float64 {
+	elevations := make([]float64, len(p.points))
+	values := make([]float64, 0)
+	for i, w := range c.windows(p) {
+		values = values[:0]
+		for _, pt := range p.points[w[0]:w[1]] {
+			values = append(values, pt.GetElevation())
+		}
+		sort.Float64s(values)
+		m := len(values) / 2
+		if len(values)%2 == 0 {
+			elevations[i] = (values[m-1] + values[m]) / 2
+		} else {
+			elevations[i] = values[m]
+		}
+	}
+	return elevations
+}
+
+// savgol fits a polynomial of the order to the points in the window by least
+// squares and evaluates it at the center, which generalizes Savitzky–Golay to
+// unevenly spaced points. The order is lowered where there are too few points.
+func (c *FilterElevation) savgol(p *profile) []float64 {
+	elevations := make([]float64, len(p.points))
+	for i, w := range c.windows(p) {
+		order := c.Order
+		if n := w[1] - w[0]; order > n-1 {
+			order = n - 1
+		}
+		// normal equations of x^0..x^order, with x scaled by half the window
+		size := order + 1
+		a := make([][]float64, size)
+		for j := range a {
+			a[j] = make([]float64, size+1)
+		}
+		for k := w[0]; k < w[1]; k++ {
+			x := (p.chainages[k] - p.chainages[i]) / (c.Window / 2)
+			y := p.points[k].GetElevation()
+			powers := make([]float64, 2*size)
+			powers[0] = 1
+			for j := 1; j < len(powers); j++ {
+				powers[j] = powers[j-1] * x
+			}
+			for r := 0; r < size; r++ {
+				for s := 0; s < size; s++ {
+					a[r][s] += powers[r+s]
+				}
+				a[r][size] += powers[r] * y
+			}
+		}
+		coefficients, ok := solve(a)
+		if !ok {
+			log.Debugf("Singular Savitzky–Golay fit at point[%d]", i)
+			elevations[i] = p.points[i].GetElevation()
+			continue
+		}
+		elevations[i] = coefficients[0]
+	}
+	return elevations
+}
+
+// solve solves the augmented matrix of linear equations by Gaussian
+// elimination with partial pivoting.
+func solve(a [][]float64) ([]float64, bool) {
+	n := len(a)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for r := col + 1; r < n; r++ {
+			f := a[r][col] / a[col][col]
+			for k := col; k <= n; k++ {
+				a[r][k] -= f * a[col][k]
+			}
+		}
+	}
+	x := make([]float64, n)
+	for r := n - 1; r >= 0; r-- {
+		sum := a[r][n]
+		for k := r + 1; k < n; k++ {
+			sum -= a[r][k] * x[k]
+		}
+		x[r] = sum / a[r][r]
+	}
+	return x, true
+}
+
+// RemoveElevationSpikes replaces the elevation of spikes by linear
+// interpolation along the horizontal chainage. A spike is a run of points
+// lasting no longer than a minute, which are reached from the previous good
+// point faster than MaxVerticalSpeed (m/s), while the point right after them
+// is plausible again. Points without time are not checked.
+type RemoveElevationSpikes struct {
+	MaxVerticalSpeed float64
+}
+
+func (c *RemoveElevationSpikes) Name() string {
+	return fmt.Sprintf("Remove Elevation Spikes over %.2f m/s", c.MaxVerticalSpeed)
+}
+
+func (c *RemoveElevationSpikes) Run(tracklog *gpx.TrackLog) (int, error) {
+	if c.MaxVerticalSpeed <= 0 {
+		return 0, fmt.Errorf("invalid max vertical speed: %f", c.MaxVerticalSpeed)
+	}
+	n := 0
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			p, err := newProfile(pointsWithElevation(seg.Points))
+			if err != nil {
+				return n, err
+			}
+			n += c.remove(p)
+		}
+	}
+	return n, nil
+}
+
+func (c *RemoveElevationSpikes) remove(p *profile) int {
+	n := 0
+	good := 0
+	for i := 1; i < len(p.points); i++ {
+		if !c.implausible(p.points[good], p.points[i]) {
+			good = i
+			continue
+		}
+		end := -1
+		for j := i + 1; j < len(p.points); j++ {
+			if p.points[j].NanoTime == nil || p.points[i].NanoTime == nil || p.points[j].Time().Sub(p.points[i].Time()) > time.Minute {
+				break
+			}
+			if !c.implausible(p.points[good], p.points[j]) {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			// a sustained change rather than a spike
+			good = i
+			continue
+		}
+		a, b := p.points[good].GetElevation(), p.points[end].GetElevation()
+		dist := p.chainages[end] - p.chainages[good]
+		for k := i; k < end; k++ {
+			ratio := 0.5
+			if dist > 0 {
+				ratio = (p.chainages[k] - p.chainages[good]) / dist
+			}
+			log.Debugf("Elevation spike at point[%d]: %f", k, p.points[k].GetElevation())
+			p.points[k].Elevation = proto.Float64(a + (b-a)*ratio)
+			n++
+		}
+		good = end
+		i = end
+	}
+	return n
+}
+
+func (c *RemoveElevationSpikes) implausible(a, b *gpx.Point) bool {
+	return math.Abs(c.verticalSpeed(a, b)) > c.MaxVerticalSpeed
+}
+
+// verticalSpeed returns the vertical speed in m/s between the points, or 0 if
+// it is unknown.
+func (c *RemoveElevationSpikes) verticalSpeed(a, b *gpx.Point) float64 {
+	if a.NanoTime == nil || b.NanoTime == nil {
+		return 0
+	}
+	seconds := b.Time().Sub(a.Time()).Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return (b.GetElevation() - a.GetElevation()) / seconds
+}
+
+func pointsWithElevation(points []*gpx.Point) []*gpx.Point {
+	result := make([]*gpx.Point, 0, len(points))
+	for _, p := range points {
+		if p.Elevation != nil {
+			result = append(result, p)
+		}
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestElevationFilter(t *testing.T) {
+	// a constant 10% climb with a point every 10 meters and 10 seconds, where
+	// point[5] and point[6] are spikes and point[12] is 3 meters off
+	trkpts := ""
+	for i := 0; i < 20; i++ {
+		ele := float64(i)
+		switch i {
+		case 5, 6:
+			ele += 100
+		case 12:
+			ele += 3
+		}
+		trkpts += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"><ele>%f</ele><time>2022-01-01T00:%02d:%02dZ</time></trkpt>`,
+			24+float64(i)*10/111195, ele, i*10/60, i*10%60)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, trkpts)
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := tracklog.Tracks[0].Segments[0].Points
+	lat := points[5].GetLatitude()
+	spikes := &RemoveElevationSpikes{MaxVerticalSpeed: 2}
+	n, err := spikes.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Unexpected number of spikes: %d", n)
+	}
+	if math.Abs(points[5].GetElevation()-5) > 0.1 || math.Abs(points[6].GetElevation()-6) > 0.1 {
+		t.Fatalf("Unexpected elevation of spikes: %f, %f", points[5].GetElevation(), points[6].GetElevation())
+	}
+	if points[5].GetLatitude() != lat {
+		t.Fatalf("Unexpected latitude: %f", points[5].GetLatitude())
+	}
+	for _, method := range ElevationFilters {
+		tracklog, _ := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+		_, err := spikes.Run(tracklog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filter := &FilterElevation{Method: method, Window: 100, Order: 2}
+		_, err = filter.Run(tracklog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		points := tracklog.Tracks[0].Segments[0].Points
+		if e := points[12].GetElevation(); math.Abs(e-12) > 1 {
+			t.Fatalf("Unexpected elevation by %s: %f", method, e)
+		}
+	}
+	filter := &FilterElevation{Method: "foobar", Window: 60}
+	_, err = filter.Run(tracklog)
+	if err == nil {
+		t.Fatal("Unexpected success of unknown method")
+	}
+}
@@ -0,0 +1,75 @@
+package gpxutil
+
+import (
+	"time"
+
+	"gpxtoolkit/gpx"
+)
+
+// Leg is a part of a track between two waypoints with its estimated time.
+type Leg struct {
+	From, To   *gpx.WayPoint // nil for the start or end of the track
+	Distance   float64
+	Gain, Loss float64
+	Duration   time.Duration
+}
+
+// EstimateTime estimates the time of walking along points by a pace model.
+type EstimateTime struct {
+	Pace      PaceModel
+	Window    float64 // horizontal window in meters for smoothing the grade
+	Threshold float64 // waypoints farther than this (in meters) are not used for legs
+}
+
+// Duration estimates the time of walking along the points.
+func (e *EstimateTime) Duration(points []*gpx.Point) time.Duration {
+	return e.leg(points).Duration
+}
+
+// Legs slices the points by the waypoints and estimates the time of walking
+// each leg.
+func (e *EstimateTime) Legs(points []*gpx.Point, waypoints []*gpx.WayPoint) ([]*Leg, error) {
+	segments, err := sliceByWaypoints(HaversinMode, points, waypoints, e.Threshold, true)
+	if err != nil {
+		return nil, err
+	}
+	legs := make([]*Leg, 0, len(segments))
+	for _, seg := range segments {
+		leg := e.leg(seg.points)
+		leg.From = seg.a.waypoint
+		leg.To = seg.b.waypoint
+		legs = append(legs, leg)
+	}
+	return legs, nil
+}
+
+func (e *EstimateTime) leg(points []*gpx.Point) *Leg {
+	leg := &Leg{}
+	for _, l := range e.lines(points) {
+		dele := l.Grade * l.Distance
+		if dele > 0 {
+			leg.Gain += dele
+		} else {
+			leg.Loss -= dele
+		}
+		leg.Distance += l.Distance
+		leg.Duration += e.Pace.Duration(l.Distance, dele)
+	}
+	return leg
+}
+
+// lines returns the lines between the points with smoothed grades, or flat
+// lines if any point is missing elevation.
+func (e *EstimateTime) lines(points []*gpx.Point) []*GradeLine {
+	lines, err := Grades(points, e.Window)
+	if err == nil {
+		return lines
+	}
+	lines = make([]*GradeLine, 0)
+	chainage := 0.0
+	for _, l := range getLines(HaversinMode, points) {
+		lines = append(lines, &GradeLine{A: l.a, B: l.b, Chainage: chainage, Distance: l.dist})
+		chainage += l.dist
+	}
+	return lines
+}
@@ -0,0 +1,170 @@
+package gpxutil
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gpxtoolkit/gpx"
+
+	"github.com/maja42/goval"
+)
+
+// FilterVariables are the variables of a point available to filter
+// expressions, most of which are of the line from the previous point. They
+// are 0 for the first point, or if time or elevation is missing.
+type FilterVariables struct {
+	Index     int
+	Distance  float64 // meters from the previous point
+	Duration  float64 // seconds from the previous point
+	Speed     float64 // meters per second from the previous point
+	Grade     float64 // elevation delta over distance, e.g. 0.1 for 10%
+	Elevation float64
+	DeltaEle  float64 // elevation delta from the previous point
+	Time      float64 // unix time in seconds
+	Hour      float64 // hour of the day in the time zone, e.g. 13.5 for 13:30
+	Heading   float64 // degrees clockwise from north from the previous point
+}
+
+func (v *FilterVariables) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"index":   v.Index,
+		"dist":    v.Distance,
+		"dt":      v.Duration,
+		"speed":   v.Speed,
+		"grade":   v.Grade,
+		"ele":     v.Elevation,
+		"dele":    v.DeltaEle,
+		"time":    v.Time,
+		"hour":    v.Hour,
+		"heading": v.Heading,
+	}
+}
+
+// PointFilter is a goval expression over FilterVariables, e.g. `speed > 5`.
+// Points matching the expression are removed, or points not matching it if
+// Keep is true.
+type PointFilter struct {
+	Expression string
+	Keep       bool
+	Removed    int // number of points removed by this filter
+}
+
+func (f *PointFilter) Eval(variables *FilterVariables) (bool, error) {
+	val, err := goval.NewEvaluator().Evaluate(f.Expression, variables.Map(), functions)
+	if err != nil {
+		return false, err
+	}
+	b, ok := val.(bool)
+	if !ok {
+		return false, fmt.Errorf("unexpected result of '%s': %v", f.Expression, val)
+	}
+	return b, nil
+}
+
+// Validate evaluates the filter with sample variables to check its syntax.
+func (f *PointFilter) Validate() error {
+	_, err := f.Eval(&FilterVariables{
+		Index:     1,
+		Distance:  10,
+		Duration:  5,
+		Speed:     2,
+		Grade:     0.1,
+		Elevation: 1000,
+		DeltaEle:  1,
+		Time:      1640995200,
+		Hour:      12,
+		Heading:   90,
+	})
+	return err
+}
+
+// FilterPoints removes points by the filters, each of which counts the points
+// it would remove regardless of the other filters.
+type FilterPoints struct {
+	Filters  []*PointFilter
+	Location *time.Location // time zone of hour; UTC if nil
+}
+
+func (c *FilterPoints) Name() string {
+	return "Filter Points by Expressions"
+}
+
+func (c *FilterPoints) Run(tracklog *gpx.TrackLog) (int, error) {
+	n := 0
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			points := make([]*gpx.Point, 0, len(seg.Points))
+			for i, p := range seg.Points {
+				var prev *gpx.Point
+				if i > 0 {
+					prev = seg.Points[i-1]
+				}
+				vars := c.variables(i, prev, p)
+				remove := false
+				for _, f := range c.Filters {
+					match, err := f.Eval(vars)
+					if err != nil {
+						return n, fmt.Errorf("failed to evaluate '%s' at point[%d]: %w", f.Expression, i, err)
+					}
+					if match != f.Keep {
+						f.Removed++
+						remove = true
+					}
+				}
+				if remove {
+					n++
+					continue
+				}
+				points = append(points, p)
+			}
+			seg.Points = points
+		}
+	}
+	return n, nil
+}
+
+func (c *FilterPoints) variables(index int, prev, p *gpx.Point) *FilterVariables {
+	v := &FilterVariables{
+		Index:     index,
+		Elevation: p.GetElevation(),
+	}
+	if p.NanoTime != nil {
+		tm := p.Time()
+		if c.Location != nil {
+			tm = tm.In(c.Location)
+		}
+		v.Time = float64(p.GetNanoTime()) / float64(time.Second)
+		v.Hour = float64(tm.Hour()) + float64(tm.Minute())/60 + float64(tm.Second())/3600
+	}
+	if prev == nil {
+		return v
+	}
+	v.Distance = HaversinDistance(prev, p)
+	v.Heading = bearing(prev, p)
+	if prev.NanoTime != nil && p.NanoTime != nil {
+		v.Duration = p.Time().Sub(prev.Time()).Seconds()
+		if v.Duration > 0 {
+			v.Speed = v.Distance / v.Duration
+		}
+	}
+	if prev.Elevation != nil && p.Elevation != nil {
+		v.DeltaEle = p.GetElevation() - prev.GetElevation()
+		if v.Distance > 0 {
+			v.Grade = v.DeltaEle / v.Distance
+		}
+	}
+	return v
+}
+
+// bearing returns the initial bearing in degrees clockwise from north from a
+// to b.
+func bearing(a, b *gpx.Point) float64 {
+	lat1 := a.GetLatitude() * math.Pi / 180
+	lat2 := b.GetLatitude() * math.Pi / 180
+	dlon := (b.GetLongitude() - a.GetLongitude()) * math.Pi / 180
+	y := math.Sin(dlon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dlon)
+	deg := math.Atan2(y, x) * 180 / math.Pi
+	return math.Mod(deg+360, 360)
+}
@@ -0,0 +1,58 @@
+package gpxutil
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestFilterPoints(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk>
+	<trkseg>
+	<trkpt lat="24.0000" lon="121.0"><ele>100</ele><time>2022-01-01T04:00:00Z</time></trkpt>
+	<trkpt lat="24.0001" lon="121.0"><ele>101</ele><time>2022-01-01T04:00:10Z</time></trkpt>
+	<trkpt lat="24.0100" lon="121.0"><ele>102</ele><time>2022-01-01T04:00:20Z</time></trkpt>
+	<trkpt lat="24.0101" lon="121.0"><ele>103</ele><time>2022-01-01T10:00:30Z</time></trkpt>
+	</trkseg>
+</trk>
+</gpx>`
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	location, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := &FilterPoints{
+		Filters: []*PointFilter{
+			{Expression: "speed > 10"},
+			{Expression: "hour < 18", Keep: true},
+		},
+		Location: location,
+	}
+	vars := filter.variables(1, tracklog.Tracks[0].Segments[0].Points[0], tracklog.Tracks[0].Segments[0].Points[1])
+	if math.Abs(vars.Distance-11.1) > 0.1 || math.Abs(vars.Grade-0.09) > 0.01 || math.Abs(vars.Hour-(12+1.0/360)) > 1e-9 || math.Abs(vars.Heading) > 1e-6 {
+		t.Fatalf("Unexpected variables: %+v", vars)
+	}
+	for _, f := range filter.Filters {
+		if err := f.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := filter.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || filter.Filters[0].Removed != 1 || filter.Filters[1].Removed != 1 {
+		t.Fatalf("Unexpected number of removed points: %d, %d, %d", n, filter.Filters[0].Removed, filter.Filters[1].Removed)
+	}
+	if err := (&PointFilter{Expression: "speed + 1"}).Validate(); err == nil {
+		t.Fatal("Unexpected success of non-boolean expression")
+	}
+}
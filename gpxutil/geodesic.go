@@ -0,0 +1,202 @@
+package gpxutil
+
+import (
+	"math"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// WGS84 ellipsoid
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+	wgs84B = wgs84A * (1 - wgs84F)
+)
+
+// GeodesicDistance returns the distance in meters on the WGS84 ellipsoid by
+// Vincenty's inverse formula, or the haversine distance for nearly antipodal
+// points where it does not converge. Lines in GeodesicMode are also
+// interpolated and projected along the geodesic instead of by lat/lon.
+func GeodesicDistance(a, b *gpx.Point) float64 {
+	s, _, ok := vincentyInverse(a.GetLatitude(), a.GetLongitude(), b.GetLatitude(), b.GetLongitude())
+	if !ok {
+		return HaversinDistance(a, b)
+	}
+	return s
+}
+
+// interpolateBy interpolates along the geodesic in GeodesicMode, or by lat/lon
+// otherwise.
+func interpolateBy(mode DistanceMode, a, b *gpx.Point, ratio float64) *gpx.Point {
+	if mode == GeodesicMode {
+		return geodesicInterpolate(a, b, ratio)
+	}
+	return interpolate(a, b, ratio)
+}
+
+// geodesicInterpolate returns the point at the ratio of the geodesic from a to
+// b, whose elevation and time are interpolated linearly.
+func geodesicInterpolate(a, b *gpx.Point, ratio float64) *gpx.Point {
+	p := interpolate(a, b, ratio)
+	lat1, lon1 := a.GetLatitude(), a.GetLongitude()
+	s, azimuth, ok := vincentyInverse(lat1, lon1, b.GetLatitude(), b.GetLongitude())
+	var lat, lon float64
+	if ok {
+		lat, lon = vincentyDirect(lat1, lon1, azimuth, s*ratio)
+	} else {
+		lat, lon = slerp(a, b, ratio)
+	}
+	p.Latitude = proto.Float64(lat)
+	p.Longitude = proto.Float64(lon)
+	return p
+}
+
+// geodesicClosestPoint returns the point on the geodesic from a to b closest
+// to c, where the along-track ratio is found on the sphere.
+func geodesicClosestPoint(a, b, c *gpx.Point) *gpx.Point {
+	va, vb, vc := toNVector(a), toNVector(b), toNVector(c)
+	n := cross(va, vb)
+	ab := norm(n)
+	if ab == 0 {
+		return a
+	}
+	for i := range n {
+		n[i] /= ab
+	}
+	// c projected onto the plane of the great circle
+	d := dot(vc, n)
+	vp := [3]float64{vc[0] - d*n[0], vc[1] - d*n[1], vc[2] - d*n[2]}
+	ratio := math.Atan2(dot(cross(va, vp), n), dot(va, vp)) / math.Atan2(ab, dot(va, vb))
+	if ratio <= 0 {
+		return a
+	} else if ratio >= 1 {
+		return b
+	}
+	return geodesicInterpolate(a, b, ratio)
+}
+
+// vincentyInverse returns the distance and the initial azimuth in radians
+// between the points, or false if it does not converge.
+func vincentyInverse(lat1, lon1, lat2, lon2 float64) (float64, float64, bool) {
+	L := (lon2 - lon1) * math.Pi / 180
+	U1 := math.Atan((1 - wgs84F) * math.Tan(lat1*math.Pi/180))
+	U2 := math.Atan((1 - wgs84F) * math.Tan(lat2*math.Pi/180))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+	lambda := L
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, cos2Alpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		x := cosU2 * sinLambda
+		y := cosU1*sinU2 - sinU1*cosU2*cosLambda
+		sinSigma = math.Sqrt(x*x + y*y)
+		if sinSigma == 0 {
+			return 0, 0, true
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cos2Alpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		C := wgs84F / 16 * cos2Alpha * (4 + wgs84F*(4-3*cos2Alpha))
+		prev := lambda
+		lambda = L + (1-C)*wgs84F*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return 0, 0, false
+	}
+	A, B := vincentyAB(cos2Alpha)
+	deltaSigma := vincentyDeltaSigma(B, sinSigma, cosSigma, cos2SigmaM)
+	s := wgs84B * A * (sigma - deltaSigma)
+	azimuth := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	return s, azimuth, true
+}
+
+// vincentyDirect returns the point at the distance from the point along the
+// initial azimuth in radians.
+func vincentyDirect(lat1, lon1, azimuth, s float64) (float64, float64) {
+	sinAlpha1, cosAlpha1 := math.Sincos(azimuth)
+	tanU1 := (1 - wgs84F) * math.Tan(lat1*math.Pi/180)
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cos2Alpha := 1 - sinAlpha*sinAlpha
+	A, B := vincentyAB(cos2Alpha)
+	sigma := s / (wgs84B * A)
+	var sinSigma, cosSigma, cos2SigmaM float64
+	for i := 0; i < 200; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+		prev := sigma
+		sigma = s/(wgs84B*A) + vincentyDeltaSigma(B, sinSigma, cosSigma, cos2SigmaM)
+		if math.Abs(sigma-prev) < 1e-12 {
+			break
+		}
+	}
+	sinSigma, cosSigma = math.Sincos(sigma)
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	lat := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1, (1-wgs84F)*math.Sqrt(sinAlpha*sinAlpha+x*x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	C := wgs84F / 16 * cos2Alpha * (4 + wgs84F*(4-3*cos2Alpha))
+	L := lambda - (1-C)*wgs84F*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+	lon := math.Mod(lon1+L*180/math.Pi+540, 360) - 180
+	return lat * 180 / math.Pi, lon
+}
+
+func vincentyAB(cos2Alpha float64) (float64, float64) {
+	u2 := cos2Alpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	A := 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+	B := u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+	return A, B
+}
+
+func vincentyDeltaSigma(B, sinSigma, cosSigma, cos2SigmaM float64) float64 {
+	return B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+}
+
+// slerp returns the lat/lon at the ratio of the great circle from a to b on
+// the sphere.
+func slerp(a, b *gpx.Point, ratio float64) (float64, float64) {
+	va, vb := toNVector(a), toNVector(b)
+	omega := math.Atan2(norm(cross(va, vb)), dot(va, vb))
+	if omega == 0 {
+		return a.GetLatitude(), a.GetLongitude()
+	}
+	ka := math.Sin((1-ratio)*omega) / math.Sin(omega)
+	kb := math.Sin(ratio*omega) / math.Sin(omega)
+	v := [3]float64{ka*va[0] + kb*vb[0], ka*va[1] + kb*vb[1], ka*va[2] + kb*vb[2]}
+	lat := math.Atan2(v[2], math.Hypot(v[0], v[1]))
+	lon := math.Atan2(v[1], v[0])
+	return lat * 180 / math.Pi, lon * 180 / math.Pi
+}
+
+// toNVector returns the unit vector normal to the sphere at the point.
+func toNVector(p *gpx.Point) [3]float64 {
+	sinLat, cosLat := math.Sincos(p.GetLatitude() * math.Pi / 180)
+	sinLon, cosLon := math.Sincos(p.GetLongitude() * math.Pi / 180)
+	return [3]float64{cosLat * cosLon, cosLat * sinLon, sinLat}
+}
+
+func cross(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func norm(v [3]float64) float64 {
+	return math.Sqrt(dot(v, v))
+}
@@ -0,0 +1,69 @@
+package gpxutil
+
+import (
+	"math"
+	"testing"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestGeodesicDistance(t *testing.T) {
+	// Flinders Peak to Buninyong, the example of Vincenty's formulae
+	a := &gpx.Point{Latitude: proto.Float64(-(37 + 57/60.0 + 3.72030/3600)), Longitude: proto.Float64(144 + 25/60.0 + 29.52440/3600)}
+	b := &gpx.Point{Latitude: proto.Float64(-(37 + 39/60.0 + 10.15610/3600)), Longitude: proto.Float64(143 + 55/60.0 + 35.38390/3600)}
+	if d := GeodesicDistance(a, b); math.Abs(d-54972.271) > 0.001 {
+		t.Fatalf("Unexpected geodesic distance: %f", d)
+	}
+	_, azimuth, _ := vincentyInverse(a.GetLatitude(), a.GetLongitude(), b.GetLatitude(), b.GetLongitude())
+	if deg := math.Mod(azimuth*180/math.Pi+360, 360); math.Abs(deg-(306+52/60.0+5.37/3600)) > 1e-4 {
+		t.Fatalf("Unexpected azimuth: %f", deg)
+	}
+}
+
+func TestGeodesicInterpolate(t *testing.T) {
+	// Taipei to Kinmen
+	a := &gpx.Point{Latitude: proto.Float64(25.0330), Longitude: proto.Float64(121.5654), Elevation: proto.Float64(0), NanoTime: proto.Int64(0)}
+	b := &gpx.Point{Latitude: proto.Float64(24.4321), Longitude: proto.Float64(118.3171), Elevation: proto.Float64(100), NanoTime: proto.Int64(1000)}
+	total := GeodesicDistance(a, b)
+	p := interpolateBy(GeodesicMode, a, b, 0.25)
+	if d := GeodesicDistance(a, p); math.Abs(d-total/4) > 0.001 {
+		t.Fatalf("Unexpected distance to interpolated point: %f of %f", d, total)
+	}
+	if d := GeodesicDistance(p, b); math.Abs(d-total*3/4) > 0.001 {
+		t.Fatalf("Unexpected distance from interpolated point: %f of %f", d, total)
+	}
+	if p.GetElevation() != 25 || p.GetNanoTime() != 250 {
+		t.Fatalf("Unexpected interpolated elevation or time: %f, %d", p.GetElevation(), p.GetNanoTime())
+	}
+	// lat/lon interpolation is off the geodesic by hundreds of meters
+	if d := GeodesicDistance(p, interpolate(a, b, 0.25)); d < 100 {
+		t.Fatalf("Unexpected difference from lat/lon interpolation: %f", d)
+	}
+
+	lines := getLines(GeodesicMode, []*gpx.Point{a, b})
+	mid := lines[0].interpolate(0.5)
+	// a point 5 km off the middle of the line
+	_, azimuth, _ := vincentyInverse(mid.GetLatitude(), mid.GetLongitude(), b.GetLatitude(), b.GetLongitude())
+	lat, lon := vincentyDirect(mid.GetLatitude(), mid.GetLongitude(), azimuth+math.Pi/2, 5000)
+	c := &gpx.Point{Latitude: proto.Float64(lat), Longitude: proto.Float64(lon)}
+	prj := lines[0].closestPoint(c)
+	if d := GeodesicDistance(prj, mid); d > 50 {
+		t.Fatalf("Unexpected projection %f meters from the middle", d)
+	}
+	if d := GeodesicDistance(prj, c); math.Abs(d-5000) > 10 {
+		t.Fatalf("Unexpected distance to projection: %f", d)
+	}
+}
+
+func TestDistanceMode(t *testing.T) {
+	a := &gpx.Point{Latitude: proto.Float64(24.0), Longitude: proto.Float64(121.0), Elevation: proto.Float64(0)}
+	b := &gpx.Point{Latitude: proto.Float64(24.0), Longitude: proto.Float64(121.001), Elevation: proto.Float64(100)}
+	if d := getLines(TerrainMode, []*gpx.Point{a, b})[0].dist; d != TerrainDistance(a, b) {
+		t.Fatalf("Unexpected distance in terrain mode: %f", d)
+	}
+	if l := getLines(GeodesicMode, []*gpx.Point{a, b})[0]; !l.geodesic {
+		t.Fatalf("Unexpected line by lat/lon in geodesic mode")
+	}
+}
@@ -0,0 +1,152 @@
+package gpxutil
+
+import (
+	"math"
+
+	"gpxtoolkit/gpx"
+)
+
+// GradeLine is a line between two consecutive points with its smoothed grade.
+type GradeLine struct {
+	A, B     *gpx.Point
+	Chainage float64 // horizontal distance from the first point to A in meters
+	Distance float64 // horizontal distance from A to B in meters
+	Grade    float64 // rise over run, e.g. 0.15 for 15%
+}
+
+// Grades calculates the grade of every line between the points. The rise is
+// taken over a horizontal window (in meters) centered at the middle of each
+// line to smooth out elevation noise; a non-positive window uses the rise of
+// the line itself.
+func Grades(points []*gpx.Point, window float64) ([]*GradeLine, error) {
+	prof, err := newProfile(points)
+	if err != nil {
+		return nil, err
+	}
+	return prof.grades(window), nil
+}
+
+func (p *profile) grades(window float64) []*GradeLine {
+	if len(p.points) <= 1 {
+		return []*GradeLine{}
+	}
+	total := p.length()
+	lines := make([]*GradeLine, len(p.points)-1)
+	for i, b := range p.points[1:] {
+		a := p.points[i]
+		line := &GradeLine{
+			A:        a,
+			B:        b,
+			Chainage: p.chainages[i],
+			Distance: p.chainages[i+1] - p.chainages[i],
+		}
+		from, to := p.chainages[i], p.chainages[i+1]
+		if window > 0 && window > line.Distance {
+			mid := (from + to) / 2
+			from = math.Max(0, mid-window/2)
+			to = math.Min(total, mid+window/2)
+		}
+		if to > from {
+			line.Grade = (p.elevationAt(to) - p.elevationAt(from)) / (to - from)
+		}
+		lines[i] = line
+	}
+	return lines
+}
+
+// GradeBucket is the distance of lines whose absolute grade falls in [Min, Max).
+type GradeBucket struct {
+	Min, Max float64
+	Distance float64
+}
+
+// GradeHistogram accumulates distance by grade buckets.
+type GradeHistogram struct {
+	Buckets  []*GradeBucket
+	Distance float64
+}
+
+// NewGradeHistogram creates a histogram with buckets split by the ascending
+// bounds, e.g. 0.15, 0.25 and 0.35 creates [0,15%), [15%,25%), [25%,35%) and
+// [35%,∞).
+func NewGradeHistogram(bounds []float64) *GradeHistogram {
+	h := &GradeHistogram{
+		Buckets: make([]*GradeBucket, 0, len(bounds)+1),
+	}
+	min := 0.0
+	for _, b := range bounds {
+		if b <= min {
+			continue
+		}
+		h.Buckets = append(h.Buckets, &GradeBucket{Min: min, Max: b})
+		min = b
+	}
+	h.Buckets = append(h.Buckets, &GradeBucket{Min: min, Max: math.Inf(1)})
+	return h
+}
+
+func (h *GradeHistogram) Add(lines []*GradeLine) {
+	for _, l := range lines {
+		grade := math.Abs(l.Grade)
+		for _, b := range h.Buckets {
+			if grade >= b.Min && grade < b.Max {
+				b.Distance += l.Distance
+				break
+			}
+		}
+		h.Distance += l.Distance
+	}
+}
+
+// GradeSection is a continuous part of the points with its average grade.
+type GradeSection struct {
+	Start, End                 *gpx.Point
+	StartChainage, EndChainage float64
+	Gain                       float64 // elevation difference from Start to End
+	Grade                      float64
+}
+
+// SteepestSection finds the section of the given horizontal length with the
+// largest absolute average grade, either climbing or descending. It returns
+// nil if the points are shorter than the length.
+func SteepestSection(points []*gpx.Point, length float64) (*GradeSection, error) {
+	prof, err := newProfile(points)
+	if err != nil {
+		return nil, err
+	}
+	return prof.steepestSection(length), nil
+}
+
+func (p *profile) steepestSection(length float64) *GradeSection {
+	total := p.length()
+	if length <= 0 || total < length {
+		return nil
+	}
+	best := -1.0
+	bestStart := 0.0
+	try := func(start float64) {
+		if start < 0 || start+length > total {
+			return
+		}
+		gain := math.Abs(p.elevationAt(start+length) - p.elevationAt(start))
+		if gain > best {
+			best = gain
+			bestStart = start
+		}
+	}
+	// the extremum of a piecewise linear profile is reached when either end
+	// of the window sits on a point
+	for _, c := range p.chainages {
+		try(c)
+		try(c - length)
+	}
+	gain := p.elevationAt(bestStart+length) - p.elevationAt(bestStart)
+	return &GradeSection{
+		Start:         p.pointAt(bestStart),
+		End:           p.pointAt(bestStart + length),
+		StartChainage: bestStart,
+		EndChainage:   bestStart + length,
+		Gain:          gain,
+		Grade:         gain / length,
+	}
+}
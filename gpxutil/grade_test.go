@@ -0,0 +1,66 @@
+package gpxutil
+
+import (
+	"bytes"
+	"gpxtoolkit/gpx"
+	"math"
+	"testing"
+)
+
+func TestGrades(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk>
+	<trkseg>
+	<trkpt lat="24.0000" lon="121.0"><ele>0</ele></trkpt>
+	<trkpt lat="24.0010" lon="121.0"><ele>10</ele></trkpt>
+	<trkpt lat="24.0020" lon="121.0"><ele>20</ele></trkpt>
+	<trkpt lat="24.0030" lon="121.0"><ele>60</ele></trkpt>
+	<trkpt lat="24.0040" lon="121.0"><ele>100</ele></trkpt>
+	</trkseg>
+</trk>
+</gpx>`
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := tracklog.Tracks[0].Segments[0].Points
+	lines, err := Grades(points, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("Unexpected number of lines: %d", len(lines))
+	}
+	if math.Round(lines[0].Grade*100) != 9 {
+		t.Fatalf("Unexpected grade: %f", lines[0].Grade)
+	}
+	if math.Round(lines[3].Grade*100) != 36 {
+		t.Fatalf("Unexpected grade: %f", lines[3].Grade)
+	}
+	histogram := NewGradeHistogram([]float64{0.15, 0.25, 0.35})
+	histogram.Add(lines)
+	if math.Round(histogram.Buckets[0].Distance) != 222 {
+		t.Fatalf("Unexpected distance: %f", histogram.Buckets[0].Distance)
+	}
+	if math.Round(histogram.Buckets[3].Distance) != 222 {
+		t.Fatalf("Unexpected distance: %f", histogram.Buckets[3].Distance)
+	}
+	section, err := SteepestSection(points, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Round(section.Gain) != 72 {
+		t.Fatalf("Unexpected gain: %f", section.Gain)
+	}
+	if math.Round(section.StartChainage) != 222 {
+		t.Fatalf("Unexpected start: %f", section.StartChainage)
+	}
+	section, err = SteepestSection(points, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if section != nil {
+		t.Fatalf("Unexpected section: %v", section)
+	}
+}
@@ -13,10 +13,9 @@ import (
 )
 
 type Interpolate struct {
-	Service           elevation.Service
-	Distance          float64
-	ByTerrainDistance bool
-	distanceFunc      DistanceFunc
+	Service      elevation.Service
+	Distance     float64
+	DistanceMode DistanceMode
 }
 
 func (c *Interpolate) Name() string {
@@ -24,11 +23,6 @@ func (c *Interpolate) Name() string {
 }
 
 func (c *Interpolate) Run(tracklog *gpx.TrackLog) (int, error) {
-	if c.ByTerrainDistance {
-		c.distanceFunc = TerrainDistance
-	} else {
-		c.distanceFunc = HaversinDistance
-	}
 	n := 0
 	for _, t := range tracklog.Tracks {
 		for _, seg := range t.Segments {
@@ -46,7 +40,7 @@ func (c *Interpolate) Run(tracklog *gpx.TrackLog) (int, error) {
 
 func (c *Interpolate) interpolate(points []*gpx.Point) ([]*gpx.Point, error) {
 	interpolated := make([]*gpx.Point, 0)
-	lines := getLines(c.distanceFunc, points)
+	lines := getLines(c.DistanceMode, points)
 	res := make([]*gpx.Point, 0)
 	for _, line := range lines {
 		res = append(res, line.a)
@@ -55,7 +49,7 @@ func (c *Interpolate) interpolate(points []*gpx.Point) ([]*gpx.Point, error) {
 			continue
 		}
 		for i := 1; i <= num; i++ {
-			p := interpolate(line.a, line.b, float64(i)/float64(num+1))
+			p := line.interpolate(float64(i) / float64(num+1))
 			interpolated = append(interpolated, p)
 			res = append(res, p)
 		}
@@ -93,7 +87,19 @@ func interpolate(a, b *gpx.Point, ratio float64) *gpx.Point {
 		p.Elevation = proto.Float64(ele1 + dele*ratio)
 	}
 	if a.NanoTime != nil && b.NanoTime != nil {
-		p.NanoTime = proto.Int64(t1.Add(dt * time.Duration(ratio)).UnixNano())
+		p.NanoTime = proto.Int64(t1.Add(time.Duration(float64(dt) * ratio)).UnixNano())
+	}
+	return p
+}
+
+// interpolateAt returns the point at the time on the line from a to b.
+func interpolateAt(a, b *gpx.Point, t time.Time) *gpx.Point {
+	dt := b.Time().Sub(a.Time())
+	ratio := 0.0
+	if dt != 0 {
+		ratio = float64(t.Sub(a.Time())) / float64(dt)
 	}
+	p := interpolate(a, b, ratio)
+	p.NanoTime = proto.Int64(t.UnixNano())
 	return p
 }
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"gpxtoolkit/gpx"
 	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
 )
 
 func TestInterpolate(t *testing.T) {
@@ -33,3 +36,12 @@ func TestInterpolate(t *testing.T) {
 		t.Fatalf("Unexpected interpolated number: %d", n)
 	}
 }
+
+func TestInterpolateTime(t *testing.T) {
+	a := &gpx.Point{Latitude: proto.Float64(24), Longitude: proto.Float64(121), NanoTime: proto.Int64(0)}
+	b := &gpx.Point{Latitude: proto.Float64(25), Longitude: proto.Float64(121), NanoTime: proto.Int64(int64(100 * time.Second))}
+	p := interpolate(a, b, 0.25)
+	if p.GetNanoTime() != int64(25*time.Second) {
+		t.Fatalf("Unexpected interpolated time: %v", p.Time())
+	}
+}
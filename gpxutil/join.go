@@ -0,0 +1,181 @@
+package gpxutil
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Gap is a gap between joined segments farther than the threshold.
+type Gap struct {
+	From, To *gpx.Point
+	Distance float64 // meters
+}
+
+// JoinTracks joins all the segments of all the tracks into a single track in
+// the order of endpoint proximity. It starts from the most isolated endpoint,
+// and repeatedly appends the segment with the closest endpoint, reversed if it
+// is the last point. Gaps within Threshold meters are bridged with points
+// interpolated at most every Interval meters if positive, or snapped to their
+// middle point if Snap is true. Larger gaps start new segments and are kept in
+// Gaps. A segment starting at the same point as the previous one ends skips
+// the duplicate point. Since time would run backwards along a reversed
+// segment, segments with time are never reversed: they are ordered by time if
+// all the segments have time. Only if ClearTimes is true, segments with time
+// are ordered by endpoint proximity too, the times of the reversed ones are
+// cleared, and the number of such segments is kept in Untimed.
+type JoinTracks struct {
+	Threshold  float64
+	Interval   float64
+	Snap       bool
+	ClearTimes bool
+	Gaps       []*Gap
+	Untimed    int
+}
+
+func (c *JoinTracks) Name() string {
+	return fmt.Sprintf("Join Tracks within %.0fm", c.Threshold)
+}
+
+// Run returns the number of joined segments.
+func (c *JoinTracks) Run(tracklog *gpx.TrackLog) (int, error) {
+	pieces := make([][]*gpx.Point, 0)
+	var name *string
+	for _, t := range tracklog.Tracks {
+		if name == nil && t.Name != nil {
+			name = t.Name
+		}
+		for _, seg := range t.Segments {
+			if len(seg.Points) > 0 {
+				pieces = append(pieces, seg.Points)
+			}
+		}
+	}
+	c.Gaps = make([]*Gap, 0)
+	c.Untimed = 0
+	if len(pieces) <= 0 {
+		return 0, nil
+	}
+	ordered := c.order(pieces)
+	segments := make([]*gpx.Segment, 0)
+	current := &gpx.Segment{Points: slices.Clone(ordered[0])}
+	for _, piece := range ordered[1:] {
+		last := current.Points[len(current.Points)-1]
+		dist := HaversinDistance(last, piece[0])
+		if dist > c.Threshold {
+			c.Gaps = append(c.Gaps, &Gap{From: last, To: piece[0], Distance: dist})
+			segments = append(segments, current)
+			current = &gpx.Segment{Points: slices.Clone(piece)}
+			continue
+		}
+		if dist < 1e-3 {
+			// the same point as the last one
+			current.Points = append(current.Points, piece[1:]...)
+			continue
+		}
+		if c.Snap {
+			current.Points[len(current.Points)-1] = interpolate(last, piece[0], 0.5)
+			current.Points = append(current.Points, piece[1:]...)
+			continue
+		}
+		if c.Interval > 0 {
+			num := int(math.Ceil(dist / c.Interval))
+			for i := 1; i < num; i++ {
+				current.Points = append(current.Points, interpolate(last, piece[0], float64(i)/float64(num)))
+			}
+		}
+		current.Points = append(current.Points, piece...)
+	}
+	segments = append(segments, current)
+	tracklog.Tracks = []*gpx.Track{{Name: name, Segments: segments}}
+	return len(pieces), nil
+}
+
+// order returns the pieces in the order of time if all of them have time, or
+// in the order of endpoint proximity, reversed if needed and allowed.
+func (c *JoinTracks) order(pieces [][]*gpx.Point) [][]*gpx.Point {
+	timed := func(piece []*gpx.Point) bool {
+		return slices.ContainsFunc(piece, func(p *gpx.Point) bool { return p.NanoTime != nil })
+	}
+	if !c.ClearTimes && !slices.ContainsFunc(pieces, func(piece []*gpx.Point) bool { return !timed(piece) }) {
+		start := func(piece []*gpx.Point) int64 {
+			return piece[slices.IndexFunc(piece, func(p *gpx.Point) bool { return p.NanoTime != nil })].GetNanoTime()
+		}
+		ordered := slices.Clone(pieces)
+		slices.SortStableFunc(ordered, func(a, b []*gpx.Point) int {
+			return cmp.Compare(start(a), start(b))
+		})
+		return ordered
+	}
+	ends := func(piece []*gpx.Point) [2]*gpx.Point {
+		return [2]*gpx.Point{piece[0], piece[len(piece)-1]}
+	}
+	// pieces with time are reversed only if their times may be cleared
+	reversible := func(i int) bool {
+		return c.ClearTimes || !timed(pieces[i])
+	}
+	// the most isolated endpoint, which is farthest from the endpoints of the
+	// other pieces
+	first, reversed, farthest := 0, false, -1.0
+	for i, a := range pieces {
+		for e, p := range ends(a) {
+			closest := math.Inf(1)
+			for j, b := range pieces {
+				if i == j {
+					continue
+				}
+				for _, q := range ends(b) {
+					closest = math.Min(closest, HaversinDistance(p, q))
+				}
+			}
+			if closest > farthest && (e == 0 || reversible(i)) {
+				first, reversed, farthest = i, e == 1, closest
+			}
+		}
+	}
+	used := make([]bool, len(pieces))
+	ordered := make([][]*gpx.Point, 0, len(pieces))
+	add := func(i int, reversed bool) {
+		piece := pieces[i]
+		if reversed {
+			piece = slices.Clone(piece)
+			slices.Reverse(piece)
+			if timed(piece) {
+				for j, p := range piece {
+					p = proto.Clone(p).(*gpx.Point)
+					p.NanoTime = nil
+					piece[j] = p
+				}
+				c.Untimed++
+			}
+		}
+		used[i] = true
+		ordered = append(ordered, piece)
+	}
+	add(first, reversed)
+	for len(ordered) < len(pieces) {
+		last := ordered[len(ordered)-1]
+		end := last[len(last)-1]
+		next, reversed, closest := -1, false, math.Inf(1)
+		for i, piece := range pieces {
+			if used[i] {
+				continue
+			}
+			for e, p := range ends(piece) {
+				if e == 1 && !reversible(i) {
+					continue
+				}
+				if d := HaversinDistance(end, p); d < closest {
+					next, reversed, closest = i, e == 1, d
+				}
+			}
+		}
+		add(next, reversed)
+	}
+	return ordered
+}
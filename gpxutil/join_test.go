@@ -0,0 +1,153 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"gpxtoolkit/gpx"
+)
+
+// disorderedTrackLog has pieces of a trail to the north in a random order and
+// direction, with gaps of about 11m and a gap of about 200m before the last.
+func disorderedTrackLog(t *testing.T) *gpx.TrackLog {
+	piece := func(from, to float64) string {
+		points := ""
+		step := 0.001
+		if to < from {
+			step = -step
+		}
+		for lat := from; (step > 0 && lat <= to+1e-9) || (step < 0 && lat >= to-1e-9); lat += step {
+			points += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"></trkpt>`, lat)
+		}
+		return fmt.Sprintf(`<trkseg>%s</trkseg>`, points)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><name>Trail</name>%s%s</trk>
+<trk>%s%s</trk>
+</gpx>`, piece(24.0051, 24.0081), piece(24.0120, 24.0130), piece(24.0050, 24.0000), piece(24.0082, 24.0102))
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tracklog
+}
+
+func TestJoinTracks(t *testing.T) {
+	tracklog := disorderedTrackLog(t)
+	join := &JoinTracks{Threshold: 50}
+	n, err := join.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatalf("Unexpected number of joined segments: %d", n)
+	}
+	if len(tracklog.Tracks) != 1 || tracklog.Tracks[0].GetName() != "Trail" {
+		t.Fatalf("Unexpected tracks: %v", tracklog.Tracks)
+	}
+	segments := tracklog.Tracks[0].Segments
+	if len(segments) != 2 || len(join.Gaps) != 1 {
+		t.Fatalf("Unexpected number of segments: %d", len(segments))
+	}
+	if d := join.Gaps[0].Distance; d < 190 || d > 210 {
+		t.Fatalf("Unexpected gap: %f", d)
+	}
+	points := segments[0].Points
+	if len(points) != 6+4+3 {
+		t.Fatalf("Unexpected number of points: %d", len(points))
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].GetLatitude() <= points[i-1].GetLatitude() {
+			t.Fatalf("Unexpected order at %d: %f", i, points[i].GetLatitude())
+		}
+	}
+
+	tracklog = disorderedTrackLog(t)
+	join = &JoinTracks{Threshold: 50, Snap: true}
+	_, err = join.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(tracklog.Tracks[0].Segments[0].Points); n != 6+4+3-2 {
+		t.Fatalf("Unexpected number of snapped points: %d", n)
+	}
+
+	tracklog = disorderedTrackLog(t)
+	join = &JoinTracks{Threshold: 50, Interval: 5}
+	_, err = join.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(tracklog.Tracks[0].Segments[0].Points); n != 6+4+3+2*2 {
+		t.Fatalf("Unexpected number of interpolated points: %d", n)
+	}
+}
+
+func TestJoinTracksOfTimedPieces(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>
+<trkpt lat="24.000" lon="121.0"><time>2022-01-01T00:00:00Z</time></trkpt>
+<trkpt lat="24.001" lon="121.0"><time>2022-01-01T00:01:00Z</time></trkpt>
+<trkpt lat="24.002" lon="121.0"><time>2022-01-01T00:02:00Z</time></trkpt>
+</trkseg><trkseg>
+<trkpt lat="24.004" lon="121.0"><time>2022-01-01T00:10:00Z</time></trkpt>
+<trkpt lat="24.003" lon="121.0"><time>2022-01-01T00:11:00Z</time></trkpt>
+<trkpt lat="24.002" lon="121.0"><time>2022-01-01T00:12:00Z</time></trkpt>
+</trkseg></trk>
+</gpx>`
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := &JoinTracks{Threshold: 50, Interval: 5}
+	if _, err := join.Run(tracklog); err != nil {
+		t.Fatal(err)
+	}
+	segments := tracklog.Tracks[0].Segments
+	if len(segments) != 2 || len(join.Gaps) != 1 {
+		t.Fatalf("Unexpected number of segments: %d", len(segments))
+	}
+	points := append(segments[0].Points, segments[1].Points...)
+	if len(points) != 6 {
+		t.Fatalf("Unexpected number of points: %d", len(points))
+	}
+	if join.Untimed != 0 {
+		t.Fatalf("Unexpected number of untimed segments: %d", join.Untimed)
+	}
+	for i, p := range points {
+		if p.NanoTime == nil || (i > 0 && p.GetNanoTime() <= points[i-1].GetNanoTime()) {
+			t.Fatalf("Unexpected time of point %d: %v", i, p)
+		}
+	}
+
+	tracklog, err = gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := tracklog.Tracks[0].Segments[1].Points
+	join = &JoinTracks{Threshold: 50, Interval: 5, ClearTimes: true}
+	if _, err := join.Run(tracklog); err != nil {
+		t.Fatal(err)
+	}
+	points = tracklog.Tracks[0].Segments[0].Points
+	if len(points) != 5 {
+		t.Fatalf("Unexpected number of points: %d", len(points))
+	}
+	if join.Untimed != 1 {
+		t.Fatalf("Unexpected number of untimed segments: %d", join.Untimed)
+	}
+	for i, p := range points {
+		if i > 0 && p.GetLatitude() <= points[i-1].GetLatitude() {
+			t.Fatalf("Unexpected order at %d: %f", i, p.GetLatitude())
+		}
+		if (i < 3) != (p.NanoTime != nil) {
+			t.Fatalf("Unexpected time of point %d: %v", i, p)
+		}
+	}
+	if original[0].NanoTime == nil {
+		t.Fatalf("Unexpected cleared time of the original point")
+	}
+}
@@ -6,7 +6,27 @@ import (
 	"time"
 )
 
-type DistanceFunc func(a, b *gpx.Point) float64
+// DistanceMode is how distances between points are measured, and how lines
+// between them are interpolated and projected.
+type DistanceMode int
+
+const (
+	HaversinMode DistanceMode = iota // HaversinDistance, lines by lat/lon
+	TerrainMode                      // TerrainDistance, lines by lat/lon
+	GeodesicMode                     // GeodesicDistance, lines along the geodesic
+)
+
+// Distance returns the distance between the points in meters.
+func (m DistanceMode) Distance(a, b *gpx.Point) float64 {
+	switch m {
+	case TerrainMode:
+		return TerrainDistance(a, b)
+	case GeodesicMode:
+		return GeodesicDistance(a, b)
+	default:
+		return HaversinDistance(a, b)
+	}
+}
 
 func HaversinDistance(a, b *gpx.Point) float64 {
 	return gpx.GeoDistance(a.GetLatitude(), a.GetLongitude(), b.GetLatitude(), b.GetLongitude())
@@ -25,9 +45,21 @@ type line struct {
 	dist     float64
 	duration *time.Duration
 	speed    *float64
+	geodesic bool // measured in GeodesicMode
+}
+
+// interpolate returns the point at the ratio of the line.
+func (l *line) interpolate(ratio float64) *gpx.Point {
+	if l.geodesic {
+		return geodesicInterpolate(l.a, l.b, ratio)
+	}
+	return interpolate(l.a, l.b, ratio)
 }
 
 func (l *line) closestPoint(c *gpx.Point) *gpx.Point {
+	if l.geodesic {
+		return geodesicClosestPoint(l.a, l.b, c)
+	}
 	xa, ya := toWebMercator(l.a.GetLatitude(), l.a.GetLongitude())
 	xb, yb := toWebMercator(l.b.GetLatitude(), l.b.GetLongitude())
 	xc, yc := toWebMercator(c.GetLatitude(), c.GetLongitude())
@@ -53,18 +85,20 @@ func (l *line) closestPoint(c *gpx.Point) *gpx.Point {
 	return interpolate(l.a, l.b, ratio)
 }
 
-func getLines(distanceFunc DistanceFunc, points []*gpx.Point) []*line {
+func getLines(mode DistanceMode, points []*gpx.Point) []*line {
 	if len(points) <= 1 {
 		return []*line{}
 	}
 	lines := make([]*line, len(points)-1)
+	geodesic := mode == GeodesicMode
 	for i, b := range points[1:] {
 		a := points[i]
 		line := &line{
-			a: a,
-			b: b,
+			a:        a,
+			b:        b,
+			geodesic: geodesic,
 		}
-		line.dist = distanceFunc(line.a, line.b)
+		line.dist = mode.Distance(line.a, line.b)
 		// log.Printf("Line[%d]: dist=%f", i, line.dist)
 		if line.a.NanoTime != nil && line.b.NanoTime != nil {
 			line.duration = new(time.Duration)
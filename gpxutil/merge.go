@@ -0,0 +1,316 @@
+package gpxutil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// MergeRecordings merges recordings of the same trip by several devices into a
+// single track without duplicates, and marks each point with the source it
+// comes from.
+//
+// If MaxOffset is positive, the clock of each recording is aligned to the
+// first one by the offset within MaxOffset that minimizes the mean distance
+// between their positions at the same time. Offsets are searched every
+// second, or every tenth of MaxOffset if it is shorter than 10 seconds.
+//
+// For each time Window, the source of the least estimated error in meters is
+// picked: 5 meters per HDOP (2 if unknown) plus the jitter, i.e. the mean
+// distance of points to the middle of their neighbors, divided by the ratio of
+// the window not in a gap longer than a tenth of it. The current source is kept
+// unless another is better by a fifth. Windows without any point start new
+// segments. Points without time cannot be merged, so they are dropped and
+// counted in Untimed, and a recording without any time is an error.
+//
+// Waypoints within WaypointDistance meters of each other are merged if their
+// names are similar, i.e. one contains the other ignoring case, or they differ
+// in at most a third of their characters.
+type MergeRecordings struct {
+	Sources          []string // names of the recordings; "Source N" if missing
+	Window           time.Duration
+	MaxOffset        time.Duration
+	WaypointDistance float64
+	Offsets          []time.Duration // clock offsets added to the recordings
+	Untimed          int             // number of points dropped without time
+}
+
+func (c *MergeRecordings) Name() string {
+	return fmt.Sprintf("Merge Recordings every %v", c.Window)
+}
+
+// Run returns the merged track log.
+func (c *MergeRecordings) Run(tracklogs []*gpx.TrackLog) (*gpx.TrackLog, error) {
+	if c.Window <= 0 {
+		return nil, fmt.Errorf("invalid window: %v", c.Window)
+	}
+	sources := make([][]*gpx.Point, len(tracklogs))
+	var name *string
+	c.Untimed = 0
+	for i, tracklog := range tracklogs {
+		untimed := 0
+		for _, t := range tracklog.Tracks {
+			if name == nil && t.Name != nil {
+				name = t.Name
+			}
+			for _, seg := range t.Segments {
+				for _, p := range seg.Points {
+					if p.NanoTime != nil {
+						sources[i] = append(sources[i], p)
+					} else {
+						untimed++
+					}
+				}
+			}
+		}
+		if untimed > 0 && len(sources[i]) <= 0 {
+			return nil, fmt.Errorf("no time in %d points of %s", untimed, c.source(i))
+		}
+		c.Untimed += untimed
+		sort.SliceStable(sources[i], func(a, b int) bool {
+			return sources[i][a].GetNanoTime() < sources[i][b].GetNanoTime()
+		})
+	}
+	c.Offsets = make([]time.Duration, len(sources))
+	if c.MaxOffset > 0 {
+		for i := 1; i < len(sources); i++ {
+			c.Offsets[i] = clockOffset(sources[0], sources[i], c.MaxOffset)
+			if c.Offsets[i] != 0 {
+				sources[i] = shiftPoints(sources[i], c.Offsets[i])
+			}
+		}
+	}
+	merged := &gpx.TrackLog{
+		WayPoints: c.mergeWaypoints(tracklogs),
+		Tracks:    []*gpx.Track{{Name: name}},
+	}
+	var start, end int64 = math.MaxInt64, math.MinInt64
+	for _, points := range sources {
+		if len(points) > 0 {
+			start = min(start, points[0].GetNanoTime())
+			end = max(end, points[len(points)-1].GetNanoTime())
+		}
+	}
+	if start > end {
+		return merged, nil
+	}
+	var current *gpx.Segment
+	picked := -1
+	next := make([]int, len(sources)) // index of the first point not before the window
+	for from := start; from <= end; from += int64(c.Window) {
+		to := from + int64(c.Window)
+		best, bestErr := -1, math.Inf(1)
+		windows := make([][]*gpx.Point, len(sources))
+		for i, points := range sources {
+			j := next[i]
+			for next[i] < len(points) && points[next[i]].GetNanoTime() < to {
+				next[i]++
+			}
+			windows[i] = points[j:next[i]]
+			if len(windows[i]) <= 0 {
+				continue
+			}
+			err := c.estimateError(windows[i], from, to)
+			if err < bestErr {
+				best, bestErr = i, err
+			}
+		}
+		if best < 0 {
+			current = nil
+			continue
+		}
+		if picked >= 0 && picked != best && len(windows[picked]) > 0 && bestErr > 0.8*c.estimateError(windows[picked], from, to) {
+			best = picked
+		}
+		picked = best
+		if current == nil {
+			current = &gpx.Segment{}
+			merged.Tracks[0].Segments = append(merged.Tracks[0].Segments, current)
+		}
+		source := c.source(best)
+		for _, p := range windows[best] {
+			p = proto.Clone(p).(*gpx.Point)
+			p.Source = proto.String(source)
+			current.Points = append(current.Points, p)
+		}
+	}
+	return merged, nil
+}
+
+func (c *MergeRecordings) source(i int) string {
+	if i < len(c.Sources) && c.Sources[i] != "" {
+		return c.Sources[i]
+	}
+	return fmt.Sprintf("Source %d", i+1)
+}
+
+// estimateError returns the estimated error in meters of the points in the
+// window.
+func (c *MergeRecordings) estimateError(points []*gpx.Point, from, to int64) float64 {
+	hdop := 0.0
+	for _, p := range points {
+		if p.Hdop != nil {
+			hdop += p.GetHdop()
+		} else {
+			hdop += 2
+		}
+	}
+	hdop /= float64(len(points))
+	jitter := 0.0
+	if len(points) > 2 {
+		for i := 1; i < len(points)-1; i++ {
+			jitter += HaversinDistance(points[i], interpolate(points[i-1], points[i+1], 0.5))
+		}
+		jitter /= float64(len(points) - 2)
+	}
+	gaps := int64(0)
+	prev := from
+	for _, t := range append(pointTimes(points), to) {
+		if gap := t - prev; gap > (to-from)/10 {
+			gaps += gap
+		}
+		prev = t
+	}
+	coverage := math.Max(0.1, 1-float64(gaps)/float64(to-from))
+	return (5*hdop + jitter) / coverage
+}
+
+func pointTimes(points []*gpx.Point) []int64 {
+	times := make([]int64, len(points))
+	for i, p := range points {
+		times[i] = p.GetNanoTime()
+	}
+	return times
+}
+
+// clockOffset returns the offset within max to be added to the time of the
+// points, that minimizes the mean distance to the reference at the same time,
+// searched every second or every tenth of max if shorter.
+func clockOffset(ref, points []*gpx.Point, maxOffset time.Duration) time.Duration {
+	// at most 200 points are compared
+	step := max(1, len(points)/200)
+	interval := min(time.Second, maxOffset/10)
+	if interval <= 0 {
+		return 0
+	}
+	best, bestDist := time.Duration(0), math.Inf(1)
+	for offset := -maxOffset.Truncate(interval); offset <= maxOffset; offset += interval {
+		sum, num := 0.0, 0
+		for i := 0; i < len(points); i += step {
+			t := points[i].Time().Add(offset)
+			j := sort.Search(len(ref), func(j int) bool {
+				return !ref[j].Time().Before(t)
+			})
+			if j <= 0 || j >= len(ref) {
+				continue
+			}
+			sum += HaversinDistance(points[i], interpolateAt(ref[j-1], ref[j], t))
+			num++
+		}
+		if num <= 0 {
+			continue
+		}
+		if dist := sum / float64(num); dist < bestDist || (dist == bestDist && offset.Abs() < best.Abs()) {
+			best, bestDist = offset, dist
+		}
+	}
+	return best
+}
+
+func shiftPoints(points []*gpx.Point, offset time.Duration) []*gpx.Point {
+	shifted := make([]*gpx.Point, len(points))
+	for i, p := range points {
+		p = proto.Clone(p).(*gpx.Point)
+		p.NanoTime = proto.Int64(p.GetNanoTime() + int64(offset))
+		shifted[i] = p
+	}
+	return shifted
+}
+
+// mergeWaypoints returns the waypoints of the track logs, with those of
+// similar names within WaypointDistance merged into the first of them, which
+// takes the missing fields from the others.
+func (c *MergeRecordings) mergeWaypoints(tracklogs []*gpx.TrackLog) []*gpx.WayPoint {
+	merged := make([]*gpx.WayPoint, 0)
+	for _, tracklog := range tracklogs {
+		for _, w := range tracklog.WayPoints {
+			var same *gpx.WayPoint
+			for _, m := range merged {
+				if gpx.GeoDistance(m.GetLatitude(), m.GetLongitude(), w.GetLatitude(), w.GetLongitude()) <= c.WaypointDistance && similarNames(m.GetName(), w.GetName()) {
+					same = m
+					break
+				}
+			}
+			if same == nil {
+				merged = append(merged, proto.Clone(w).(*gpx.WayPoint))
+				continue
+			}
+			if same.Name == nil {
+				same.Name = w.Name
+			}
+			if same.NanoTime == nil {
+				same.NanoTime = w.NanoTime
+			}
+			if same.Elevation == nil {
+				same.Elevation = w.Elevation
+			}
+			if same.Description == nil {
+				same.Description = w.Description
+			}
+			if same.Comment == nil {
+				same.Comment = w.Comment
+			}
+			if same.Symbol == nil {
+				same.Symbol = w.Symbol
+			}
+		}
+	}
+	return merged
+}
+
+// minContainedName is the minimum number of characters of a name to be
+// similar to a longer name containing it.
+const minContainedName = 3
+
+// similarNames returns whether one of the names contains the other ignoring
+// case, or they differ in at most a third of their characters. Empty names
+// are never similar, and a name shorter than minContainedName is only
+// similar to the same name.
+func similarNames(a, b string) bool {
+	a, b = strings.ToLower(strings.TrimSpace(a)), strings.ToLower(strings.TrimSpace(b))
+	if a == "" || b == "" {
+		return false
+	}
+	ra, rb := []rune(a), []rune(b)
+	if min(len(ra), len(rb)) >= minContainedName && (strings.Contains(a, b) || strings.Contains(b, a)) {
+		return true
+	}
+	return editDistance(ra, rb)*3 <= max(len(ra), len(rb))
+}
+
+// editDistance returns the Levenshtein distance between the runes.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
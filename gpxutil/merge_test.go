@@ -0,0 +1,144 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMergeRecordings(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	// walking north every 10s for 20 minutes; the phone with HDOP 1 lost the
+	// fix for minutes 10-15, and the watch with HDOP 3 is 30s late
+	phone, watch := "", ""
+	for i := 0; i < 120; i++ {
+		lat := 24.0 + 0.0001*float64(i)
+		if i < 60 || i >= 90 {
+			phone += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"><time>%s</time><hdop>1</hdop></trkpt>`, lat, start.Add(time.Duration(i)*10*time.Second).Format(time.RFC3339))
+		}
+		watch += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"><time>%s</time><hdop>3</hdop></trkpt>`, lat, start.Add(time.Duration(i)*10*time.Second+30*time.Second).Format(time.RFC3339))
+	}
+	tracklogs := make([]*gpx.TrackLog, 0)
+	for _, xml := range []string{
+		fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<wpt lat="24.006" lon="121.0"><name>Summit</name></wpt>
+<trk><name>Hike</name><trkseg>%s</trkseg></trk>
+</gpx>`, phone),
+		fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<wpt lat="24.0061" lon="121.0"><ele>1000</ele><name>summit </name></wpt>
+<wpt lat="24.0061" lon="121.0"><name>Water</name></wpt>
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, watch),
+	} {
+		tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tracklogs = append(tracklogs, tracklog)
+	}
+	merge := &MergeRecordings{
+		Sources:          []string{"phone", "watch"},
+		Window:           time.Minute,
+		MaxOffset:        time.Minute,
+		WaypointDistance: 50,
+	}
+	tracklog, err := merge.Run(tracklogs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merge.Offsets[0] != 0 || merge.Offsets[1] != -30*time.Second {
+		t.Fatalf("Unexpected clock offsets: %v", merge.Offsets)
+	}
+	if len(tracklog.Tracks) != 1 || tracklog.Tracks[0].GetName() != "Hike" || len(tracklog.Tracks[0].Segments) != 1 {
+		t.Fatalf("Unexpected tracks: %v", tracklog.Tracks)
+	}
+	points := tracklog.Tracks[0].Segments[0].Points
+	if len(points) != 120 {
+		t.Fatalf("Unexpected number of points: %d", len(points))
+	}
+	for i, p := range points {
+		want := "phone"
+		if i >= 60 && i < 90 {
+			want = "watch"
+		}
+		if p.GetSource() != want {
+			t.Fatalf("Unexpected source of point %d: %s", i, p.GetSource())
+		}
+		if !p.Time().Equal(start.Add(time.Duration(i) * 10 * time.Second)) {
+			t.Fatalf("Unexpected time of point %d: %v", i, p.Time())
+		}
+	}
+	if len(tracklog.WayPoints) != 2 {
+		t.Fatalf("Unexpected number of waypoints: %d", len(tracklog.WayPoints))
+	}
+	if w := tracklog.WayPoints[0]; w.GetName() != "Summit" || w.GetElevation() != 1000 {
+		t.Fatalf("Unexpected merged waypoint: %v", w)
+	}
+}
+
+func TestMergeUntimedRecordings(t *testing.T) {
+	parse := func(trkpts string) *gpx.TrackLog {
+		tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, trkpts))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tracklog
+	}
+	timed := parse(`<trkpt lat="24.0" lon="121.0"><time>2022-01-01T00:00:00Z</time></trkpt><trkpt lat="24.1" lon="121.0"></trkpt><trkpt lat="24.2" lon="121.0"><time>2022-01-01T00:00:10Z</time></trkpt>`)
+	untimed := parse(`<trkpt lat="24.0" lon="121.0"></trkpt><trkpt lat="24.1" lon="121.0"></trkpt>`)
+	merge := &MergeRecordings{Window: time.Minute}
+	tracklog, err := merge.Run([]*gpx.TrackLog{timed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merge.Untimed != 1 || len(tracklog.Tracks[0].Segments[0].Points) != 2 {
+		t.Fatalf("Unexpected merge of %d untimed points: %v", merge.Untimed, tracklog.Tracks[0].Segments)
+	}
+	if _, err := merge.Run([]*gpx.TrackLog{timed, untimed}); err == nil {
+		t.Fatalf("Unexpected success of merging a recording without time")
+	}
+}
+
+func TestSimilarNames(t *testing.T) {
+	for _, c := range []struct {
+		a, b    string
+		similar bool
+	}{
+		{"Summit", "summit ", true},
+		{"Camp", "Base Camp", true},
+		{"Summit", "Sumit", true},
+		{"", "", false},
+		{"", "Camp A", false},
+		{"A", "Camp A", false},
+		{"A", "a", true},
+		{"Water", "Summit", false},
+	} {
+		if similarNames(c.a, c.b) != c.similar {
+			t.Fatalf("Unexpected similarity of %q and %q: %v", c.a, c.b, !c.similar)
+		}
+	}
+}
+
+func TestClockOffsetUnderSecond(t *testing.T) {
+	// walking north 1 m/s, where the points are 300ms late
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	ref, points := make([]*gpx.Point, 0), make([]*gpx.Point, 0)
+	for i := 0; i < 60; i++ {
+		lat := 24.0 + float64(i)/111195
+		ref = append(ref, &gpx.Point{Latitude: proto.Float64(lat), Longitude: proto.Float64(121.0), NanoTime: proto.Int64(start.Add(time.Duration(i) * time.Second).UnixNano())})
+		points = append(points, &gpx.Point{Latitude: proto.Float64(lat), Longitude: proto.Float64(121.0), NanoTime: proto.Int64(start.Add(time.Duration(i)*time.Second + 300*time.Millisecond).UnixNano())})
+	}
+	if offset := clockOffset(ref, points, 500*time.Millisecond); offset != -300*time.Millisecond {
+		t.Fatalf("Unexpected clock offset: %v", offset)
+	}
+}
@@ -13,14 +13,13 @@ import (
 )
 
 type Milestone struct {
-	Service           elevation.Service
-	Distance          float64
-	MilestoneName     *MilestoneName
-	Symbol            string
-	Reverse           bool
-	FitWaypoints      bool
-	ByTerrainDistance bool
-	distanceFunc      DistanceFunc
+	Service       elevation.Service
+	Distance      float64
+	MilestoneName *MilestoneName
+	Symbol        string
+	Reverse       bool
+	FitWaypoints  bool
+	DistanceMode  DistanceMode
 }
 
 func (c *Milestone) Name() string {
@@ -28,11 +27,6 @@ func (c *Milestone) Name() string {
 }
 
 func (c *Milestone) Run(tracklog *gpx.TrackLog) (int, error) {
-	if c.ByTerrainDistance {
-		c.distanceFunc = TerrainDistance
-	} else {
-		c.distanceFunc = HaversinDistance
-	}
 	n := 0
 	for _, t := range tracklog.Tracks {
 		for _, seg := range t.Segments {
@@ -46,7 +40,7 @@ func (c *Milestone) Run(tracklog *gpx.TrackLog) (int, error) {
 					points[len(seg.Points)-1-i] = p
 				}
 			}
-			if c.ByTerrainDistance && c.Service != nil {
+			if c.DistanceMode == TerrainMode && c.Service != nil {
 				// we don't alter the original points
 				corrected := make([]*gpx.Point, len(points))
 				copy(corrected, points)
@@ -88,7 +82,7 @@ func (c *Milestone) milestone(points []*gpx.Point, waypoints []*gpx.WayPoint) ([
 		total := 0.0
 		for i, b := range points[1:] {
 			a := points[i]
-			dist := c.distanceFunc(a, b)
+			dist := c.DistanceMode.Distance(a, b)
 			distances[i] = dist
 			total += dist
 		}
@@ -106,7 +100,7 @@ func (c *Milestone) milestone(points []*gpx.Point, waypoints []*gpx.WayPoint) ([
 		log.Debugf("Total %d points: %.1fm with %d milestones", len(points), total, len(milestones))
 		return c.create(points, milestones, distances)
 	} else {
-		segments, err := sliceByWaypoints(c.distanceFunc, points, waypoints, c.Distance/2)
+		segments, err := sliceByWaypoints(c.DistanceMode, points, waypoints, c.Distance/2, false)
 		if err != nil {
 			return nil, err
 		}
@@ -124,7 +118,7 @@ func (c *Milestone) milestone(points []*gpx.Point, waypoints []*gpx.WayPoint) ([
 			distances[i] = make([]float64, len(segment.points)-1)
 			for j, b := range segment.points[1:] {
 				a := segment.points[j]
-				dist := c.distanceFunc(a, b)
+				dist := c.DistanceMode.Distance(a, b)
 				distances[i][j] = dist
 				distance += dist
 			}
@@ -183,7 +177,7 @@ func (c *Milestone) create(points []*gpx.Point, milestones []*milestone, distanc
 		if distances != nil {
 			dist = distances[i]
 		} else {
-			dist = c.distanceFunc(a, b)
+			dist = c.DistanceMode.Distance(a, b)
 		}
 		end := start + dist
 		// log.Printf("Current distance: %f", end)
@@ -214,7 +208,7 @@ func (c *Milestone) create(points []*gpx.Point, milestones []*milestone, distanc
 				}
 				markers = append(markers, ms.waypoint)
 			} else {
-				p := interpolate(a, b, (ms.distance-start)/dist)
+				p := interpolateBy(c.DistanceMode, a, b, (ms.distance-start)/dist)
 				if c.Service != nil {
 					_, err := correctPoints(c.Service, []*gpx.Point{p})
 					if err != nil {
@@ -299,6 +293,39 @@ var functions map[string]func(args ...interface{}) (interface{}, error) = map[st
 	"ceil": func(args ...interface{}) (interface{}, error) {
 		return mathFunc(math.Ceil, args...)
 	},
+	"abs": func(args ...interface{}) (interface{}, error) {
+		return mathFunc(math.Abs, args...)
+	},
+	"min": func(args ...interface{}) (interface{}, error) {
+		return reduceFunc(math.Min, args...)
+	},
+	"max": func(args ...interface{}) (interface{}, error) {
+		return reduceFunc(math.Max, args...)
+	},
+}
+
+func reduceFunc(call func(float64, float64) float64, args ...interface{}) (interface{}, error) {
+	if len(args) <= 0 {
+		return nil, fmt.Errorf("unexpected number of arguments")
+	}
+	var res float64
+	for i, arg := range args {
+		var v float64
+		switch a := arg.(type) {
+		case int:
+			v = float64(a)
+		case float64:
+			v = a
+		default:
+			return nil, fmt.Errorf("unexpected type: %v", arg)
+		}
+		if i == 0 {
+			res = v
+		} else {
+			res = call(res, v)
+		}
+	}
+	return res, nil
 }
 
 func mathFunc(call func(float64) float64, args ...interface{}) (interface{}, error) {
@@ -15,7 +15,7 @@ func (c *RemoveOutlier) Name() string {
 func RemoveOutlierBySpeed(sigma int) *RemoveOutlier {
 	return &RemoveOutlier{
 		sigma:        sigma,
-		distanceFunc: HaversinDistance,
+		distanceMode: HaversinMode,
 		metric:       "Speed",
 		unit:         "m/s",
 		value: func(line *line) *float64 {
@@ -27,7 +27,7 @@ func RemoveOutlierBySpeed(sigma int) *RemoveOutlier {
 func RemoveOutlierByDistance(sigma int) *RemoveOutlier {
 	return &RemoveOutlier{
 		sigma:        sigma,
-		distanceFunc: HaversinDistance,
+		distanceMode: HaversinMode,
 		metric:       "Distance",
 		unit:         "m",
 		value: func(line *line) *float64 {
@@ -38,18 +38,19 @@ func RemoveOutlierByDistance(sigma int) *RemoveOutlier {
 
 type RemoveOutlier struct {
 	sigma        int
-	distanceFunc DistanceFunc
+	distanceMode DistanceMode
 	metric       string
 	unit         string
 	value        func(line *line) *float64
+	Report       func(outlier *Outlier) // called for each removed point if not nil
 }
 
 func (r *RemoveOutlier) Run(tracklog *gpx.TrackLog) (int, error) {
 	n := 0
-	for _, t := range tracklog.Tracks {
+	for j, t := range tracklog.Tracks {
 		for i, seg := range t.Segments {
 			num := len(seg.Points)
-			removed, err := r.remove(seg)
+			removed, err := r.remove(j, i, seg)
 			if err != nil {
 				return 0, err
 			}
@@ -62,8 +63,8 @@ func (r *RemoveOutlier) Run(tracklog *gpx.TrackLog) (int, error) {
 	return n, nil
 }
 
-func (r *RemoveOutlier) remove(seg *gpx.Segment) (*gpx.Segment, error) {
-	lines := getLines(r.distanceFunc, seg.Points)
+func (r *RemoveOutlier) remove(track, segment int, seg *gpx.Segment) (*gpx.Segment, error) {
+	lines := getLines(r.distanceMode, seg.Points)
 	sum := 0.0
 	num := 0
 	for _, line := range lines {
@@ -90,19 +91,38 @@ func (r *RemoveOutlier) remove(seg *gpx.Segment) (*gpx.Segment, error) {
 	log.Debugf("%d-Sigma: %f %s", r.sigma, sigma, r.unit)
 
 	accepted := make([]*line, 0)
+	reasons := make(map[*gpx.Point]string)
 	for _, line := range lines {
 		value := r.value(line)
 		if value != nil {
 			// log.Debugf("Speed %v", *value)
-			if math.Abs(*value)-avg > sigma {
+			if math.Abs(*value-avg) > sigma {
 				log.Debugf("Discarding %v %s", *value, r.unit)
+				reason := fmt.Sprintf("%s: %.1f %s off the average %.1f %s by over %d sigma", r.metric, *value, r.unit, avg, r.unit, r.sigma)
+				for _, p := range []*gpx.Point{line.a, line.b} {
+					if _, ok := reasons[p]; !ok {
+						reasons[p] = reason
+					}
+				}
 				continue
 			}
 		}
 		accepted = append(accepted, line)
 	}
 
-	return &gpx.Segment{
+	res := &gpx.Segment{
 		Points: joinLines(accepted),
-	}, nil
+	}
+	if r.Report != nil {
+		kept := make(map[*gpx.Point]bool)
+		for _, p := range res.Points {
+			kept[p] = true
+		}
+		for k, p := range seg.Points {
+			if !kept[p] {
+				r.Report(&Outlier{Track: track, Segment: segment, Index: k, Point: p, Reason: reasons[p]})
+			}
+		}
+	}
+	return res, nil
 }
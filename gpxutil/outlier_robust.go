@@ -0,0 +1,292 @@
+package gpxutil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/log"
+)
+
+// Outlier is a point detected as an outlier with the reason.
+type Outlier struct {
+	Track, Segment int
+	Index          int // index of the point in the segment before removal
+	Point          *gpx.Point
+	Reason         string
+}
+
+// OutlierDetector detects outliers in the points of a track segment. Detectors
+// are robust to the outliers themselves, unlike RemoveOutlier whose standard
+// deviation is inflated by a single huge jump.
+type OutlierDetector interface {
+	Name() string
+	Detect(track *gpx.Track, points []*gpx.Point) []*Outlier
+}
+
+// RemoveOutliers removes the points detected by any of the detectors, and
+// calls Report for each of them if not nil.
+type RemoveOutliers struct {
+	Detectors []OutlierDetector
+	Report    func(outlier *Outlier)
+}
+
+func (r *RemoveOutliers) Name() string {
+	names := make([]string, len(r.Detectors))
+	for i, d := range r.Detectors {
+		names[i] = d.Name()
+	}
+	return fmt.Sprintf("Remove Outliers by %s", strings.Join(names, ", "))
+}
+
+func (r *RemoveOutliers) Run(tracklog *gpx.TrackLog) (int, error) {
+	n := 0
+	for i, t := range tracklog.Tracks {
+		for j, seg := range t.Segments {
+			outliers := make(map[int]*Outlier)
+			for _, d := range r.Detectors {
+				for _, o := range d.Detect(t, seg.Points) {
+					if prev := outliers[o.Index]; prev != nil {
+						prev.Reason += "; " + o.Reason
+						continue
+					}
+					o.Track, o.Segment = i, j
+					outliers[o.Index] = o
+				}
+			}
+			if len(outliers) <= 0 {
+				continue
+			}
+			points := make([]*gpx.Point, 0, len(seg.Points)-len(outliers))
+			for k, p := range seg.Points {
+				o := outliers[k]
+				if o == nil {
+					points = append(points, p)
+					continue
+				}
+				log.Debugf("Removing outlier point[%d] of track[%d] segment[%d]: %s", k, i, j, o.Reason)
+				if r.Report != nil {
+					r.Report(o)
+				}
+			}
+			n += len(outliers)
+			seg.Points = points
+		}
+	}
+	return n, nil
+}
+
+// HampelDetector is a rolling Hampel filter on the positions: a point deviating
+// from the median of its neighbors by more than Threshold times the scaled
+// median absolute deviation (MAD), in either east-west or north-south
+// direction, is an outlier. The deviation must also exceed MinDeviation
+// meters, as the MAD of a stationary window can be nearly zero.
+type HampelDetector struct {
+	Window       int // number of neighbors on each side
+	Threshold    float64
+	MinDeviation float64
+}
+
+func (d *HampelDetector) Name() string {
+	return fmt.Sprintf("Hampel filter of %d points", d.Window*2+1)
+}
+
+func (d *HampelDetector) Detect(track *gpx.Track, points []*gpx.Point) []*Outlier {
+	outliers := make([]*Outlier, 0)
+	if len(points) <= 2 || d.Window <= 0 {
+		return outliers
+	}
+	prj := newLocalProjection(points[0])
+	xs := make([]float64, len(points))
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		xs[i], ys[i] = prj.project(p)
+	}
+	for i, p := range points {
+		from := max(0, i-d.Window)
+		to := min(len(points), i+d.Window+1)
+		dx, lx := d.deviation(xs[from:to], xs[i])
+		dy, ly := d.deviation(ys[from:to], ys[i])
+		if dx > lx || dy > ly {
+			outliers = append(outliers, &Outlier{
+				Index:  i,
+				Point:  p,
+				Reason: fmt.Sprintf("Hampel: %.1f meter from the median over limit %.1f meter", math.Max(dx, dy), math.Max(lx, ly)),
+			})
+		}
+	}
+	return outliers
+}
+
+// deviation returns the deviation of the value from the median of the window,
+// and the limit of the deviation.
+func (d *HampelDetector) deviation(window []float64, value float64) (float64, float64) {
+	m := median(window)
+	deviations := make([]float64, len(window))
+	for i, v := range window {
+		deviations[i] = math.Abs(v - m)
+	}
+	limit := d.Threshold * 1.4826 * median(deviations)
+	return math.Abs(value - m), math.Max(limit, d.MinDeviation)
+}
+
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	m := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[m-1] + sorted[m]) / 2
+	}
+	return sorted[m]
+}
+
+// Default limits of SpikeDetector. Walking with a point every 10 seconds, a
+// point jumping away at 50 m/s is reached by an acceleration of about 5 m/s².
+const (
+	DefaultMaxAcceleration = 2.0
+	DefaultMaxTurnAngle    = 150.0
+)
+
+// SpikeDetector detects spike-and-return glitches: a point reached by an
+// acceleration over MaxAcceleration (m/s²) and left by a turn sharper than
+// MaxTurnAngle (degrees) back toward where it came from. Zero limits are
+// DefaultMaxAcceleration and DefaultMaxTurnAngle. Points without time are not
+// checked.
+type SpikeDetector struct {
+	MaxAcceleration float64
+	MaxTurnAngle    float64
+}
+
+func (d *SpikeDetector) Name() string {
+	acceleration, turnAngle := d.limits()
+	return fmt.Sprintf("Spike over %.1f m/s² and %.0f°", acceleration, turnAngle)
+}
+
+func (d *SpikeDetector) limits() (float64, float64) {
+	acceleration, turnAngle := d.MaxAcceleration, d.MaxTurnAngle
+	if acceleration <= 0 {
+		acceleration = DefaultMaxAcceleration
+	}
+	if turnAngle <= 0 {
+		turnAngle = DefaultMaxTurnAngle
+	}
+	return acceleration, turnAngle
+}
+
+func (d *SpikeDetector) Detect(track *gpx.Track, points []*gpx.Point) []*Outlier {
+	maxAcceleration, maxTurnAngle := d.limits()
+	outliers := make([]*Outlier, 0)
+	lines := getLines(HaversinMode, points)
+	for i := 1; i < len(lines); i++ {
+		in, out := lines[i-1], lines[i]
+		if in.speed == nil || in.duration == nil || *in.duration <= 0 {
+			continue
+		}
+		before := 0.0
+		if i >= 2 && lines[i-2].speed != nil {
+			before = *lines[i-2].speed
+		}
+		acceleration := (*in.speed - before) / in.duration.Seconds()
+		if acceleration <= maxAcceleration {
+			continue
+		}
+		turn := turnAngle(in.a, in.b, out.b)
+		if turn <= maxTurnAngle {
+			continue
+		}
+		outliers = append(outliers, &Outlier{
+			Index:  i,
+			Point:  in.b,
+			Reason: fmt.Sprintf("Spike: acceleration %.1f m/s² and turn %.0f°", acceleration, turn),
+		})
+	}
+	return outliers
+}
+
+// turnAngle returns the change of heading in degrees at b from a to c, which
+// is 0 for going straight and 180 for going back.
+func turnAngle(a, b, c *gpx.Point) float64 {
+	prj := newLocalProjection(b)
+	xa, ya := prj.project(a)
+	xc, yc := prj.project(c)
+	in := math.Atan2(-ya, -xa)
+	out := math.Atan2(yc, xc)
+	turn := math.Abs(out-in) * 180 / math.Pi
+	if turn > 180 {
+		turn = 360 - turn
+	}
+	return turn
+}
+
+// ActivitySpeeds are the max plausible speeds in m/s by activity, i.e. the
+// type of tracks.
+var ActivitySpeeds = map[string]float64{
+	"hiking":  4,
+	"walking": 4,
+	"running": 8,
+	"cycling": 25,
+	"skiing":  40,
+	"driving": 70,
+}
+
+// MaxSpeedDetector detects points reached from the previous accepted point
+// faster than the max speed. The max speed is MaxSpeed if positive, or the
+// speed in ActivitySpeeds of the track type, or of Activity if the track type
+// is unknown. Points without time are not checked.
+type MaxSpeedDetector struct {
+	MaxSpeed float64
+	Activity string
+}
+
+func (d *MaxSpeedDetector) Name() string {
+	if d.MaxSpeed > 0 {
+		return fmt.Sprintf("Max speed %.1f m/s", d.MaxSpeed)
+	}
+	return "Max speed by activity"
+}
+
+func (d *MaxSpeedDetector) Detect(track *gpx.Track, points []*gpx.Point) []*Outlier {
+	outliers := make([]*Outlier, 0)
+	limit, activity := d.limit(track)
+	if limit <= 0 || len(points) <= 1 {
+		return outliers
+	}
+	prev := points[0]
+	for i, p := range points[1:] {
+		if prev.NanoTime == nil || p.NanoTime == nil {
+			prev = p
+			continue
+		}
+		seconds := p.Time().Sub(prev.Time()).Seconds()
+		if seconds <= 0 {
+			prev = p
+			continue
+		}
+		speed := HaversinDistance(prev, p) / seconds
+		if speed > limit {
+			outliers = append(outliers, &Outlier{
+				Index:  i + 1,
+				Point:  p,
+				Reason: fmt.Sprintf("Speed: %.1f m/s over %.1f m/s%s", speed, limit, activity),
+			})
+			continue
+		}
+		prev = p
+	}
+	return outliers
+}
+
+func (d *MaxSpeedDetector) limit(track *gpx.Track) (float64, string) {
+	if d.MaxSpeed > 0 {
+		return d.MaxSpeed, ""
+	}
+	for _, activity := range []string{strings.ToLower(track.GetType()), d.Activity} {
+		if speed, ok := ActivitySpeeds[activity]; ok {
+			return speed, " for " + activity
+		}
+	}
+	return 0, ""
+}
@@ -0,0 +1,62 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestRemoveOutliers(t *testing.T) {
+	// walking 1 m/s eastward with a point every 10 seconds, where point[10]
+	// jumps 500 meters north and back, and point[20] is 60 meters off
+	trkpts := ""
+	for i := 0; i < 30; i++ {
+		lat := 24.0
+		switch i {
+		case 10:
+			lat += 500.0 / 111195
+		case 20:
+			lat += 60.0 / 111195
+		}
+		lon := 121 + float64(i)*10/101582
+		trkpts += fmt.Sprintf(`<trkpt lat="%f" lon="%f"><time>2022-01-01T00:%02d:%02dZ</time></trkpt>`, lat, lon, i*10/60, i*10%60)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><type>hiking</type><trkseg>%s</trkseg></trk>
+</gpx>`, trkpts)
+	tests := []struct {
+		detector OutlierDetector
+		indexes  []int
+	}{
+		{&HampelDetector{Window: 3, Threshold: 3, MinDeviation: 20}, []int{10, 20}},
+		{&SpikeDetector{}, []int{10}},
+		{&MaxSpeedDetector{}, []int{10, 20}},
+		{&MaxSpeedDetector{MaxSpeed: 10}, []int{10}},
+	}
+	for _, test := range tests {
+		tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes := make([]int, 0)
+		outlier := &RemoveOutliers{
+			Detectors: []OutlierDetector{test.detector},
+			Report: func(o *Outlier) {
+				indexes = append(indexes, o.Index)
+			},
+		}
+		n, err := outlier.Run(tracklog)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(indexes) != fmt.Sprint(test.indexes) || n != len(test.indexes) {
+			t.Fatalf("Unexpected outliers by %s: %v", test.detector.Name(), indexes)
+		}
+		if num := len(tracklog.Tracks[0].Segments[0].Points); num != 30-n {
+			t.Fatalf("Unexpected number of points: %d", num)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestRemoveOutlierOfLowValue(t *testing.T) {
+	// points every 100 meters eastward, where point[15] repeats point[14], so
+	// the line from point[14] is far shorter than the others
+	trkpts := ""
+	for i := 0; i < 30; i++ {
+		k := i
+		if i >= 15 {
+			k--
+		}
+		trkpts += fmt.Sprintf(`<trkpt lat="24.0" lon="%f"></trkpt>`, 121+float64(k)*100/101582)
+	}
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, trkpts))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outliers := make([]*Outlier, 0)
+	outlier := RemoveOutlierByDistance(2)
+	outlier.Report = func(o *Outlier) {
+		outliers = append(outliers, o)
+	}
+	n, err := outlier.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(outliers) != 1 || outliers[0].Index != 14 || outliers[0].Reason == "" {
+		t.Fatalf("Unexpected outliers: %d, %v", n, outliers)
+	}
+}
@@ -0,0 +1,249 @@
+package gpxutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"gpxtoolkit/gpx"
+)
+
+// PaceModel estimates the time of walking along a line.
+type PaceModel interface {
+	Name() string
+	// Duration estimates the time of walking a horizontal distance with an
+	// elevation difference, both in meters.
+	Duration(dist, dele float64) time.Duration
+}
+
+func hours(h float64) time.Duration {
+	return time.Duration(h * float64(time.Hour))
+}
+
+// ConstantPace walks at a constant speed regardless of the terrain.
+type ConstantPace struct {
+	Speed float64 // meters per second
+}
+
+func (p *ConstantPace) Name() string {
+	return fmt.Sprintf("Constant %.2f m/s", p.Speed)
+}
+
+func (p *ConstantPace) Duration(dist, dele float64) time.Duration {
+	return time.Duration(dist / p.Speed * float64(time.Second))
+}
+
+// ToblerPace is Tobler's hiking function: 6 × e^(-3.5 × |grade + 0.05|) km/h.
+// The grade is limited to ±100% so that elevation noise between nearby points
+// doesn't stall the walk.
+type ToblerPace struct{}
+
+func (p *ToblerPace) Name() string {
+	return "Tobler"
+}
+
+func (p *ToblerPace) Duration(dist, dele float64) time.Duration {
+	if dist <= 0 {
+		return 0
+	}
+	grade := math.Max(-1, math.Min(1, dele/dist))
+	speed := 6 * math.Exp(-3.5*math.Abs(grade+0.05))
+	return hours(dist / 1000 / speed)
+}
+
+// NaismithPace is Naismith's rule (5 km/h plus 1 hour per 600 meters of
+// ascent) with Langmuir's corrections for descents: 10 minutes less per 300
+// meters of gentle descent (5° to 12°) and 10 minutes more per 300 meters of
+// steep descent (over 12°).
+type NaismithPace struct{}
+
+func (p *NaismithPace) Name() string {
+	return "Naismith"
+}
+
+func (p *NaismithPace) Duration(dist, dele float64) time.Duration {
+	h := dist / 5000
+	if dele > 0 {
+		h += dele / 600
+	} else if dele < 0 {
+		angle := math.Atan2(-dele, dist) * 180 / math.Pi
+		if angle > 12 {
+			h += -dele / 1800
+		} else if angle >= 5 {
+			h -= -dele / 1800
+		}
+	}
+	return hours(h)
+}
+
+// SACPace is the hiking time (Wanderzeit) of the Swiss Alpine Club: 4.2 km/h
+// horizontally, 300 meters per hour of ascent and 500 meters per hour of
+// descent, where the smaller of the horizontal and vertical times is halved
+// and added to the larger one.
+type SACPace struct{}
+
+func (p *SACPace) Name() string {
+	return "SAC"
+}
+
+func (p *SACPace) Duration(dist, dele float64) time.Duration {
+	h := dist / 4200
+	v := 0.0
+	if dele > 0 {
+		v = dele / 300
+	} else {
+		v = -dele / 500
+	}
+	return hours(math.Max(h, v) + math.Min(h, v)/2)
+}
+
+// CalibratedPace interpolates the speed by grade from a table fitted to
+// recorded tracks. It is saved and loaded as JSON.
+type CalibratedPace struct {
+	Grades []float64 // ascending grades, e.g. -0.1 for -10%
+	Speeds []float64 // horizontal speed in meters per second at each grade
+}
+
+func (p *CalibratedPace) Name() string {
+	return "Calibrated"
+}
+
+func (p *CalibratedPace) Duration(dist, dele float64) time.Duration {
+	if dist <= 0 {
+		return 0
+	}
+	return time.Duration(dist / p.Speed(dele/dist) * float64(time.Second))
+}
+
+// Speed returns the horizontal speed in meters per second at the grade.
+func (p *CalibratedPace) Speed(grade float64) float64 {
+	n := len(p.Grades)
+	i := sort.SearchFloat64s(p.Grades, grade)
+	if i <= 0 {
+		return p.Speeds[0]
+	}
+	if i >= n {
+		return p.Speeds[n-1]
+	}
+	ratio := (grade - p.Grades[i-1]) / (p.Grades[i] - p.Grades[i-1])
+	return p.Speeds[i-1] + (p.Speeds[i]-p.Speeds[i-1])*ratio
+}
+
+// OpenCalibratedPace loads a CalibratedPace from a JSON file.
+func OpenCalibratedPace(file string) (*CalibratedPace, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ParseCalibratedPace(r)
+}
+
+// ParseCalibratedPace decodes and validates a CalibratedPace from JSON.
+func ParseCalibratedPace(r io.Reader) (*CalibratedPace, error) {
+	p := &CalibratedPace{}
+	err := json.NewDecoder(r).Decode(p)
+	if err != nil {
+		return nil, err
+	}
+	err = p.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate checks that the grades are ascending and the speeds are positive.
+func (p *CalibratedPace) Validate() error {
+	if len(p.Grades) <= 0 {
+		return fmt.Errorf("no grades in pace model")
+	}
+	if len(p.Grades) != len(p.Speeds) {
+		return fmt.Errorf("%d grades but %d speeds in pace model", len(p.Grades), len(p.Speeds))
+	}
+	for i, g := range p.Grades {
+		if i > 0 && g <= p.Grades[i-1] {
+			return fmt.Errorf("grades are not ascending at [%d]: %f", i, g)
+		}
+		if p.Speeds[i] <= 0 {
+			return fmt.Errorf("invalid speed at [%d]: %f", i, p.Speeds[i])
+		}
+	}
+	return nil
+}
+
+// CalibratePace fits a CalibratedPace to recorded segments. Consecutive points
+// are merged into samples of at least the given horizontal length, and samples
+// slower than minSpeed (m/s) are regarded as stops and excluded. Samples are
+// grouped into bins of the given grade width, and bins with less than 100
+// meters of samples are dropped.
+func CalibratePace(segments [][]*gpx.Point, length, binWidth, minSpeed float64) (*CalibratedPace, error) {
+	type bin struct {
+		dist    float64
+		seconds float64
+	}
+	bins := make(map[int]*bin)
+	for _, points := range segments {
+		var start, prev *gpx.Point
+		dist := 0.0
+		for _, p := range points {
+			if p.NanoTime == nil || p.Elevation == nil {
+				continue
+			}
+			if start == nil {
+				start, prev = p, p
+				continue
+			}
+			dist += HaversinDistance(prev, p)
+			prev = p
+			if dist < length {
+				continue
+			}
+			seconds := p.Time().Sub(start.Time()).Seconds()
+			grade := (p.GetElevation() - start.GetElevation()) / dist
+			if seconds > 0 && dist/seconds >= minSpeed {
+				key := int(math.Round(grade / binWidth))
+				b := bins[key]
+				if b == nil {
+					b = &bin{}
+					bins[key] = b
+				}
+				b.dist += dist
+				b.seconds += seconds
+			}
+			start = p
+			dist = 0
+		}
+	}
+	keys := make([]int, 0, len(bins))
+	for k, b := range bins {
+		if b.dist < 100 {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) <= 0 {
+		return nil, fmt.Errorf("not enough timestamped points with elevation for calibration")
+	}
+	sort.Ints(keys)
+	pace := &CalibratedPace{
+		Grades: make([]float64, len(keys)),
+		Speeds: make([]float64, len(keys)),
+	}
+	for i, k := range keys {
+		pace.Grades[i] = math.Round(float64(k)*binWidth*1e6) / 1e6
+		pace.Speeds[i] = bins[k].dist / bins[k].seconds
+	}
+	return pace, nil
+}
+
+// PaceModels are the built-in pace models.
+var PaceModels = map[string]PaceModel{
+	"tobler":   &ToblerPace{},
+	"naismith": &NaismithPace{},
+	"sac":      &SACPace{},
+}
@@ -0,0 +1,136 @@
+package gpxutil
+
+import (
+	"bytes"
+	"gpxtoolkit/gpx"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPaceModels(t *testing.T) {
+	tobler := &ToblerPace{}
+	if d := tobler.Duration(6000, -300); d != time.Hour {
+		t.Fatalf("Unexpected duration: %v", d)
+	}
+	naismith := &NaismithPace{}
+	if d := naismith.Duration(5000, 600); d != 2*time.Hour {
+		t.Fatalf("Unexpected duration: %v", d)
+	}
+	// 5000m with -1500m is steeper than 12° while 5000m with -900m is gentle
+	if d := naismith.Duration(5000, -1500); d != 110*time.Minute {
+		t.Fatalf("Unexpected duration: %v", d)
+	}
+	if d := naismith.Duration(5000, -900); d != 30*time.Minute {
+		t.Fatalf("Unexpected duration: %v", d)
+	}
+	sac := &SACPace{}
+	if d := sac.Duration(4200, 600); d != 150*time.Minute {
+		t.Fatalf("Unexpected duration: %v", d)
+	}
+	calibrated := &CalibratedPace{
+		Grades: []float64{-0.1, 0, 0.1},
+		Speeds: []float64{1.2, 1.0, 0.6},
+	}
+	if s := calibrated.Speed(0.05); math.Abs(s-0.8) > 1e-9 {
+		t.Fatalf("Unexpected speed: %f", s)
+	}
+	if s := calibrated.Speed(0.5); s != 0.6 {
+		t.Fatalf("Unexpected speed: %f", s)
+	}
+	loaded, err := ParseCalibratedPace(bytes.NewBufferString(`{"Grades":[-0.1,0,0.1],"Speeds":[1.2,1.0,0.6]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, calibrated) {
+		t.Fatalf("Unexpected pace model: %v", loaded)
+	}
+	_, err = ParseCalibratedPace(bytes.NewBufferString(`{"Grades":[0.1,0],"Speeds":[1.0,0.6]}`))
+	if err == nil {
+		t.Fatal("Unexpected success of descending grades")
+	}
+}
+
+func TestEstimateTime(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<wpt lat="24.0010" lon="121.0"><name>A</name></wpt>
+<trk>
+	<trkseg>
+	<trkpt lat="24.0000" lon="121.0"><ele>0</ele></trkpt>
+	<trkpt lat="24.0010" lon="121.0"><ele>0</ele></trkpt>
+	<trkpt lat="24.0020" lon="121.0"><ele>0</ele></trkpt>
+	</trkseg>
+</trk>
+</gpx>`
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	estimate := &EstimateTime{
+		Pace:      &ConstantPace{Speed: 1},
+		Threshold: 30,
+	}
+	legs, err := estimate.Legs(tracklog.Tracks[0].Points(), tracklog.WayPoints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(legs) != 2 {
+		t.Fatalf("Unexpected number of legs: %d", len(legs))
+	}
+	if legs[0].To.GetName() != "A" || legs[1].From.GetName() != "A" {
+		t.Fatalf("Unexpected legs: %v", legs)
+	}
+	if math.Round(legs[0].Duration.Seconds()) != 111 {
+		t.Fatalf("Unexpected duration: %v", legs[0].Duration)
+	}
+}
+
+func TestEstimateTimeLegsBetweenPoints(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<wpt lat="24.0000" lon="121.0"><name>Start</name></wpt>
+<wpt lat="24.0015" lon="121.0"><name>A</name></wpt>
+<wpt lat="24.0025" lon="121.0"><name>B</name></wpt>
+<trk>
+	<trkseg>
+	<trkpt lat="24.0000" lon="121.0"><ele>0</ele></trkpt>
+	<trkpt lat="24.0010" lon="121.0"><ele>0</ele></trkpt>
+	<trkpt lat="24.0020" lon="121.0"><ele>0</ele></trkpt>
+	<trkpt lat="24.0030" lon="121.0"><ele>0</ele></trkpt>
+	<trkpt lat="24.0040" lon="121.0"><ele>0</ele></trkpt>
+	</trkseg>
+</trk>
+</gpx>`
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	estimate := &EstimateTime{
+		Pace:      &ConstantPace{Speed: 1},
+		Threshold: 30,
+	}
+	for _, c := range []struct {
+		waypoints []*gpx.WayPoint
+		names     []string
+		distances []float64
+	}{
+		{tracklog.WayPoints[1:], []string{"→A", "A→B", "B→"}, []float64{167, 111, 167}},
+		{tracklog.WayPoints, []string{"Start→A", "A→B", "B→"}, []float64{167, 111, 167}},
+	} {
+		legs, err := estimate.Legs(tracklog.Tracks[0].Points(), c.waypoints)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(legs) != len(c.names) {
+			t.Fatalf("Unexpected number of legs: %d", len(legs))
+		}
+		for i, leg := range legs {
+			name := leg.From.GetName() + "→" + leg.To.GetName()
+			if name != c.names[i] || math.Round(leg.Distance) != c.distances[i] {
+				t.Fatalf("Unexpected leg %d: %s of %fm", i, name, leg.Distance)
+			}
+		}
+	}
+}
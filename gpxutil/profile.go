@@ -0,0 +1,118 @@
+package gpxutil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gpxtoolkit/gpx"
+)
+
+// profile is the elevation profile of points along their horizontal chainage.
+type profile struct {
+	points    []*gpx.Point
+	chainages []float64
+}
+
+func newProfile(points []*gpx.Point) (*profile, error) {
+	p := &profile{
+		points:    points,
+		chainages: make([]float64, len(points)),
+	}
+	for i, pt := range points {
+		if pt.Elevation == nil {
+			return nil, fmt.Errorf("missing elevation in point[%d]", i)
+		}
+		if i > 0 {
+			p.chainages[i] = p.chainages[i-1] + HaversinDistance(points[i-1], pt)
+		}
+	}
+	return p, nil
+}
+
+func (p *profile) length() float64 {
+	if len(p.chainages) <= 0 {
+		return 0
+	}
+	return p.chainages[len(p.chainages)-1]
+}
+
+// locate returns the index of the line containing the chainage and the ratio
+// of the chainage within the line.
+func (p *profile) locate(chainage float64) (int, float64) {
+	n := len(p.chainages)
+	i := sort.SearchFloat64s(p.chainages, chainage)
+	if i <= 0 {
+		return 0, 0
+	}
+	if i >= n {
+		return n - 2, 1
+	}
+	dist := p.chainages[i] - p.chainages[i-1]
+	if dist == 0 {
+		return i - 1, 1
+	}
+	return i - 1, (chainage - p.chainages[i-1]) / dist
+}
+
+func (p *profile) elevationAt(chainage float64) float64 {
+	if len(p.points) == 1 {
+		return p.points[0].GetElevation()
+	}
+	i, ratio := p.locate(chainage)
+	a := p.points[i].GetElevation()
+	b := p.points[i+1].GetElevation()
+	return a + (b-a)*ratio
+}
+
+func (p *profile) pointAt(chainage float64) *gpx.Point {
+	if len(p.points) == 1 {
+		return p.points[0]
+	}
+	i, ratio := p.locate(chainage)
+	if ratio <= 0 {
+		return p.points[i]
+	} else if ratio >= 1 {
+		return p.points[i+1]
+	}
+	return interpolate(p.points[i], p.points[i+1], ratio)
+}
+
+// integrals returns the cumulative area under the profile at each point.
+func (p *profile) integrals() []float64 {
+	areas := make([]float64, len(p.points))
+	for i := 1; i < len(p.points); i++ {
+		a := p.points[i-1].GetElevation()
+		b := p.points[i].GetElevation()
+		areas[i] = areas[i-1] + (a+b)/2*(p.chainages[i]-p.chainages[i-1])
+	}
+	return areas
+}
+
+func (p *profile) integralAt(areas []float64, chainage float64) float64 {
+	if len(p.points) == 1 {
+		return 0
+	}
+	i, ratio := p.locate(chainage)
+	a := p.points[i].GetElevation()
+	dist := (p.chainages[i+1] - p.chainages[i]) * ratio
+	return areas[i] + (a+p.elevationAt(chainage))/2*dist
+}
+
+// smoothedElevations returns the distance-weighted average elevation of each
+// point over a horizontal window (in meters) centered at the point.
+func (p *profile) smoothedElevations(window float64) []float64 {
+	elevations := make([]float64, len(p.points))
+	areas := p.integrals()
+	total := p.length()
+	for i, c := range p.chainages {
+		from := math.Max(0, c-window/2)
+		to := math.Min(total, c+window/2)
+		if window <= 0 || to <= from {
+			elevations[i] = p.points[i].GetElevation()
+			continue
+		}
+		elevations[i] = (p.integralAt(areas, to) - p.integralAt(areas, from)) / (to - from)
+	}
+	return elevations
+}
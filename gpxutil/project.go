@@ -11,7 +11,7 @@ import (
 )
 
 type ProjectWaypoints struct {
-	DistanceFunc DistanceFunc
+	DistanceMode DistanceMode
 	Threshold    float64
 	KeepOriginal bool
 }
@@ -27,8 +27,8 @@ func (c *ProjectWaypoints) Run(tracklog *gpx.TrackLog) (int, error) {
 			points = append(points, seg.Points...)
 		}
 	}
-	lines := getLines(c.DistanceFunc, points)
-	projections := projectWaypoints(c.DistanceFunc, lines, tracklog.WayPoints, c.Threshold)
+	lines := getLines(c.DistanceMode, points)
+	projections := projectWaypoints(c.DistanceMode, lines, tracklog.WayPoints, c.Threshold)
 	waypoints := make([]*gpx.WayPoint, 0)
 	for _, p := range projections {
 		wpt := proto.Clone(p.waypoint).(*gpx.WayPoint)
@@ -67,7 +67,7 @@ type segment struct {
 	points []*gpx.Point
 }
 
-func projectWaypoints(distanceFunc DistanceFunc, lines []*line, waypoints []*gpx.WayPoint, threshold float64) projections {
+func projectWaypoints(mode DistanceMode, lines []*line, waypoints []*gpx.WayPoint, threshold float64) projections {
 	projections := make(projections, 0)
 	for _, w := range waypoints {
 		p := w.GetPoint()
@@ -90,7 +90,7 @@ func projectWaypoints(distanceFunc DistanceFunc, lines []*line, waypoints []*gpx
 				prj.waypoint = w
 				prj.line = l
 				prj.distanceToLine = dist
-				prj.mileage = mileage - l.dist + distanceFunc(l.a, prj.point)
+				prj.mileage = mileage - l.dist + mode.Distance(l.a, prj.point)
 				closest = prj
 			}
 		}
@@ -107,9 +107,13 @@ func projectWaypoints(distanceFunc DistanceFunc, lines []*line, waypoints []*gpx
 	return projections
 }
 
-func sliceByWaypoints(distanceFunc DistanceFunc, points []*gpx.Point, waypoints []*gpx.WayPoint, threshold float64) ([]*segment, error) {
-	lines := getLines(distanceFunc, points)
-	projections := projectWaypoints(distanceFunc, lines, waypoints, threshold)
+// sliceByWaypoints slices the points into segments between the projections of
+// the waypoints. The points before the first waypoint are merged into the
+// first segment, or sliced as a leading segment without a starting waypoint
+// if leading is true.
+func sliceByWaypoints(mode DistanceMode, points []*gpx.Point, waypoints []*gpx.WayPoint, threshold float64, leading bool) ([]*segment, error) {
+	lines := getLines(mode, points)
+	projections := projectWaypoints(mode, lines, waypoints, threshold)
 	segments := make([]*segment, 0)
 	seg := &segment{
 		points: make([]*gpx.Point, 0),
@@ -121,7 +125,7 @@ func sliceByWaypoints(distanceFunc DistanceFunc, points []*gpx.Point, waypoints
 			seg.points = append(seg.points, l.a)
 			if prj.line == l {
 				lines = lines[i+1:]
-				if seg.a.point == nil {
+				if seg.a.point == nil && (!leading || prj.mileage <= 0) {
 					seg.a = struct {
 						waypoint *gpx.WayPoint
 						point    *gpx.Point
@@ -8,7 +8,7 @@ import (
 
 func RemoveDuplicated() *RemoveByCriteria {
 	return &RemoveByCriteria{
-		distanceFunc: HaversinDistance,
+		distanceMode: HaversinMode,
 		shouldRemove: func(line *line) bool {
 			return line.a.Equals(line.b)
 		},
@@ -17,7 +17,7 @@ func RemoveDuplicated() *RemoveByCriteria {
 
 func RemoveDistanceLessThan(distance float64) *RemoveByCriteria {
 	return &RemoveByCriteria{
-		distanceFunc: HaversinDistance,
+		distanceMode: HaversinMode,
 		shouldRemove: func(line *line) bool {
 			ret := line.dist < distance
 			if ret {
@@ -30,7 +30,7 @@ func RemoveDistanceLessThan(distance float64) *RemoveByCriteria {
 
 func RemoveDurationLessThan(duration time.Duration) *RemoveByCriteria {
 	return &RemoveByCriteria{
-		distanceFunc: HaversinDistance,
+		distanceMode: HaversinMode,
 		shouldRemove: func(line *line) bool {
 			if line.duration == nil {
 				return false
@@ -48,7 +48,7 @@ func RemoveDurationLessThan(duration time.Duration) *RemoveByCriteria {
 }
 
 type RemoveByCriteria struct {
-	distanceFunc DistanceFunc
+	distanceMode DistanceMode
 	shouldRemove func(line *line) bool
 }
 
@@ -76,7 +76,7 @@ func (r *RemoveByCriteria) Run(tracklog *gpx.TrackLog) (int, error) {
 }
 
 func (r *RemoveByCriteria) remove(points []*gpx.Point) ([]*gpx.Point, error) {
-	lines := getLines(r.distanceFunc, points)
+	lines := getLines(r.distanceMode, points)
 	accepted := make([]*line, 0)
 	for _, line := range lines {
 		if r.shouldRemove(line) {
@@ -0,0 +1,65 @@
+package gpxutil
+
+import (
+	"fmt"
+	"time"
+
+	"gpxtoolkit/gpx"
+)
+
+// ResampleByTime replaces the points of each segment by one point per Interval
+// from the first timestamped point, with position and elevation linearly
+// interpolated. Points without time are ignored. If MinSpeed (m/s) is positive,
+// samples on lines slower than it, i.e. during stops, are dropped, except for
+// the first sample of the segment.
+type ResampleByTime struct {
+	Interval time.Duration
+	MinSpeed float64
+}
+
+func (c *ResampleByTime) Name() string {
+	return fmt.Sprintf("Resample by Time %v", c.Interval)
+}
+
+// Run returns the number of points after resampling.
+func (c *ResampleByTime) Run(tracklog *gpx.TrackLog) (int, error) {
+	if c.Interval <= 0 {
+		return 0, fmt.Errorf("invalid interval: %v", c.Interval)
+	}
+	n := 0
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			seg.Points = c.resample(seg.Points)
+			n += len(seg.Points)
+		}
+	}
+	return n, nil
+}
+
+func (c *ResampleByTime) resample(points []*gpx.Point) []*gpx.Point {
+	timed := make([]*gpx.Point, 0, len(points))
+	for _, p := range points {
+		if p.NanoTime != nil && (len(timed) <= 0 || p.Time().After(timed[len(timed)-1].Time())) {
+			timed = append(timed, p)
+		}
+	}
+	if len(timed) <= 1 {
+		return timed
+	}
+	start, end := timed[0].Time(), timed[len(timed)-1].Time()
+	res := make([]*gpx.Point, 0, int(end.Sub(start)/c.Interval)+1)
+	i := 0
+	for tm := start; !tm.After(end); tm = tm.Add(c.Interval) {
+		for i < len(timed)-2 && !tm.Before(timed[i+1].Time()) {
+			i++
+		}
+		a, b := timed[i], timed[i+1]
+		if c.MinSpeed > 0 && len(res) > 0 {
+			if HaversinDistance(a, b)/b.Time().Sub(a.Time()).Seconds() < c.MinSpeed {
+				continue
+			}
+		}
+		res = append(res, interpolateAt(a, b, tm))
+	}
+	return res
+}
@@ -0,0 +1,59 @@
+package gpxutil
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestResampleByTime(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk>
+	<trkseg>
+	<trkpt lat="24.0000" lon="121.0"><ele>100</ele><time>2022-01-01T00:00:00Z</time></trkpt>
+	<trkpt lat="24.0010" lon="121.0"><ele>110</ele><time>2022-01-01T00:00:25Z</time></trkpt>
+	<trkpt lat="24.0010" lon="121.0"><ele>110</ele><time>2022-01-01T00:01:05Z</time></trkpt>
+	<trkpt lat="24.0020" lon="121.0"><ele>120</ele><time>2022-01-01T00:01:15Z</time></trkpt>
+	</trkseg>
+</trk>
+</gpx>`
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resample := &ResampleByTime{Interval: 10 * time.Second}
+	n, err := resample.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 8 {
+		t.Fatalf("Unexpected number of points: %d", n)
+	}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := tracklog.Tracks[0].Segments[0].Points
+	for i, p := range points {
+		if want := start.Add(time.Duration(i) * 10 * time.Second); !p.Time().Equal(want) {
+			t.Fatalf("Unexpected time of point[%d]: %v", i, p.Time())
+		}
+	}
+	if ele := points[1].GetElevation(); ele != 104 {
+		t.Fatalf("Unexpected elevation of point[1]: %f", ele)
+	}
+
+	tracklog, err = gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resample.MinSpeed = 0.5
+	n, err = resample.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 00:30 to 01:00 are during the stop
+	if n != 4 {
+		t.Fatalf("Unexpected number of points without stops: %d", n)
+	}
+}
@@ -8,7 +8,7 @@ import (
 )
 
 type ReSegment struct {
-	DistanceFunc DistanceFunc
+	DistanceMode DistanceMode
 	Threshold    float64
 }
 
@@ -23,7 +23,7 @@ func (c *ReSegment) Run(tracklog *gpx.TrackLog) (int, error) {
 			points = append(points, seg.Points...)
 		}
 	}
-	segments, err := sliceByWaypoints(c.DistanceFunc, points, tracklog.WayPoints, c.Threshold)
+	segments, err := sliceByWaypoints(c.DistanceMode, points, tracklog.WayPoints, c.Threshold, false)
 	if err != nil {
 		return 0, err
 	}
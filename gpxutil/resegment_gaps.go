@@ -0,0 +1,123 @@
+package gpxutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/log"
+)
+
+// ReSegmentByGaps starts a new segment wherever the line from the previous
+// point exceeds any of the thresholds, e.g. when recording was paused or GPS
+// was lost in a tunnel. Zero thresholds are disabled.
+type ReSegmentByGaps struct {
+	MaxDuration time.Duration // time gap
+	MaxDistance float64       // distance jump in meters
+	MaxSpeed    float64       // implied speed in m/s
+}
+
+func (c *ReSegmentByGaps) Name() string {
+	criteria := make([]string, 0)
+	if c.MaxDuration > 0 {
+		criteria = append(criteria, fmt.Sprintf("time gap %v", c.MaxDuration))
+	}
+	if c.MaxDistance > 0 {
+		criteria = append(criteria, fmt.Sprintf("distance %.0fm", c.MaxDistance))
+	}
+	if c.MaxSpeed > 0 {
+		criteria = append(criteria, fmt.Sprintf("speed %.1fm/s", c.MaxSpeed))
+	}
+	return fmt.Sprintf("Re-Segment by %s", strings.Join(criteria, ", "))
+}
+
+// Run returns the number of new segments.
+func (c *ReSegmentByGaps) Run(tracklog *gpx.TrackLog) (int, error) {
+	n := 0
+	for _, t := range tracklog.Tracks {
+		segments := make([]*gpx.Segment, 0, len(t.Segments))
+		for _, seg := range t.Segments {
+			current := &gpx.Segment{Points: make([]*gpx.Point, 0)}
+			for _, l := range getLines(HaversinMode, seg.Points) {
+				if len(current.Points) <= 0 {
+					current.Points = append(current.Points, l.a)
+				}
+				if c.isGap(l) {
+					segments = append(segments, current)
+					current = &gpx.Segment{Points: []*gpx.Point{l.b}}
+					n++
+					continue
+				}
+				current.Points = append(current.Points, l.b)
+			}
+			if len(seg.Points) == 1 {
+				current.Points = append(current.Points, seg.Points[0])
+			}
+			segments = append(segments, current)
+		}
+		t.Segments = segments
+	}
+	return n, nil
+}
+
+func (c *ReSegmentByGaps) isGap(l *line) bool {
+	if c.MaxDistance > 0 && l.dist > c.MaxDistance {
+		log.Debugf("Distance jump: %fm", l.dist)
+		return true
+	}
+	if l.duration == nil {
+		return false
+	}
+	if c.MaxDuration > 0 && *l.duration > c.MaxDuration {
+		log.Debugf("Time gap: %v", *l.duration)
+		return true
+	}
+	if c.MaxSpeed > 0 && l.speed != nil && *l.speed > c.MaxSpeed {
+		log.Debugf("Implied speed: %fm/s", *l.speed)
+		return true
+	}
+	return false
+}
+
+// RemoveTinySegments removes segments with fewer points than MinPoints or
+// shorter than MinLength meters, and tracks left without segments by that.
+type RemoveTinySegments struct {
+	MinPoints int
+	MinLength float64
+}
+
+func (c *RemoveTinySegments) Name() string {
+	return fmt.Sprintf("Remove Segments of less than %d points or %.0fm", c.MinPoints, c.MinLength)
+}
+
+// Run returns the number of removed segments.
+func (c *RemoveTinySegments) Run(tracklog *gpx.TrackLog) (int, error) {
+	n := 0
+	tracks := make([]*gpx.Track, 0, len(tracklog.Tracks))
+	for _, t := range tracklog.Tracks {
+		segments := make([]*gpx.Segment, 0, len(t.Segments))
+		for _, seg := range t.Segments {
+			if len(seg.Points) < c.MinPoints || c.length(seg) < c.MinLength {
+				n++
+				continue
+			}
+			segments = append(segments, seg)
+		}
+		if len(segments) <= 0 && len(t.Segments) > 0 {
+			continue
+		}
+		t.Segments = segments
+		tracks = append(tracks, t)
+	}
+	tracklog.Tracks = tracks
+	return n, nil
+}
+
+func (c *RemoveTinySegments) length(seg *gpx.Segment) float64 {
+	length := 0.0
+	for _, l := range getLines(HaversinMode, seg.Points) {
+		length += l.dist
+	}
+	return length
+}
@@ -0,0 +1,53 @@
+package gpxutil
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestReSegmentByGaps(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk>
+	<trkseg>
+	<trkpt lat="24.0000" lon="121.0"><time>2022-01-01T00:00:00Z</time></trkpt>
+	<trkpt lat="24.0001" lon="121.0"><time>2022-01-01T00:00:10Z</time></trkpt>
+	<trkpt lat="24.0002" lon="121.0"><time>2022-01-01T00:00:20Z</time></trkpt>
+	<trkpt lat="24.0003" lon="121.0"><time>2022-01-01T01:00:00Z</time></trkpt>
+	<trkpt lat="24.0004" lon="121.0"><time>2022-01-01T01:00:10Z</time></trkpt>
+	<trkpt lat="24.0104" lon="121.0"><time>2022-01-01T01:00:20Z</time></trkpt>
+	<trkpt lat="24.0105" lon="121.0"><time>2022-01-01T01:00:30Z</time></trkpt>
+	<trkpt lat="24.0106" lon="121.0"><time>2022-01-01T01:00:40Z</time></trkpt>
+	</trkseg>
+</trk>
+</gpx>`
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resegment := &ReSegmentByGaps{MaxDuration: 10 * time.Minute, MaxDistance: 500}
+	n, err := resegment.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments := tracklog.Tracks[0].Segments
+	if n != 2 || len(segments) != 3 {
+		t.Fatalf("Unexpected number of segments: %d", len(segments))
+	}
+	for i, num := range []int{3, 2, 3} {
+		if len(segments[i].Points) != num {
+			t.Fatalf("Unexpected number of points in segment[%d]: %d", i, len(segments[i].Points))
+		}
+	}
+	tiny := &RemoveTinySegments{MinPoints: 3}
+	n, err = tiny.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(tracklog.Tracks[0].Segments) != 2 {
+		t.Fatalf("Unexpected number of removed segments: %d", n)
+	}
+}
@@ -9,12 +9,16 @@ import (
 )
 
 type ReTimestamp struct {
-	DistanceFunc DistanceFunc
+	DistanceMode DistanceMode
 	Start        time.Time
 	Speed        float64
+	Pace         *EstimateTime // overrides Speed if specified
 }
 
 func (c *ReTimestamp) Name() string {
+	if c.Pace != nil {
+		return fmt.Sprintf("Re-Timestamp from %v with %s Pace", c.Start, c.Pace.Pace.Name())
+	}
 	return fmt.Sprintf("Re-Timestamp from %v with Speed %f m/s", c.Start, c.Speed)
 }
 
@@ -35,7 +39,10 @@ func (c *ReTimestamp) Run(tracklog *gpx.TrackLog) (int, error) {
 }
 
 func (c *ReTimestamp) timestamp(points []*gpx.Point, start time.Time) (time.Time, error) {
-	lines := getLines(c.DistanceFunc, points)
+	if c.Pace != nil {
+		return c.timestampByPace(points, start)
+	}
+	lines := getLines(c.DistanceMode, points)
 	for i, line := range lines {
 		line.a.NanoTime = proto.Int64(start.UnixNano())
 		duration := time.Duration(line.dist/c.Speed) * time.Second
@@ -46,3 +53,15 @@ func (c *ReTimestamp) timestamp(points []*gpx.Point, start time.Time) (time.Time
 	}
 	return start, nil
 }
+
+func (c *ReTimestamp) timestampByPace(points []*gpx.Point, start time.Time) (time.Time, error) {
+	lines := c.Pace.lines(points)
+	for i, line := range lines {
+		line.A.NanoTime = proto.Int64(start.UnixNano())
+		start = start.Add(c.Pace.Pace.Duration(line.Distance, line.Grade*line.Distance))
+		if i == len(lines)-1 {
+			line.B.NanoTime = proto.Int64(start.UnixNano())
+		}
+	}
+	return start, nil
+}
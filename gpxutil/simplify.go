@@ -5,15 +5,29 @@ import (
 	"gpxtoolkit/elevation"
 	"gpxtoolkit/gpx"
 	"gpxtoolkit/simpleline"
-	"time"
-
-	"google.golang.org/protobuf/proto"
+	"runtime"
 )
 
+// SimplifyMethods are the methods of Simplify.
+var SimplifyMethods = []string{"rdp", "vw"}
+
+// Simplify keeps the original points selected by the method, with distances in
+// meters in a local projection, and also in elevation if Elevation is true:
+//
+//	rdp: Ramer–Douglas–Peucker, keeping points farther than Epsilon from the line
+//	vw:  Visvalingam–Whyatt, keeping points of triangle areas over Epsilon² m²
+//
+// If MaxPoints is positive, tracks still having more points are simplified to
+// exactly MaxPoints points by the ranked importance of the method, which are
+// shared by segments in proportion to their numbers of points. It fails if the
+// segments are more than MaxPoints/2, which can not keep their end points.
 type Simplify struct {
-	Service elevation.Service
-	Epsilon float64
-	First   bool
+	Service   elevation.Service // looks up points without elevation
+	Epsilon   float64
+	First     bool   // radial distance simplification before rdp
+	Elevation bool   // 3D simplification with elevation in meters
+	Method    string // rdp if empty
+	MaxPoints int
 }
 
 func (s *Simplify) Name() string {
@@ -21,23 +35,44 @@ func (s *Simplify) Name() string {
 }
 
 func (c *Simplify) Run(tracklog *gpx.TrackLog) (int, error) {
+	switch c.Method {
+	case "", "rdp", "vw":
+	default:
+		return 0, fmt.Errorf("unknown simplification method: %s", c.Method)
+	}
 	n := 0
 	for _, t := range tracklog.Tracks {
-		for _, seg := range t.Segments {
-			num := len(seg.Points)
+		simplified := make([][]*gpx.Point, len(t.Segments))
+		total := 0
+		for i, seg := range t.Segments {
 			points, err := c.simplify(seg.Points)
 			if err != nil {
 				return 0, err
 			}
-			if c.Service != nil {
-				// we don't alter the original points
-				corrected := make([]*gpx.Point, len(points))
-				copy(corrected, points)
-				_, err := correctPoints(c.Service, corrected)
+			simplified[i] = points
+			total += len(points)
+		}
+		if c.MaxPoints > 0 && total > c.MaxPoints {
+			budgets, err := c.budgets(t.Segments)
+			if err != nil {
+				return 0, err
+			}
+			for i, budget := range budgets {
+				points, err := c.simplifyToCount(t.Segments[i].Points, budget)
+				if err != nil {
+					return 0, err
+				}
+				simplified[i] = points
+			}
+		}
+		for i, seg := range t.Segments {
+			num := len(seg.Points)
+			points := simplified[i]
+			if missing := pointsWithoutElevation(points); c.Service != nil && len(missing) > 0 {
+				_, err := correctPoints(c.Service, missing)
 				if err != nil {
 					return 0, err
 				}
-				points = corrected
 			}
 			n += (num - len(points))
 			seg.Points = points
@@ -46,28 +81,124 @@ func (c *Simplify) Run(tracklog *gpx.TrackLog) (int, error) {
 	return n, nil
 }
 
-func (c *Simplify) simplify(points []*gpx.Point) ([]*gpx.Point, error) {
+// budgets shares MaxPoints by the segments in proportion to their numbers of
+// points, with at least the first and last points of each segment. It fails if
+// even those are more than MaxPoints.
+func (c *Simplify) budgets(segments []*gpx.Segment) ([]int, error) {
+	budgets := make([]int, len(segments))
+	sum, rest := 0, 0
+	for i, seg := range segments {
+		budgets[i] = min(len(seg.Points), 2)
+		sum += budgets[i]
+		rest += len(seg.Points) - budgets[i]
+	}
+	if sum > c.MaxPoints {
+		return nil, fmt.Errorf("%d segments need at least %d points over max %d", len(segments), sum, c.MaxPoints)
+	}
+	extra := c.MaxPoints - sum
+	for i, seg := range segments {
+		if rest > 0 {
+			n := extra * (len(seg.Points) - budgets[i]) / rest
+			budgets[i] += n
+			sum += n
+		}
+	}
+	for i, seg := range segments {
+		if sum >= c.MaxPoints {
+			break
+		}
+		n := min(c.MaxPoints-sum, len(seg.Points)-budgets[i])
+		budgets[i] += n
+		sum += n
+	}
+	return budgets, nil
+}
+
+func (c *Simplify) dataPoints(points []*gpx.Point) []simpleline.Point {
+	prj := newLocalProjection(points[0])
 	dataPoints := make([]simpleline.Point, len(points))
 	for i, p := range points {
-		dataPoints[i] = &simpleline.Point3d{
-			X: p.GetLatitude(),
-			Y: p.GetLongitude(),
-			Z: float64(p.Time().Unix()),
+		x, y := prj.project(p)
+		z := 0.0
+		if c.Elevation {
+			z = p.GetElevation()
 		}
+		dataPoints[i] = &simpleline.Point3d{X: x, Y: y, Z: z}
+	}
+	return dataPoints
+}
+
+func (c *Simplify) dims() int {
+	if c.Elevation {
+		return 3
+	}
+	return 2
+}
+
+// coords returns the flat coordinates of the points in the local projection.
+func (c *Simplify) coords(points []*gpx.Point) []float64 {
+	prj := newLocalProjection(points[0])
+	coords := make([]float64, 0, len(points)*c.dims())
+	for _, p := range points {
+		x, y := prj.project(p)
+		coords = append(coords, x, y)
+		if c.Elevation {
+			coords = append(coords, p.GetElevation())
+		}
+	}
+	return coords
+}
+
+func (c *Simplify) simplify(points []*gpx.Point) ([]*gpx.Point, error) {
+	if len(points) <= 0 {
+		return points, nil
+	}
+	var indices []int
+	var err error
+	if c.Method == "vw" {
+		indices, err = simpleline.VWIndex(c.dataPoints(points), c.Epsilon*c.Epsilon, simpleline.Euclidean)
+	} else {
+		indices = simpleline.RDPFlat(c.coords(points), c.dims(), c.Epsilon, c.First, runtime.GOMAXPROCS(0))
 	}
-	simplified, err := simpleline.RDP(dataPoints, c.Epsilon, func(p1, p2 simpleline.Point) float64 {
-		return gpx.GeoDistance(p1.Vector()[0], p1.Vector()[1], p2.Vector()[0], p2.Vector()[1])
-	}, c.First)
 	if err != nil {
 		return nil, err
 	}
-	res := make([]*gpx.Point, len(simplified))
-	for i, p := range simplified {
-		res[i] = &gpx.Point{
-			Latitude:  proto.Float64(p.Vector()[0]),
-			Longitude: proto.Float64(p.Vector()[1]),
-			NanoTime:  proto.Int64(time.Unix(int64(p.Vector()[2]), 0).UnixNano()),
+	return pointsAt(points, indices), nil
+}
+
+func (c *Simplify) simplifyToCount(points []*gpx.Point, count int) ([]*gpx.Point, error) {
+	if len(points) <= count {
+		return points, nil
+	}
+	var indices []int
+	var err error
+	if c.Method == "vw" {
+		indices, err = simpleline.VWCountIndex(c.dataPoints(points), count, simpleline.Euclidean)
+	} else {
+		indices, err = simpleline.RDPCountIndex(c.dataPoints(points), count, simpleline.Euclidean)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pointsAt(points, indices), nil
+}
+
+// pointsWithoutElevation returns the points of no elevation, which are looked
+// up by the elevation service without overwriting recorded elevations.
+func pointsWithoutElevation(points []*gpx.Point) []*gpx.Point {
+	res := make([]*gpx.Point, 0)
+	for _, p := range points {
+		if p.Elevation == nil {
+			res = append(res, p)
 		}
 	}
-	return res, nil
+	return res
+}
+
+func pointsAt(points []*gpx.Point, indices []int) []*gpx.Point {
+	res := make([]*gpx.Point, len(indices))
+	for i, index := range indices {
+		res[i] = points[index]
+	}
+	return res
 }
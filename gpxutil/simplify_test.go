@@ -0,0 +1,161 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"gpxtoolkit/elevation"
+	"gpxtoolkit/gpx"
+)
+
+func TestSimplify(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk>
+	<trkseg>
+	<trkpt lat="24.0000" lon="121.0"><ele>100</ele><time>2022-01-01T00:00:00.250Z</time></trkpt>
+	<trkpt lat="24.0010" lon="121.0"><ele>150</ele><time>2022-01-01T00:01:00.250Z</time></trkpt>
+	<trkpt lat="24.0020" lon="121.0"><ele>200</ele><time>2022-01-01T00:02:00.250Z</time></trkpt>
+	<trkpt lat="24.0030" lon="121.0"><ele>170</ele><time>2022-01-01T00:03:00.250Z</time></trkpt>
+	<trkpt lat="24.0040" lon="121.0"><ele>140</ele><time>2022-01-01T00:04:00.250Z</time></trkpt>
+	</trkseg>
+</trk>
+</gpx>`
+	for _, c := range []struct {
+		elevation bool
+		num       int
+	}{
+		{false, 2},
+		{true, 3},
+	} {
+		tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		original := tracklog.Tracks[0].Segments[0].Points
+		simplify := &Simplify{Epsilon: 10, Elevation: c.elevation}
+		if _, err := simplify.Run(tracklog); err != nil {
+			t.Fatal(err)
+		}
+		points := tracklog.Tracks[0].Segments[0].Points
+		if len(points) != c.num {
+			t.Fatalf("Unexpected number of points with elevation %v: %d", c.elevation, len(points))
+		}
+		if points[0] != original[0] || points[len(points)-1] != original[4] {
+			t.Fatalf("Unexpected points not of the original")
+		}
+		if c.elevation && points[1] != original[2] {
+			t.Fatalf("Unexpected point instead of the peak: %v", points[1])
+		}
+		if points[0].GetElevation() != 100 || points[0].GetNanoTime()%1e9 != 250e6 {
+			t.Fatalf("Unexpected elevation or time: %v", points[0])
+		}
+	}
+}
+
+func TestSimplifyMaxPoints(t *testing.T) {
+	points := ""
+	for i := 0; i < 100; i++ {
+		points += fmt.Sprintf(`<trkpt lat="%f" lon="%f"></trkpt>`, 24+float64(i)*0.001, 121+math.Sin(float64(i)/5)*0.001)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg><trkseg>%s</trkseg></trk>
+</gpx>`, points, points[:len(points)/2])
+	for _, method := range SimplifyMethods {
+		tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		simplify := &Simplify{Method: method, MaxPoints: 30}
+		if _, err := simplify.Run(tracklog); err != nil {
+			t.Fatal(err)
+		}
+		segments := tracklog.Tracks[0].Segments
+		if n := len(segments[0].Points) + len(segments[1].Points); n != 30 {
+			t.Fatalf("Unexpected number of points by %s: %d", method, n)
+		}
+		if len(segments[0].Points) != 20 {
+			t.Fatalf("Unexpected number of points of the longer segment by %s: %d", method, len(segments[0].Points))
+		}
+	}
+}
+
+func TestSimplifyMaxPointsOfManySegments(t *testing.T) {
+	segments := ""
+	for i := 0; i < 300; i++ {
+		points := ""
+		for j := 0; j < 5; j++ {
+			points += fmt.Sprintf(`<trkpt lat="%f" lon="%f"></trkpt>`, 24+float64(i*5+j)*0.001, 121+math.Sin(float64(j))*0.001)
+		}
+		segments += fmt.Sprintf(`<trkseg>%s</trkseg>`, points)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk>%s</trk>
+</gpx>`, segments)
+	for _, method := range SimplifyMethods {
+		tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		simplify := &Simplify{Method: method, MaxPoints: 500}
+		if _, err := simplify.Run(tracklog); err == nil {
+			t.Fatalf("Unexpected success of 300 segments within 500 points by %s", method)
+		}
+		simplify = &Simplify{Method: method, MaxPoints: 1000}
+		if _, err := simplify.Run(tracklog); err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, seg := range tracklog.Tracks[0].Segments {
+			if len(seg.Points) < 2 {
+				t.Fatalf("Unexpected segment of %d points by %s", len(seg.Points), method)
+			}
+			n += len(seg.Points)
+		}
+		if n != 1000 {
+			t.Fatalf("Unexpected number of points by %s: %d", method, n)
+		}
+	}
+}
+
+// fixedElevation is an elevation service of the same elevation everywhere.
+type fixedElevation float64
+
+func (e fixedElevation) Lookup(points []*elevation.LatLon) ([]*float64, error) {
+	elevations := make([]*float64, len(points))
+	for i := range points {
+		v := float64(e)
+		elevations[i] = &v
+	}
+	return elevations, nil
+}
+
+func TestSimplifyKeepsElevation(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>
+<trkpt lat="24.000" lon="121.000"><ele>100</ele></trkpt>
+<trkpt lat="24.001" lon="121.001"></trkpt>
+<trkpt lat="24.002" lon="121.000"><ele>120</ele></trkpt>
+</trkseg></trk>
+</gpx>`
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	simplify := &Simplify{Service: fixedElevation(500), Epsilon: 1}
+	if _, err := simplify.Run(tracklog); err != nil {
+		t.Fatal(err)
+	}
+	points := tracklog.Tracks[0].Segments[0].Points
+	if len(points) != 3 {
+		t.Fatalf("Unexpected number of points: %d", len(points))
+	}
+	if points[0].GetElevation() != 100 || points[1].GetElevation() != 500 || points[2].GetElevation() != 120 {
+		t.Fatalf("Unexpected elevations: %f, %f, %f", points[0].GetElevation(), points[1].GetElevation(), points[2].GetElevation())
+	}
+}
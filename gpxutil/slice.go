@@ -13,7 +13,7 @@ type Slice struct {
 }
 
 type SliceByWaypoints struct {
-	DistanceFunc DistanceFunc
+	DistanceMode DistanceMode
 	Threshold    float64
 	Waypoints    []*gpx.WayPoint
 }
@@ -59,7 +59,7 @@ func (c *SliceByWaypoints) Run(tracklog *gpx.TrackLog) (int, error) {
 }
 
 func (c *SliceByWaypoints) slice(points []*gpx.Point) ([]*Slice, error) {
-	segments, err := sliceByWaypoints(c.DistanceFunc, points, c.Waypoints, c.Threshold)
+	segments, err := sliceByWaypoints(c.DistanceMode, points, c.Waypoints, c.Threshold, false)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,186 @@
+package gpxutil
+
+import (
+	"fmt"
+	"math"
+
+	"gpxtoolkit/gpx"
+	"gpxtoolkit/log"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Smooth corrects the positions of track points by a constant-velocity Kalman
+// filter followed by a Rauch–Tung–Striebel backward pass over each segment.
+// Elevation and time are left untouched.
+type Smooth struct {
+	// ProcessNoise is the standard deviation of acceleration in m/s², i.e. how
+	// quickly the velocity may change.
+	ProcessNoise float64
+	// MeasurementNoise is the standard deviation of GPS positions in meters.
+	// It is scaled by the HDOP of the point if available.
+	MeasurementNoise float64
+}
+
+func (c *Smooth) Name() string {
+	return fmt.Sprintf("Smooth by Kalman filter (process noise %.2f m/s², measurement noise %.1f m)", c.ProcessNoise, c.MeasurementNoise)
+}
+
+func (c *Smooth) Run(tracklog *gpx.TrackLog) (int, error) {
+	if c.ProcessNoise <= 0 {
+		return 0, fmt.Errorf("invalid process noise: %f", c.ProcessNoise)
+	}
+	if c.MeasurementNoise <= 0 {
+		return 0, fmt.Errorf("invalid measurement noise: %f", c.MeasurementNoise)
+	}
+	n := 0
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			n += c.smooth(seg.Points)
+		}
+	}
+	return n, nil
+}
+
+// smooth replaces the points with the smoothed ones in place and returns the
+// number of points.
+func (c *Smooth) smooth(points []*gpx.Point) int {
+	if len(points) <= 2 {
+		return 0
+	}
+	prj := newLocalProjection(points[0])
+	xs := make([]float64, len(points))
+	ys := make([]float64, len(points))
+	rs := make([]float64, len(points))
+	for i, p := range points {
+		xs[i], ys[i] = prj.project(p)
+		r := c.MeasurementNoise
+		if p.Hdop != nil && p.GetHdop() > 0 {
+			r *= p.GetHdop()
+		}
+		rs[i] = r * r
+	}
+	dts := c.intervals(points)
+	xs = c.rts(xs, rs, dts)
+	ys = c.rts(ys, rs, dts)
+	for i, p := range points {
+		lat, lon := prj.unproject(xs[i], ys[i])
+		p.Latitude = proto.Float64(lat)
+		p.Longitude = proto.Float64(lon)
+	}
+	return len(points)
+}
+
+// intervals returns the seconds between consecutive points, or 1 for each
+// interval if any point is missing time or the time goes backward.
+func (c *Smooth) intervals(points []*gpx.Point) []float64 {
+	dts := make([]float64, len(points))
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		if a.NanoTime == nil || b.NanoTime == nil || b.GetNanoTime() < a.GetNanoTime() {
+			log.Debugf("Smoothing by index spacing due to missing or disordered time at point[%d]", i)
+			for j := range dts {
+				dts[j] = 1
+			}
+			return dts
+		}
+		dts[i] = b.Time().Sub(a.Time()).Seconds()
+	}
+	return dts
+}
+
+// rts runs the filter and the backward pass on one axis, where zs are the
+// measured positions in meters, rs the variances of the measurements and dts
+// the seconds since the previous measurements.
+func (c *Smooth) rts(zs, rs, dts []float64) []float64 {
+	n := len(zs)
+	q := c.ProcessNoise * c.ProcessNoise
+	// state is [position, velocity] with covariance [[p00, p01], [p01, p11]]
+	type state struct {
+		x0, x1        float64
+		p00, p01, p11 float64
+	}
+	predicted := make([]state, n)
+	filtered := make([]state, n)
+	filtered[0] = state{x0: zs[0], p00: rs[0], p11: 100}
+	predicted[0] = filtered[0]
+	for k := 1; k < n; k++ {
+		f := filtered[k-1]
+		dt := dts[k]
+		dt2 := dt * dt
+		pr := state{
+			x0:  f.x0 + dt*f.x1,
+			x1:  f.x1,
+			p00: f.p00 + 2*dt*f.p01 + dt2*f.p11 + q*dt2*dt2/4,
+			p01: f.p01 + dt*f.p11 + q*dt2*dt/2,
+			p11: f.p11 + q*dt2,
+		}
+		predicted[k] = pr
+		s := pr.p00 + rs[k]
+		k0, k1 := pr.p00/s, pr.p01/s
+		y := zs[k] - pr.x0
+		filtered[k] = state{
+			x0:  pr.x0 + k0*y,
+			x1:  pr.x1 + k1*y,
+			p00: (1 - k0) * pr.p00,
+			p01: (1 - k0) * pr.p01,
+			p11: pr.p11 - k1*pr.p01,
+		}
+	}
+	smoothed := filtered[n-1]
+	result := make([]float64, n)
+	result[n-1] = smoothed.x0
+	for k := n - 2; k >= 0; k-- {
+		f, pr := filtered[k], predicted[k+1]
+		dt := dts[k+1]
+		// C = P F' inv(P_predicted)
+		det := pr.p00*pr.p11 - pr.p01*pr.p01
+		if det == 0 {
+			smoothed = f
+			result[k] = f.x0
+			continue
+		}
+		a00, a01 := f.p00+dt*f.p01, f.p01
+		a10, a11 := f.p01+dt*f.p11, f.p11
+		c00 := (a00*pr.p11 - a01*pr.p01) / det
+		c01 := (a01*pr.p00 - a00*pr.p01) / det
+		c10 := (a10*pr.p11 - a11*pr.p01) / det
+		c11 := (a11*pr.p00 - a10*pr.p01) / det
+		d0, d1 := smoothed.x0-pr.x0, smoothed.x1-pr.x1
+		e00, e01, e11 := smoothed.p00-pr.p00, smoothed.p01-pr.p01, smoothed.p11-pr.p11
+		smoothed = state{
+			x0:  f.x0 + c00*d0 + c01*d1,
+			x1:  f.x1 + c10*d0 + c11*d1,
+			p00: f.p00 + c00*(c00*e00+c01*e01) + c01*(c00*e01+c01*e11),
+			p01: f.p01 + c00*(c10*e00+c11*e01) + c01*(c10*e01+c11*e11),
+			p11: f.p11 + c10*(c10*e00+c11*e01) + c11*(c10*e01+c11*e11),
+		}
+		result[k] = smoothed.x0
+	}
+	return result
+}
+
+// localProjection is an equirectangular projection in meters around an
+// origin, which is accurate enough within a few kilometers.
+type localProjection struct {
+	lat0, lon0 float64
+	kx, ky     float64 // meters per degree
+}
+
+func newLocalProjection(origin *gpx.Point) *localProjection {
+	ky := 6371393 * math.Pi / 180
+	return &localProjection{
+		lat0: origin.GetLatitude(),
+		lon0: origin.GetLongitude(),
+		kx:   ky * math.Cos(origin.GetLatitude()*math.Pi/180),
+		ky:   ky,
+	}
+}
+
+func (p *localProjection) project(pt *gpx.Point) (float64, float64) {
+	return (pt.GetLongitude() - p.lon0) * p.kx, (pt.GetLatitude() - p.lat0) * p.ky
+}
+
+func (p *localProjection) unproject(x, y float64) (float64, float64) {
+	return p.lat0 + y/p.ky, p.lon0 + x/p.kx
+}
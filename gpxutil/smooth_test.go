@@ -0,0 +1,61 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestSmooth(t *testing.T) {
+	// walking 1 m/s eastward along the equator with ±5 m of jitter
+	trkpts := ""
+	for i := 0; i < 60; i++ {
+		lat := 0.0
+		if i%2 == 1 {
+			lat = 5.0 / 111000
+		}
+		lon := float64(i) * 10 / 111000
+		trkpts += fmt.Sprintf(`<trkpt lat="%f" lon="%f"><time>2022-01-01T00:%02d:%02dZ</time><hdop>1.0</hdop></trkpt>`, lat, lon, i*10/60, i*10%60)
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, trkpts)
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := tracklog.Tracks[0].Segments[0].Points
+	if points[0].GetHdop() != 1 {
+		t.Fatalf("Unexpected HDOP: %f", points[0].GetHdop())
+	}
+	before := pointsLength(points)
+	smooth := &Smooth{ProcessNoise: 0.1, MeasurementNoise: 5}
+	n, err := smooth.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 60 {
+		t.Fatalf("Unexpected number of smoothed points: %d", n)
+	}
+	after := pointsLength(points)
+	if after >= before || math.Abs(after-590) > 10 {
+		t.Fatalf("Unexpected length: %f => %f", before, after)
+	}
+	for i, p := range points[5:55] {
+		if math.Abs(p.GetLatitude()-2.5/111000)*111000 > 1 {
+			t.Fatalf("Unexpected latitude of point[%d]: %f", i+5, p.GetLatitude())
+		}
+	}
+}
+
+func pointsLength(points []*gpx.Point) float64 {
+	length := 0.0
+	for _, l := range getLines(HaversinMode, points) {
+		length += l.dist
+	}
+	return length
+}
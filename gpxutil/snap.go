@@ -0,0 +1,261 @@
+package gpxutil
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// SnapToTrails map-matches track points onto the segments of the trails by a
+// hidden Markov model solved by Viterbi, after Newson and Krumm: candidates are
+// the projections of a point onto the trail lines within Threshold, which
+// are more likely the closer they are (Gaussian of Sigma meters), and
+// transitions are more likely the closer the distance along the trails is to
+// the distance between the points (exponential of Beta meters). Changing
+// trails costs Threshold on top of the distance between the candidates.
+//
+// Snapped points keep their time, and the trail points between them are added
+// with time interpolated, so that the tracks follow the trail geometry. Runs of
+// points without any candidate are kept as they are in separate segments.
+type SnapToTrails struct {
+	Trails     *gpx.TrackLog
+	Threshold  float64
+	Sigma      float64 // 10 if not positive
+	Beta       float64 // 10 if not positive
+	Candidates int     // max number of candidates of a point; 8 if not positive
+	trails     []*trail
+}
+
+type trail struct {
+	lines    []*line
+	mileages []float64 // mileage at the start of each line
+}
+
+// candidate is a projection of a point onto a trail line.
+type candidate struct {
+	trail, line int
+	point       *gpx.Point
+	mileage     float64
+	dist        float64
+}
+
+func (c *SnapToTrails) Name() string {
+	return fmt.Sprintf("Snap to Trails within %.0fm", c.Threshold)
+}
+
+// Run returns the number of snapped points.
+func (c *SnapToTrails) Run(tracklog *gpx.TrackLog) (int, error) {
+	if c.Threshold <= 0 {
+		return 0, fmt.Errorf("invalid threshold: %f", c.Threshold)
+	}
+	c.trails = make([]*trail, 0)
+	for _, t := range c.Trails.Tracks {
+		for _, seg := range t.Segments {
+			lines := getLines(HaversinMode, seg.Points)
+			if len(lines) <= 0 {
+				continue
+			}
+			tr := &trail{lines: lines, mileages: make([]float64, len(lines))}
+			mileage := 0.0
+			for i, l := range lines {
+				tr.mileages[i] = mileage
+				mileage += l.dist
+			}
+			c.trails = append(c.trails, tr)
+		}
+	}
+	if len(c.trails) <= 0 {
+		return 0, fmt.Errorf("no trail")
+	}
+	n := 0
+	for _, t := range tracklog.Tracks {
+		segments := make([]*gpx.Segment, 0, len(t.Segments))
+		for _, seg := range t.Segments {
+			snapped, m := c.snap(seg.Points)
+			segments = append(segments, snapped...)
+			n += m
+		}
+		t.Segments = segments
+	}
+	return n, nil
+}
+
+func (c *SnapToTrails) candidates(p *gpx.Point) []*candidate {
+	candidates := make([]*candidate, 0)
+	for i, tr := range c.trails {
+		for j, l := range tr.lines {
+			pp := l.closestPoint(p)
+			dist := HaversinDistance(p, pp)
+			if dist > c.Threshold {
+				continue
+			}
+			candidates = append(candidates, &candidate{
+				trail:   i,
+				line:    j,
+				point:   pp,
+				mileage: tr.mileages[j] + HaversinDistance(l.a, pp),
+				dist:    dist,
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+	max := c.Candidates
+	if max <= 0 {
+		max = 8
+	}
+	if len(candidates) > max {
+		candidates = candidates[:max]
+	}
+	return candidates
+}
+
+// snap returns the segments of the snapped runs and the runs off the trails,
+// and the number of snapped points.
+func (c *SnapToTrails) snap(points []*gpx.Point) ([]*gpx.Segment, int) {
+	segments := make([]*gpx.Segment, 0)
+	n := 0
+	var run []*gpx.Point
+	var runCandidates [][]*candidate
+	var off []*gpx.Point
+	flush := func() {
+		if len(run) > 0 {
+			segments = append(segments, &gpx.Segment{Points: c.match(run, runCandidates)})
+			n += len(run)
+			run, runCandidates = nil, nil
+		}
+		if len(off) > 0 {
+			segments = append(segments, &gpx.Segment{Points: off})
+			off = nil
+		}
+	}
+	for _, p := range points {
+		candidates := c.candidates(p)
+		if len(candidates) <= 0 {
+			if len(run) > 0 {
+				flush()
+			}
+			off = append(off, p)
+			continue
+		}
+		if len(off) > 0 {
+			flush()
+		}
+		run = append(run, p)
+		runCandidates = append(runCandidates, candidates)
+	}
+	flush()
+	return segments, n
+}
+
+// match finds the most likely candidates of the points by Viterbi, and returns
+// the snapped points with the trail points between them.
+func (c *SnapToTrails) match(points []*gpx.Point, candidates [][]*candidate) []*gpx.Point {
+	sigma := c.Sigma
+	if sigma <= 0 {
+		sigma = 10
+	}
+	beta := c.Beta
+	if beta <= 0 {
+		beta = 10
+	}
+	emission := func(cd *candidate) float64 {
+		return -0.5 * (cd.dist / sigma) * (cd.dist / sigma)
+	}
+	scores := make([][]float64, len(points))
+	from := make([][]int, len(points))
+	scores[0] = make([]float64, len(candidates[0]))
+	for k, cd := range candidates[0] {
+		scores[0][k] = emission(cd)
+	}
+	for t := 1; t < len(points); t++ {
+		dist := HaversinDistance(points[t-1], points[t])
+		scores[t] = make([]float64, len(candidates[t]))
+		from[t] = make([]int, len(candidates[t]))
+		for k, cd := range candidates[t] {
+			best := math.Inf(-1)
+			for j, prev := range candidates[t-1] {
+				s := scores[t-1][j] - math.Abs(c.routeDistance(prev, cd)-dist)/beta
+				if s > best {
+					best = s
+					from[t][k] = j
+				}
+			}
+			scores[t][k] = best + emission(cd)
+		}
+	}
+	best := 0
+	last := len(points) - 1
+	for k := range scores[last] {
+		if scores[last][k] > scores[last][best] {
+			best = k
+		}
+	}
+	path := make([]*candidate, len(points))
+	for t := last; t >= 0; t-- {
+		path[t] = candidates[t][best]
+		if t > 0 {
+			best = from[t][best]
+		}
+	}
+	res := make([]*gpx.Point, 0, len(points))
+	for t, cd := range path {
+		if t > 0 {
+			res = append(res, c.between(path[t-1], cd, points[t-1], points[t])...)
+		}
+		p := proto.Clone(cd.point).(*gpx.Point)
+		p.NanoTime = points[t].NanoTime
+		if p.Elevation == nil {
+			p.Elevation = points[t].Elevation
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+// routeDistance returns the distance along the trails between the candidates.
+func (c *SnapToTrails) routeDistance(a, b *candidate) float64 {
+	if a.trail == b.trail {
+		return math.Abs(b.mileage - a.mileage)
+	}
+	return HaversinDistance(a.point, b.point) + c.Threshold
+}
+
+// between returns the trail points strictly between the candidates on the same
+// trail, with time interpolated by mileage from the points.
+func (c *SnapToTrails) between(a, b *candidate, pa, pb *gpx.Point) []*gpx.Point {
+	if a.trail != b.trail || a.line == b.line {
+		return nil
+	}
+	tr := c.trails[a.trail]
+	res := make([]*gpx.Point, 0)
+	add := func(i int) {
+		if math.Abs(tr.mileages[i]-a.mileage) < 1e-6 || math.Abs(tr.mileages[i]-b.mileage) < 1e-6 {
+			return
+		}
+		p := proto.Clone(tr.lines[i].a).(*gpx.Point)
+		p.NanoTime = nil
+		if pa.NanoTime != nil && pb.NanoTime != nil && b.mileage != a.mileage {
+			ratio := (tr.mileages[i] - a.mileage) / (b.mileage - a.mileage)
+			dt := pb.Time().Sub(pa.Time())
+			p.NanoTime = proto.Int64(pa.Time().Add(time.Duration(float64(dt) * ratio)).UnixNano())
+		}
+		res = append(res, p)
+	}
+	if a.line < b.line {
+		for i := a.line + 1; i <= b.line; i++ {
+			add(i)
+		}
+	} else {
+		for i := a.line; i > b.line; i-- {
+			add(i)
+		}
+	}
+	return res
+}
@@ -0,0 +1,93 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestSnapToTrails(t *testing.T) {
+	// an L-shaped trail to the north and then to the east, and a parallel trail
+	// about 30m to the east of the northern part
+	main := ""
+	for i := 0; i <= 10; i++ {
+		main += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"><ele>100</ele></trkpt>`, 24.0+float64(i)*0.001)
+	}
+	for i := 1; i <= 10; i++ {
+		main += fmt.Sprintf(`<trkpt lat="24.01" lon="%f"><ele>200</ele></trkpt>`, 121.0+float64(i)*0.001)
+	}
+	parallel := `<trkpt lat="24.0" lon="121.0003"></trkpt><trkpt lat="24.01" lon="121.0003"></trkpt>`
+	trails, err := gpx.Parse(bytes.NewBuffer([]byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, main, parallel))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	recorded := [][2]float64{
+		{24.0005, 121.0001},
+		{24.0025, 121.0001},
+		{24.0045, 121.0002}, // closer to the parallel trail
+		{24.0065, 121.0001},
+		{24.0095, 121.0001},
+		{24.01005, 121.0015}, // around the corner
+		{24.02, 121.005},     // off trail
+		{24.01005, 121.0075},
+	}
+	points := ""
+	for i, r := range recorded {
+		points += fmt.Sprintf(`<trkpt lat="%f" lon="%f"><time>%s</time></trkpt>`, r[0], r[1], start.Add(time.Duration(i)*time.Minute).Format(time.RFC3339))
+	}
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, points))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := &SnapToTrails{Trails: trails, Threshold: 30}
+	n, err := snap.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 7 {
+		t.Fatalf("Unexpected number of snapped points: %d", n)
+	}
+	segments := tracklog.Tracks[0].Segments
+	if len(segments) != 3 {
+		t.Fatalf("Unexpected number of segments: %d", len(segments))
+	}
+	if len(segments[1].Points) != 1 || segments[1].Points[0].GetLatitude() != 24.02 {
+		t.Fatalf("Unexpected off-trail segment: %v", segments[1].Points)
+	}
+	snapped := segments[0].Points
+	corner := -1
+	for i, p := range snapped {
+		if p.NanoTime == nil {
+			t.Fatalf("Unexpected point without time: %v", p)
+		}
+		if i > 0 && p.Time().Before(snapped[i-1].Time()) {
+			t.Fatalf("Unexpected time order: %v", p.Time())
+		}
+		if p.GetLatitude() < 24.01-1e-9 && p.GetLongitude() != 121.0 {
+			t.Fatalf("Unexpected point off the main trail: %v", p)
+		}
+		if p.GetLatitude() == 24.01 && p.GetLongitude() == 121.0 {
+			corner = i
+		}
+	}
+	if corner < 0 {
+		t.Fatalf("Unexpected snapped points without the corner: %v", snapped)
+	}
+	if !snapped[0].Time().Equal(start) || !snapped[len(snapped)-1].Time().Equal(start.Add(5*time.Minute)) {
+		t.Fatalf("Unexpected time range: %v - %v", snapped[0].Time(), snapped[len(snapped)-1].Time())
+	}
+	if snapped[0].GetElevation() != 100 {
+		t.Fatalf("Unexpected elevation: %f", snapped[0].GetElevation())
+	}
+}
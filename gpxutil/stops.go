@@ -0,0 +1,153 @@
+package gpxutil
+
+import (
+	"fmt"
+	"time"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Stay is a period staying within a radius.
+type Stay struct {
+	Arrival, Departure *gpx.Point
+	Points             []*gpx.Point
+}
+
+func (s *Stay) Duration() time.Duration {
+	return s.Departure.Time().Sub(s.Arrival.Time())
+}
+
+// Centroid returns the point at the average position and elevation of the
+// points of the stay, at the arrival time.
+func (s *Stay) Centroid() *gpx.Point {
+	var lat, lon, ele float64
+	n := 0
+	for _, p := range s.Points {
+		lat += p.GetLatitude()
+		lon += p.GetLongitude()
+		if p.Elevation != nil {
+			ele += p.GetElevation()
+			n++
+		}
+	}
+	c := &gpx.Point{
+		Latitude:  proto.Float64(lat / float64(len(s.Points))),
+		Longitude: proto.Float64(lon / float64(len(s.Points))),
+		NanoTime:  s.Arrival.NanoTime,
+	}
+	if n > 0 {
+		c.Elevation = proto.Float64(ele / float64(n))
+	}
+	return c
+}
+
+// findStays returns the maximal runs of consecutive timestamped points, across
+// tracks and segments, staying within the radius (in meters) from the first
+// point of the run for at least the duration.
+func findStays(tracklog *gpx.TrackLog, radius float64, duration time.Duration) []*Stay {
+	points := make([]*gpx.Point, 0)
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			for _, p := range seg.Points {
+				if p.NanoTime != nil {
+					points = append(points, p)
+				}
+			}
+		}
+	}
+	return staysOf(points, radius, duration)
+}
+
+// staysOf returns the maximal runs of consecutive points staying within the
+// radius from the first point of the run for at least the duration, where the
+// first and last points of a run must have time.
+func staysOf(points []*gpx.Point, radius float64, duration time.Duration) []*Stay {
+	stays := make([]*Stay, 0)
+	for i := 0; i < len(points); {
+		j := i + 1
+		for j < len(points) && HaversinDistance(points[i], points[j]) <= radius {
+			j++
+		}
+		stay := &Stay{
+			Arrival:   points[i],
+			Departure: points[j-1],
+			Points:    points[i:j],
+		}
+		if j-1 > i && stay.Arrival.NanoTime != nil && stay.Departure.NanoTime != nil && stay.Duration() >= duration {
+			stays = append(stays, stay)
+			i = j
+			continue
+		}
+		i++
+	}
+	return stays
+}
+
+// DetectStops adds a waypoint at the centroid of each stay within Radius (in
+// meters) for at least Duration, described by the arrival, departure and
+// duration. Stays are found in each segment separately. If Collapse is true,
+// the points of each stay are replaced by the centroid.
+type DetectStops struct {
+	Radius   float64
+	Duration time.Duration
+	Collapse bool
+	Location *time.Location // time zone of descriptions; UTC if nil
+	Stops    []*Stay        // stops found by the last run
+}
+
+func (c *DetectStops) Name() string {
+	return fmt.Sprintf("Detect Stops within %.0fm for %v", c.Radius, c.Duration)
+}
+
+// Run returns the number of stops.
+func (c *DetectStops) Run(tracklog *gpx.TrackLog) (int, error) {
+	if c.Radius <= 0 {
+		return 0, fmt.Errorf("invalid radius: %f", c.Radius)
+	}
+	location := c.Location
+	if location == nil {
+		location = time.UTC
+	}
+	c.Stops = make([]*Stay, 0)
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			stays := staysOf(seg.Points, c.Radius, c.Duration)
+			if len(stays) <= 0 {
+				continue
+			}
+			points := make([]*gpx.Point, 0, len(seg.Points))
+			k := 0
+			for i := 0; i < len(seg.Points); i++ {
+				if k >= len(stays) || seg.Points[i] != stays[k].Arrival {
+					points = append(points, seg.Points[i])
+					continue
+				}
+				s := stays[k]
+				centroid := s.Centroid()
+				c.Stops = append(c.Stops, s)
+				tracklog.WayPoints = append(tracklog.WayPoints, &gpx.WayPoint{
+					Latitude:  centroid.Latitude,
+					Longitude: centroid.Longitude,
+					Elevation: centroid.Elevation,
+					NanoTime:  centroid.NanoTime,
+					Name:      proto.String(fmt.Sprintf("Stop %d", len(c.Stops))),
+					Description: proto.String(fmt.Sprintf("Arrival: %s, Departure: %s, Duration: %v",
+						s.Arrival.Time().In(location).Format("2006-01-02 15:04:05"),
+						s.Departure.Time().In(location).Format("2006-01-02 15:04:05"),
+						s.Duration().Round(time.Second))),
+				})
+				if !c.Collapse {
+					points = append(points, s.Points...)
+				} else {
+					points = append(points, centroid)
+				}
+				i += len(s.Points) - 1
+				k++
+			}
+			seg.Points = points
+		}
+	}
+	return len(c.Stops), nil
+}
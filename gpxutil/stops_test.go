@@ -0,0 +1,55 @@
+package gpxutil
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gpxtoolkit/gpx"
+)
+
+func TestDetectStops(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<trk>
+	<trkseg>
+	<trkpt lat="24.0000" lon="121.0"><time>2022-01-01T00:00:00Z</time></trkpt>
+	<trkpt lat="24.0010" lon="121.0"><time>2022-01-01T00:01:00Z</time></trkpt>
+	<trkpt lat="24.0020" lon="121.0"><ele>100</ele><time>2022-01-01T00:02:00Z</time></trkpt>
+	<trkpt lat="24.0021" lon="121.0"><ele>102</ele><time>2022-01-01T00:07:00Z</time></trkpt>
+	<trkpt lat="24.0019" lon="121.0"><ele>98</ele><time>2022-01-01T00:12:00Z</time></trkpt>
+	<trkpt lat="24.0020" lon="121.0"><ele>100</ele><time>2022-01-01T00:17:00Z</time></trkpt>
+	<trkpt lat="24.0030" lon="121.0"><time>2022-01-01T00:18:00Z</time></trkpt>
+	<trkpt lat="24.0040" lon="121.0"><time>2022-01-01T00:19:00Z</time></trkpt>
+	</trkseg>
+</trk>
+</gpx>`
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stops := &DetectStops{Radius: 30, Duration: 10 * time.Minute, Collapse: true}
+	n, err := stops.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(tracklog.WayPoints) != 1 {
+		t.Fatalf("Unexpected number of stops: %d", n)
+	}
+	if d := stops.Stops[0].Duration(); d != 15*time.Minute {
+		t.Fatalf("Unexpected stop duration: %v", d)
+	}
+	wpt := tracklog.WayPoints[0]
+	if wpt.GetName() != "Stop 1" || wpt.GetElevation() != 100 {
+		t.Fatalf("Unexpected waypoint: %v", wpt)
+	}
+	if lat := wpt.GetLatitude(); lat < 24.00199 || lat > 24.00201 {
+		t.Fatalf("Unexpected centroid: %f", lat)
+	}
+	if desc := wpt.GetDescription(); desc != "Arrival: 2022-01-01 00:02:00, Departure: 2022-01-01 00:17:00, Duration: 15m0s" {
+		t.Fatalf("Unexpected description: %s", desc)
+	}
+	if num := len(tracklog.Tracks[0].Segments[0].Points); num != 5 {
+		t.Fatalf("Unexpected number of points after collapsing: %d", num)
+	}
+}
@@ -0,0 +1,264 @@
+package gpxutil
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gpxtoolkit/gpx"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// TimeWindow is a named time range [From, To), where a zero From or To is
+// unbounded.
+type TimeWindow struct {
+	Name     string
+	From, To time.Time
+}
+
+func (w *TimeWindow) contains(t time.Time) bool {
+	if !w.From.IsZero() && t.Before(w.From) {
+		return false
+	}
+	if !w.To.IsZero() && !t.Before(w.To) {
+		return false
+	}
+	return true
+}
+
+// Crop returns a new track log of the points within the window, interpolating
+// the boundary points on lines crossing the window. Points without time are
+// dropped. Waypoints with time are kept if within the window, and those
+// without time are kept if within the threshold (in meters) from the cropped
+// tracks.
+func (w *TimeWindow) Crop(tracklog *gpx.TrackLog, threshold float64) *gpx.TrackLog {
+	res := &gpx.TrackLog{
+		Creator:  tracklog.Creator,
+		Name:     tracklog.Name,
+		NanoTime: tracklog.NanoTime,
+		Link:     tracklog.Link,
+	}
+	for _, t := range tracklog.Tracks {
+		track := &gpx.Track{
+			Name:    t.Name,
+			Type:    t.Type,
+			Comment: t.Comment,
+		}
+		for _, seg := range t.Segments {
+			points := w.crop(seg.Points)
+			if len(points) > 0 {
+				track.Segments = append(track.Segments, &gpx.Segment{Points: points})
+			}
+		}
+		if len(track.Segments) > 0 {
+			res.Tracks = append(res.Tracks, track)
+		}
+	}
+	res.WayPoints = w.waypoints(tracklog.WayPoints, res.Tracks, threshold)
+	return res
+}
+
+func (w *TimeWindow) crop(points []*gpx.Point) []*gpx.Point {
+	res := make([]*gpx.Point, 0)
+	var prev *gpx.Point
+	for _, p := range points {
+		if p.NanoTime == nil {
+			continue
+		}
+		if prev != nil {
+			// boundaries crossed by the line from the previous point, where
+			// the point at To is outside [From, To) but closes the track
+			for _, b := range []time.Time{w.From, w.To} {
+				if b.IsZero() || !prev.Time().Before(b) || p.Time().Before(b) {
+					continue
+				}
+				if !p.Time().Equal(b) {
+					res = append(res, interpolateAt(prev, p, b))
+				} else if !w.contains(b) {
+					res = append(res, p)
+				}
+			}
+		}
+		if w.contains(p.Time()) {
+			res = append(res, p)
+		}
+		prev = p
+	}
+	return res
+}
+
+func (w *TimeWindow) waypoints(waypoints []*gpx.WayPoint, tracks []*gpx.Track, threshold float64) []*gpx.WayPoint {
+	res := make([]*gpx.WayPoint, 0)
+	for _, wpt := range waypoints {
+		if wpt.NanoTime != nil {
+			if w.contains(wpt.Time()) {
+				res = append(res, wpt)
+			}
+			continue
+		}
+		for _, t := range tracks {
+			found := false
+			for _, seg := range t.Segments {
+				lines := getLines(HaversinMode, seg.Points)
+				if len(lines) <= 0 {
+					continue
+				}
+				if len(projectWaypoints(HaversinMode, lines, []*gpx.WayPoint{wpt}, threshold)) > 0 {
+					found = true
+					break
+				}
+			}
+			if found {
+				res = append(res, wpt)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// DayWindows returns a window for each calendar day in the location, on which
+// any track point was recorded, named by the date.
+func DayWindows(tracklog *gpx.TrackLog, location *time.Location) []*TimeWindow {
+	days := make(map[time.Time]bool)
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			for _, p := range seg.Points {
+				if p.NanoTime == nil {
+					continue
+				}
+				tm := p.Time().In(location)
+				days[time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, location)] = true
+			}
+		}
+	}
+	windows := make([]*TimeWindow, 0, len(days))
+	for day := range days {
+		windows = append(windows, &TimeWindow{
+			Name: day.Format("2006-01-02"),
+			From: day,
+			To:   day.AddDate(0, 0, 1),
+		})
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].From.Before(windows[j].From)
+	})
+	return windows
+}
+
+// OvernightWindows splits the track log at the middle of its longest stays,
+// up to the number of nights, which last at least the min duration within the
+// radius (in meters). Windows are named by the day number and the local date
+// of their first points.
+func OvernightWindows(tracklog *gpx.TrackLog, nights int, radius float64, duration time.Duration, location *time.Location) []*TimeWindow {
+	stays := findStays(tracklog, radius, duration)
+	sort.SliceStable(stays, func(i, j int) bool {
+		return stays[i].Duration() > stays[j].Duration()
+	})
+	if len(stays) > nights {
+		stays = stays[:nights]
+	}
+	sort.Slice(stays, func(i, j int) bool {
+		return stays[i].Arrival.Time().Before(stays[j].Arrival.Time())
+	})
+	start, _ := timeRange(tracklog)
+	windows := make([]*TimeWindow, 0, len(stays)+1)
+	w := &TimeWindow{}
+	for _, s := range stays {
+		w.Name = fmt.Sprintf("Day %d: %s", len(windows)+1, start.In(location).Format("2006-01-02"))
+		w.To = s.Arrival.Time().Add(s.Duration() / 2)
+		windows = append(windows, w)
+		w = &TimeWindow{From: w.To}
+		start = s.Departure.Time()
+	}
+	w.Name = fmt.Sprintf("Day %d: %s", len(windows)+1, start.In(location).Format("2006-01-02"))
+	return append(windows, w)
+}
+
+// timeRange returns the time of the first and last timestamped track points.
+func timeRange(tracklog *gpx.TrackLog) (time.Time, time.Time) {
+	var first, last time.Time
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			for _, p := range seg.Points {
+				if p.NanoTime == nil {
+					continue
+				}
+				tm := p.Time()
+				if first.IsZero() || tm.Before(first) {
+					first = tm
+				}
+				if last.IsZero() || tm.After(last) {
+					last = tm
+				}
+			}
+		}
+	}
+	return first, last
+}
+
+// SplitByTime replaces the tracks by one track for each window, named by the
+// window and made of the segments cropped to the window, and keeps only the
+// waypoints within any window.
+type SplitByTime struct {
+	Windows   []*TimeWindow
+	Threshold float64 // distance threshold of waypoints without time
+}
+
+func (c *SplitByTime) Name() string {
+	return fmt.Sprintf("Split by %d Time Windows", len(c.Windows))
+}
+
+// Run returns the number of tracks.
+func (c *SplitByTime) Run(tracklog *gpx.TrackLog) (int, error) {
+	tracks := make([]*gpx.Track, 0, len(c.Windows))
+	waypoints := make([]*gpx.WayPoint, 0)
+	kept := make(map[*gpx.WayPoint]bool)
+	for _, w := range c.Windows {
+		cropped := w.Crop(tracklog, c.Threshold)
+		track := &gpx.Track{
+			Name: proto.String(w.Name),
+		}
+		for _, t := range cropped.Tracks {
+			track.Segments = append(track.Segments, t.Segments...)
+		}
+		if len(track.Segments) <= 0 {
+			continue
+		}
+		tracks = append(tracks, track)
+		for _, wpt := range cropped.WayPoints {
+			if !kept[wpt] {
+				kept[wpt] = true
+				waypoints = append(waypoints, wpt)
+			}
+		}
+	}
+	tracklog.Tracks = tracks
+	tracklog.WayPoints = waypoints
+	return len(tracks), nil
+}
+
+// CropByTime crops the track log to the window; see TimeWindow.Crop.
+type CropByTime struct {
+	Window    *TimeWindow
+	Threshold float64 // distance threshold of waypoints without time
+}
+
+func (c *CropByTime) Name() string {
+	return fmt.Sprintf("Crop by Time from %v to %v", c.Window.From, c.Window.To)
+}
+
+// Run returns the number of points after cropping.
+func (c *CropByTime) Run(tracklog *gpx.TrackLog) (int, error) {
+	cropped := c.Window.Crop(tracklog, c.Threshold)
+	tracklog.Tracks = cropped.Tracks
+	tracklog.WayPoints = cropped.WayPoints
+	n := 0
+	for _, t := range tracklog.Tracks {
+		for _, seg := range t.Segments {
+			n += len(seg.Points)
+		}
+	}
+	return n, nil
+}
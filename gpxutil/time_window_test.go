@@ -0,0 +1,111 @@
+package gpxutil
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"gpxtoolkit/gpx"
+)
+
+// twoDayTrackLog walks north on 2022-01-01 08:00-10:00 and 2022-01-02
+// 08:00-10:00 in Asia/Taipei, staying overnight at the same place in between.
+func twoDayTrackLog(t *testing.T) *gpx.TrackLog {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := ""
+	lat := 24.0
+	for i := 0; i <= 12; i++ {
+		points += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"><time>%s</time></trkpt>`, lat, start.Add(time.Duration(i)*10*time.Minute).Format(time.RFC3339))
+		lat += 0.001
+	}
+	lat -= 0.001
+	for i := 1; i < 22; i++ {
+		points += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"><time>%s</time></trkpt>`, lat, start.Add(2*time.Hour+time.Duration(i)*time.Hour).Format(time.RFC3339))
+	}
+	for i := 0; i <= 12; i++ {
+		lat += 0.001
+		points += fmt.Sprintf(`<trkpt lat="%f" lon="121.0"><time>%s</time></trkpt>`, lat, start.Add(24*time.Hour+time.Duration(i)*10*time.Minute).Format(time.RFC3339))
+	}
+	xml := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8" standalone="no" ?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" creator="foobar" version="1.1">
+<wpt lat="24.005" lon="121.0"><name>Timed</name><time>2022-01-01T00:50:00Z</time></wpt>
+<wpt lat="24.020" lon="121.0"><name>Untimed</name></wpt>
+<wpt lat="25.000" lon="121.0"><name>Faraway</name></wpt>
+<trk><trkseg>%s</trkseg></trk>
+</gpx>`, points)
+	tracklog, err := gpx.Parse(bytes.NewBuffer([]byte(xml)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tracklog
+}
+
+func TestCropByTime(t *testing.T) {
+	tracklog := twoDayTrackLog(t)
+	from := time.Date(2022, 1, 1, 0, 5, 0, 0, time.UTC)
+	to := time.Date(2022, 1, 1, 0, 55, 0, 0, time.UTC)
+	crop := &CropByTime{Window: &TimeWindow{From: from, To: to}, Threshold: 30}
+	n, err := crop.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 00:05, 00:10 ... 00:50, 00:55
+	if n != 7 {
+		t.Fatalf("Unexpected number of points: %d", n)
+	}
+	points := tracklog.Tracks[0].Segments[0].Points
+	first, last := points[0], points[len(points)-1]
+	if !first.Time().Equal(from) || !last.Time().Equal(to) {
+		t.Fatalf("Unexpected boundary time: %v - %v", first.Time(), last.Time())
+	}
+	if d := first.GetLatitude() - 24.0005; d > 1e-9 || d < -1e-9 {
+		t.Fatalf("Unexpected boundary latitude: %f", first.GetLatitude())
+	}
+	if len(tracklog.WayPoints) != 1 || tracklog.WayPoints[0].GetName() != "Timed" {
+		t.Fatalf("Unexpected waypoints: %v", tracklog.WayPoints)
+	}
+}
+
+func TestSplitByTime(t *testing.T) {
+	taipei, err := time.LoadLocation("Asia/Taipei")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracklog := twoDayTrackLog(t)
+	windows := DayWindows(tracklog, taipei)
+	if len(windows) != 2 || windows[0].Name != "2022-01-01" || windows[1].Name != "2022-01-02" {
+		t.Fatalf("Unexpected day windows: %v", windows)
+	}
+	split := &SplitByTime{Windows: windows, Threshold: 30}
+	n, err := split.Run(twoDayTrackLog(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("Unexpected number of tracks: %d", n)
+	}
+
+	windows = OvernightWindows(tracklog, 1, 50, 4*time.Hour, taipei)
+	if len(windows) != 2 {
+		t.Fatalf("Unexpected number of overnight windows: %d", len(windows))
+	}
+	if windows[0].Name != "Day 1: 2022-01-01" || windows[1].Name != "Day 2: 2022-01-02" {
+		t.Fatalf("Unexpected overnight windows: %s, %s", windows[0].Name, windows[1].Name)
+	}
+	// the middle of the stay from 02:00 to 23:00
+	if want := time.Date(2022, 1, 1, 12, 30, 0, 0, time.UTC); !windows[0].To.Equal(want) {
+		t.Fatalf("Unexpected split time: %v", windows[0].To)
+	}
+	split = &SplitByTime{Windows: windows, Threshold: 30}
+	n, err = split.Run(tracklog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || tracklog.Tracks[1].GetName() != "Day 2: 2022-01-02" {
+		t.Fatalf("Unexpected tracks: %d", n)
+	}
+	if len(tracklog.WayPoints) != 2 {
+		t.Fatalf("Unexpected number of waypoints: %d", len(tracklog.WayPoints))
+	}
+}
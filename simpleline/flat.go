@@ -0,0 +1,163 @@
+package simpleline
+
+import (
+	"sync"
+)
+
+// flatParallelSpan is the min number of points of a span to be divided in
+// parallel, below which goroutines cost more than they save.
+const flatParallelSpan = 1 << 14
+
+// RDPFlat is RDPIndex with the Euclidean metric over flat coordinates, i.e.
+// x0, y0, x1, y1, ... for 2 dimensions, which computes squared distances
+// without allocation. Up to parallel goroutines divide spans of many points if
+// parallel is greater than 1.
+func RDPFlat(coords []float64, dims int, epsilon float64, simplifyFirst bool, parallel int) []int {
+	n := len(coords) / dims
+	if n <= 2 {
+		return allIndices(n)
+	}
+	var radial []int
+	if simplifyFirst {
+		radial = radialFlat(coords, dims, epsilon)
+		gathered := make([]float64, len(radial)*dims)
+		for i, index := range radial {
+			copy(gathered[i*dims:(i+1)*dims], coords[index*dims:(index+1)*dims])
+		}
+		coords = gathered
+		n = len(radial)
+	}
+	f := &flatRDP{
+		coords:   coords,
+		dims:     dims,
+		epsilon2: epsilon * epsilon,
+		markers:  make([]bool, n),
+	}
+	f.markers[0], f.markers[n-1] = true, true
+	if parallel > 1 {
+		var wg sync.WaitGroup
+		f.divide(0, n-1, make(chan struct{}, parallel-1), &wg)
+		wg.Wait()
+	} else {
+		f.simplify(0, n-1)
+	}
+	results := []int{}
+	for i, include := range f.markers {
+		if !include {
+			continue
+		}
+		if radial != nil {
+			results = append(results, radial[i])
+		} else {
+			results = append(results, i)
+		}
+	}
+	return results
+}
+
+type flatRDP struct {
+	coords   []float64
+	dims     int
+	epsilon2 float64
+	markers  []bool // distinct elements are written by goroutines
+}
+
+// divide simplifies the span, dividing it in new goroutines as long as the
+// semaphore allows and the span is long enough.
+func (f *flatRDP) divide(first, last int, sem chan struct{}, wg *sync.WaitGroup) {
+	for last-first >= flatParallelSpan {
+		index, dist := f.farthest(first, last)
+		if dist <= f.epsilon2 {
+			return
+		}
+		f.markers[index] = true
+		// continue with the longer half, so that the recursion is shallow
+		a, b := first, index
+		if index-first > last-index {
+			first = index
+		} else {
+			a, b = index, last
+			last = index
+		}
+		select {
+		case sem <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				f.divide(a, b, sem, wg)
+				<-sem
+			}()
+		default:
+			f.divide(a, b, sem, wg)
+		}
+	}
+	f.simplify(first, last)
+}
+
+func (f *flatRDP) simplify(first, last int) {
+	var buf [64]int
+	stack := append(buf[:0], first, last)
+	for len(stack) > 0 {
+		first, last = stack[len(stack)-2], stack[len(stack)-1]
+		stack = stack[:len(stack)-2]
+		index, dist := f.farthest(first, last)
+		if dist > f.epsilon2 {
+			f.markers[index] = true
+			stack = append(stack, first, index, index, last)
+		}
+	}
+}
+
+// farthest returns the index of the point farthest from the line through the
+// first and last points, and its squared distance.
+func (f *flatRDP) farthest(first, last int) (int, float64) {
+	d := f.dims
+	a := f.coords[first*d : first*d+d]
+	b := f.coords[last*d : last*d+d]
+	ab2 := 0.0
+	for k := 0; k < d; k++ {
+		v := b[k] - a[k]
+		ab2 += v * v
+	}
+	index, maxDist := -1, 0.0
+	for i := first + 1; i < last; i++ {
+		p := f.coords[i*d : i*d+d]
+		ap2, dot := 0.0, 0.0
+		for k := 0; k < d; k++ {
+			v := p[k] - a[k]
+			ap2 += v * v
+			dot += v * (b[k] - a[k])
+		}
+		dist := ap2
+		if ab2 > 0 {
+			dist -= dot * dot / ab2
+		}
+		if dist > maxDist {
+			index, maxDist = i, dist
+		}
+	}
+	return index, maxDist
+}
+
+// radialFlat is simplifyRadialDist over flat coordinates.
+func radialFlat(coords []float64, dims int, epsilon float64) []int {
+	n := len(coords) / dims
+	epsilon2 := epsilon * epsilon
+	prev := 0
+	results := []int{prev}
+	for i := 1; i < n; i++ {
+		dist := 0.0
+		for k := 0; k < dims; k++ {
+			v := coords[i*dims+k] - coords[prev*dims+k]
+			dist += v * v
+		}
+		if dist > epsilon2 {
+			results = append(results, i)
+			prev = i
+		}
+	}
+	if last := n - 1; last > 0 && prev != last {
+		results = append(results, last)
+	}
+	return results
+}
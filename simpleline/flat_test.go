@@ -0,0 +1,98 @@
+package simpleline
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// randomWalk returns a 2D random walk of n points as Point3d and flat
+// coordinates.
+func randomWalk(n int) ([]Point, []float64) {
+	rng := rand.New(rand.NewSource(1))
+	points := make([]Point, n)
+	coords := make([]float64, 0, n*2)
+	x, y, heading := 0.0, 0.0, 0.0
+	for i := range points {
+		heading += rng.NormFloat64() * 0.3
+		x += math.Cos(heading) * 5
+		y += math.Sin(heading) * 5
+		points[i] = &Point3d{X: x, Y: y}
+		coords = append(coords, x, y)
+	}
+	return points, coords
+}
+
+func flatten(points []Point) []float64 {
+	coords := make([]float64, 0, len(points)*2)
+	for _, p := range points {
+		coords = append(coords, p.Vector()[0], p.Vector()[1])
+	}
+	return coords
+}
+
+func equalIndices(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRDPFlat(t *testing.T) {
+	expected, err := RDPIndex(testPoints, 5, Euclidean, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indices := RDPFlat(flatten(testPoints), 2, 5, true, 1)
+	if !equalIndices(indices, expected) {
+		t.Fatalf("Unexpected indices: %v", indices)
+	}
+	if len(indices) != len(testPointsSimplified) {
+		t.Fatalf("Unexpected number of points: %d", len(indices))
+	}
+}
+
+func TestRDPFlatParallel(t *testing.T) {
+	points, coords := randomWalk(100000)
+	expected, err := RDPIndex(points, 10, Euclidean, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, parallel := range []int{1, 4} {
+		indices := RDPFlat(coords, 2, 10, false, parallel)
+		if !equalIndices(indices, expected) {
+			t.Fatalf("Unexpected indices by %d goroutines: %d of %d", parallel, len(indices), len(expected))
+		}
+	}
+}
+
+func BenchmarkRDP(b *testing.B) {
+	points, _ := randomWalk(200000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := RDPIndex(points, 10, Euclidean, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRDPFlat(b *testing.B) {
+	_, coords := randomWalk(200000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		RDPFlat(coords, 2, 10, false, 1)
+	}
+}
+
+func BenchmarkRDPFlatParallel(b *testing.B) {
+	_, coords := randomWalk(200000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		RDPFlat(coords, 2, 10, false, 8)
+	}
+}
@@ -3,27 +3,41 @@
 package simpleline
 
 import (
-	"errors"
+	"container/heap"
 	"math"
 )
 
-func RDP(points []Point, epsilon float64, d Metric, simplifyFirst bool) (results []Point, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if str, ok := r.(string); ok {
-				err = errors.New(str)
-			}
-			err = r.(error)
-		}
-	}()
+func RDP(points []Point, epsilon float64, d Metric, simplifyFirst bool) ([]Point, error) {
+	indices, err := RDPIndex(points, epsilon, d, simplifyFirst)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Point, len(indices))
+	for i, index := range indices {
+		results[i] = points[index]
+	}
+	return results, nil
+}
+
+// RDPIndex is RDP returning the indices of the kept points, in order, so that
+// callers can keep their original data of the points.
+func RDPIndex(points []Point, epsilon float64, d Metric, simplifyFirst bool) (results []int, err error) {
+	defer recoverError(&err)
 
 	if len(points) <= 1 {
-		return points, nil
+		return allIndices(len(points)), nil
 	}
 
 	if simplifyFirst {
-		simplifiedPoints := simplifyRadialDist(points, epsilon, d)
+		radial := simplifyRadialDist(points, epsilon, d)
+		simplifiedPoints := make([]Point, len(radial))
+		for i, index := range radial {
+			simplifiedPoints[i] = points[index]
+		}
 		results = rdp(simplifiedPoints, epsilon, d)
+		for i, index := range results {
+			results[i] = radial[index]
+		}
 	} else {
 		results = rdp(points, epsilon, d)
 	}
@@ -31,9 +45,9 @@ func RDP(points []Point, epsilon float64, d Metric, simplifyFirst bool) (results
 	return
 }
 
-func rdp(points []Point, epsilon float64, d Metric) []Point {
+func rdp(points []Point, epsilon float64, d Metric) []int {
 	if len(points) <= 1 {
-		return points
+		return make([]int, len(points))
 	}
 
 	stack := []int{}
@@ -72,17 +86,96 @@ func rdp(points []Point, epsilon float64, d Metric) []Point {
 
 	}
 
-	results := []Point{}
+	results := []int{}
 
 	for i, include := range markers {
 		if include {
-			results = append(results, points[i])
+			results = append(results, i)
 		}
 	}
 
 	return results
 }
 
+// RDPCountIndex simplifies the line to n points, or all the points if there
+// are fewer, by ranked RDP: starting from the first and last points, the point
+// farthest from the simplified line is added one by one.
+func RDPCountIndex(points []Point, n int, d Metric) (results []int, err error) {
+	defer recoverError(&err)
+
+	if len(points) <= 2 || n >= len(points) {
+		return allIndices(len(points)), nil
+	}
+
+	markers := make([]bool, len(points))
+	markers[0], markers[len(points)-1] = true, true
+	kept := 2
+	h := &spanHeap{}
+	if s := farthest(points, 0, len(points)-1, d); s != nil {
+		heap.Push(h, s)
+	}
+	for kept < n && h.Len() > 0 {
+		s := heap.Pop(h).(*span)
+		markers[s.index] = true
+		kept++
+		for _, ss := range []*span{farthest(points, s.first, s.index, d), farthest(points, s.index, s.last, d)} {
+			if ss != nil {
+				heap.Push(h, ss)
+			}
+		}
+	}
+
+	results = make([]int, 0, kept)
+	for i, include := range markers {
+		if include {
+			results = append(results, i)
+		}
+	}
+	return results, nil
+}
+
+// span is a part of the line with its point farthest from the segment.
+type span struct {
+	first, last int
+	index       int
+	dist        float64
+}
+
+// farthest returns the span of the point farthest from the segment, or nil if
+// there is no point in between.
+func farthest(points []Point, first, last int, d Metric) *span {
+	if last-first <= 1 {
+		return nil
+	}
+	s := &span{first: first, last: last, index: first + 1, dist: -1}
+	for i := first + 1; i < last; i++ {
+		dist := shortestDistanceToSegment(points[first], points[last], points[i], d)
+		if dist > s.dist {
+			s.index = i
+			s.dist = dist
+		}
+	}
+	return s
+}
+
+// spanHeap is a max heap of spans by distance.
+type spanHeap []*span
+
+func (h spanHeap) Len() int           { return len(h) }
+func (h spanHeap) Less(i, j int) bool { return h[i].dist > h[j].dist }
+func (h spanHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *spanHeap) Push(x interface{}) {
+	*h = append(*h, x.(*span))
+}
+
+func (h *spanHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
 func shortestDistanceToSegment(a1, a2 Point, point Point, d Metric) float64 {
 	slope := a1.Subtract(a2)
 
@@ -108,26 +201,22 @@ func shortestDistanceToSegment(a1, a2 Point, point Point, d Metric) float64 {
 	return veryShortest
 }
 
-// basic distance-based simplification
-func simplifyRadialDist(points []Point, epsilon float64, d Metric) []Point {
+// basic distance-based simplification, returning the indices of kept points
+func simplifyRadialDist(points []Point, epsilon float64, d Metric) []int {
 
-	prevPoint := points[0]
+	prev := 0
 
-	newPoints := []Point{prevPoint}
-
-	var point Point
+	newPoints := []int{prev}
 
 	for i := 1; i < len(points); i++ {
-		point = points[i]
-
-		if d(point, prevPoint) > epsilon {
-			newPoints = append(newPoints, point)
-			prevPoint = point
+		if d(points[i], points[prev]) > epsilon {
+			newPoints = append(newPoints, i)
+			prev = i
 		}
 	}
 
-	if point != nil && prevPoint != point {
-		newPoints = append(newPoints, point)
+	if last := len(points) - 1; last > 0 && prev != last {
+		newPoints = append(newPoints, last)
 	}
 
 	return newPoints
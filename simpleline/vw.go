@@ -0,0 +1,148 @@
+package simpleline
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+)
+
+// VWIndex simplifies the line by the Visvalingam–Whyatt algorithm, which
+// repeatedly removes the point of the smallest effective area, i.e. the area of
+// the triangle with its neighbors, until every area is at least minArea. It
+// returns the indices of the kept points in order.
+func VWIndex(points []Point, minArea float64, d Metric) (results []int, err error) {
+	defer recoverError(&err)
+	return vw(points, d, func(area float64, remaining int) bool {
+		return area >= minArea
+	}), nil
+}
+
+// VWCountIndex simplifies the line by the Visvalingam–Whyatt algorithm to n
+// points, or all the points if there are fewer. The first and last points are
+// always kept.
+func VWCountIndex(points []Point, n int, d Metric) (results []int, err error) {
+	defer recoverError(&err)
+	return vw(points, d, func(area float64, remaining int) bool {
+		return remaining <= n
+	}), nil
+}
+
+// vw removes points in the order of effective area until stop returns true for
+// the smallest area and the number of remaining points.
+func vw(points []Point, d Metric, stop func(area float64, remaining int) bool) []int {
+	if len(points) <= 2 {
+		return allIndices(len(points))
+	}
+	prev := make([]int, len(points))
+	next := make([]int, len(points))
+	h := &areaHeap{
+		areas: make([]float64, len(points)),
+		pos:   make([]int, len(points)),
+	}
+	for i := range points {
+		prev[i], next[i] = i-1, i+1
+		h.pos[i] = -1
+	}
+	for i := 1; i < len(points)-1; i++ {
+		h.areas[i] = triangleArea(points[i-1], points[i], points[i+1], d)
+		h.pos[i] = len(h.items)
+		h.items = append(h.items, i)
+	}
+	heap.Init(h)
+	removed := make([]bool, len(points))
+	remaining := len(points)
+	last := 0.0
+	for h.Len() > 0 {
+		i := h.items[0]
+		// the effective area never decreases, so that a point is not removed
+		// before the points whose removal made it look less significant
+		area := math.Max(h.areas[i], last)
+		if stop(area, remaining) {
+			break
+		}
+		heap.Pop(h)
+		last = area
+		removed[i] = true
+		remaining--
+		p, n := prev[i], next[i]
+		next[p], prev[n] = n, p
+		for _, j := range []int{p, n} {
+			if h.pos[j] < 0 {
+				continue
+			}
+			h.areas[j] = triangleArea(points[prev[j]], points[j], points[next[j]], d)
+			heap.Fix(h, h.pos[j])
+		}
+	}
+	results := make([]int, 0, remaining)
+	for i, r := range removed {
+		if !r {
+			results = append(results, i)
+		}
+	}
+	return results
+}
+
+// triangleArea returns the area of the triangle by Heron's formula, so that it
+// works with any metric.
+func triangleArea(a, b, c Point, d Metric) float64 {
+	ab, bc, ca := d(a, b), d(b, c), d(c, a)
+	s := (ab + bc + ca) / 2
+	return math.Sqrt(math.Max(0, s*(s-ab)*(s-bc)*(s-ca)))
+}
+
+// areaHeap is a min heap of point indices by area, which tracks the positions
+// of the indices for updates.
+type areaHeap struct {
+	items []int
+	areas []float64
+	pos   []int
+}
+
+func (h *areaHeap) Len() int { return len(h.items) }
+
+func (h *areaHeap) Less(i, j int) bool {
+	a, b := h.areas[h.items[i]], h.areas[h.items[j]]
+	if a == b {
+		return h.items[i] < h.items[j]
+	}
+	return a < b
+}
+
+func (h *areaHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.pos[h.items[i]] = i
+	h.pos[h.items[j]] = j
+}
+
+func (h *areaHeap) Push(x interface{}) {
+	h.pos[x.(int)] = len(h.items)
+	h.items = append(h.items, x.(int))
+}
+
+func (h *areaHeap) Pop() interface{} {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	h.pos[x] = -1
+	return x
+}
+
+func allIndices(n int) []int {
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// recoverError recovers a panic, e.g. of vectors of different lengths, as the
+// error.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		if str, ok := r.(string); ok {
+			*err = errors.New(str)
+		} else {
+			*err = r.(error)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package simpleline
+
+import (
+	"testing"
+)
+
+func TestVWCollinear(t *testing.T) {
+	points := []Point{&Point3d{X: 0, Y: 0}, &Point3d{X: 1, Y: 0}, &Point3d{X: 2, Y: 0}, &Point3d{X: 3, Y: 1}, &Point3d{X: 4, Y: 0}}
+	indices, err := VWIndex(points, 0.1, Euclidean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indices) != 4 || indices[0] != 0 || indices[1] != 2 || indices[2] != 3 || indices[3] != 4 {
+		t.Fatalf("Unexpected indices: %v", indices)
+	}
+}
+
+func TestVWCount(t *testing.T) {
+	for _, n := range []int{2, 10, 50, len(testPoints), len(testPoints) + 1} {
+		indices, err := VWCountIndex(testPoints, n, Euclidean)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(indices) != min(n, len(testPoints)) {
+			t.Fatalf("Unexpected number of points for %d: %d", n, len(indices))
+		}
+		if indices[0] != 0 || indices[len(indices)-1] != len(testPoints)-1 {
+			t.Fatalf("Unexpected end points for %d: %v", n, indices)
+		}
+		for i := 1; i < len(indices); i++ {
+			if indices[i] <= indices[i-1] {
+				t.Fatalf("Unexpected order for %d: %v", n, indices)
+			}
+		}
+	}
+}
+
+func TestRDPCount(t *testing.T) {
+	indices, err := RDPCountIndex(testPoints, 3, Euclidean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the point farthest from the line between the end points
+	first, last := testPoints[0], testPoints[len(testPoints)-1]
+	farthest, dist := 0, 0.0
+	for i, p := range testPoints {
+		if d := shortestDistanceToSegment(first, last, p, Euclidean); d > dist {
+			farthest, dist = i, d
+		}
+	}
+	if len(indices) != 3 || indices[1] != farthest {
+		t.Fatalf("Unexpected indices: %v", indices)
+	}
+	for _, n := range []int{10, 33, 60} {
+		indices, err := RDPCountIndex(testPoints, n, Euclidean)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(indices) != n || indices[0] != 0 || indices[n-1] != len(testPoints)-1 {
+			t.Fatalf("Unexpected indices for %d: %v", n, indices)
+		}
+	}
+}
@@ -644,3 +644,24 @@ func TestParser_HookSystemIssue(t *testing.T) {
 	// This test now demonstrates that the specific hook system works correctly
 	t.Logf("Hooks structure: %+v", p.hooks)
 }
+
+func TestParser_Space(t *testing.T) {
+	p := NewParser()
+	spaces := make(map[string]string)
+	p.OnAny(nil, func(text string) error {
+		if text != "" {
+			spaces[text] = p.Space()
+		}
+		return nil
+	}, nil)
+	err := p.Parse(strings.NewReader(`<a xmlns="urn:a" xmlns:b="urn:b"><x>1</x><b:x>2</b:x><y xmlns="">3</y></a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spaces["1"] != "urn:a" || spaces["2"] != "urn:b" || spaces["3"] != "" {
+		t.Errorf("Unexpected namespaces: %v", spaces)
+	}
+	if p.Space() != "" {
+		t.Errorf("Expected no namespace after parsing, got '%s'", p.Space())
+	}
+}
@@ -26,8 +26,9 @@ type xmlHook struct {
 
 type Parser struct {
 	Stack
-	hooks map[string]map[string]*xmlHook
-	any   *xmlHook
+	hooks  map[string]map[string]*xmlHook
+	any    *xmlHook
+	spaces []string // namespaces of the elements in the stack
 }
 
 func (s *Parser) OnAny(enter xmlEnterCallback, text xmlTextCallback, leave xmlLeaveCallback) *Parser {
@@ -105,6 +106,7 @@ func (s *Parser) Parse(r io.Reader) error {
 			for _, a := range e.Attr {
 				attrs[a.Name.Local] = a.Value
 			}
+			s.spaces = append(s.spaces, e.Name.Space)
 			err := s.push(e.Name.Local, attrs)
 			if err != nil {
 				return err
@@ -115,6 +117,7 @@ func (s *Parser) Parse(r io.Reader) error {
 			if err != nil {
 				return err
 			}
+			s.spaces = s.spaces[:len(s.spaces)-1]
 		case xml.CharData:
 			text := strings.Trim(string(e), " \r\n\t")
 			err := s.text(text)
@@ -196,6 +199,15 @@ func (s *Parser) hook() *xmlHook {
 	return nil
 }
 
+// Space returns the namespace URI of the current element, or empty if it has
+// none.
+func (s *Parser) Space() string {
+	if len(s.spaces) <= 0 {
+		return ""
+	}
+	return s.spaces[len(s.spaces)-1]
+}
+
 func (s *Parser) Dump() string {
 	var b bytes.Buffer
 	for _, e := range s.slice {